@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/EdmundHusserl/CRM/pkg/crmclient"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+var roles = map[string]crmclient.Role{
+	"basic":   crmclient.Basic,
+	"premium": crmclient.Premium,
+	"partner": crmclient.Partner,
+}
+
+// Field flags of customers create and update
+type customerFlags struct {
+	name, email, phone, role string
+	contacted                bool
+}
+
+func (f *customerFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.name, "name", "", "name")
+	fs.StringVar(&f.email, "email", "", "email")
+	fs.StringVar(&f.phone, "phone", "", "phone number")
+	fs.StringVar(&f.role, "role", "", "role: basic, premium or partner")
+	fs.BoolVar(&f.contacted, "contacted", false, "whether the customer was contacted")
+}
+
+// Sets the fields of c whose flag was given
+func (f *customerFlags) apply(fs *flag.FlagSet, c *crmclient.Customer) error {
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "name":
+			c.Name = f.name
+		case "email":
+			c.Email = f.email
+		case "phone":
+			c.PhoneNumber = f.phone
+		case "role":
+			var role crmclient.Role
+			if role, err = parseRole(f.role); err == nil {
+				c.Role = role
+			}
+		case "contacted":
+			c.Contacted = f.contacted
+		}
+	})
+	return err
+}
+
+// Reports whether any field flag was given
+func (f *customerFlags) given(fs *flag.FlagSet) bool {
+	given := false
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "name", "email", "phone", "role", "contacted":
+			given = true
+		}
+	})
+	return given
+}
+
+func parseRole(s string) (crmclient.Role, error) {
+	if role, ok := roles[strings.ToLower(s)]; ok {
+		return role, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= int(crmclient.Basic) && n <= int(crmclient.Partner) {
+		return crmclient.Role(n), nil
+	}
+	return 0, fmt.Errorf("%w: unknown role %q, expected basic, premium or partner", errUsage, s)
+}
+
+func roleName(r crmclient.Role) string {
+	for name, role := range roles {
+		if role == r {
+			return name
+		}
+	}
+	return strconv.Itoa(int(r))
+}
+
+func (a *app) listCustomers(ctx context.Context, args []string) error {
+	fs := a.flags("customers list")
+	limit := fs.Int("limit", 100, "customers fetched per request")
+	trash := fs.Bool("trash", false, "list deleted customers instead")
+	args, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("%w: customers list takes no arguments", errUsage)
+	}
+	customers, err := a.allCustomers(ctx, *limit, *trash)
+	if err != nil {
+		return err
+	}
+	return a.printCustomers(customers, false)
+}
+
+func (a *app) getCustomers(ctx context.Context, args []string) error {
+	fs := a.flags("customers get")
+	args, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	ids, err := a.ids(args)
+	if err != nil {
+		return err
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	customers := make([]crmclient.Customer, 0, len(ids))
+	for _, id := range ids {
+		customer, err := c.GetCustomer(ctx, id)
+		if err != nil {
+			return fmt.Errorf("getting customer %s: %w", id, err)
+		}
+		customers = append(customers, *customer)
+	}
+	return a.printCustomers(customers, len(args) == 1 && args[0] != "-")
+}
+
+func (a *app) createCustomers(ctx context.Context, args []string) error {
+	fs := a.flags("customers create")
+	file := fs.String("f", "", `JSON or YAML file of a customer or a list of customers, "-" for the standard input`)
+	var fields customerFlags
+	fields.register(fs)
+	args, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("%w: customers create takes no arguments", errUsage)
+	}
+
+	var (
+		customers []crmclient.Customer
+		single    = true
+	)
+	if *file != "" {
+		if fields.given(fs) {
+			return fmt.Errorf("%w: -f cannot be combined with field flags", errUsage)
+		}
+		docs, list, err := a.readDocuments(*file)
+		if err != nil {
+			return err
+		}
+		single = !list
+		for i, doc := range docs {
+			var customer crmclient.Customer
+			if err := json.Unmarshal(doc, &customer); err != nil {
+				return fmt.Errorf("reading customer %d of %s: %w", i+1, *file, err)
+			}
+			customers = append(customers, customer)
+		}
+	} else {
+		var customer crmclient.Customer
+		if err := fields.apply(fs, &customer); err != nil {
+			return err
+		}
+		customers = append(customers, customer)
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	var (
+		created = make([]crmclient.Customer, 0, len(customers))
+		// Customers created but not fetched back are shown as sent
+		fetchErrs []error
+	)
+	for _, customer := range customers {
+		id, err := c.CreateCustomer(ctx, customer)
+		if err != nil {
+			// Show what was created before failing, so that it is not created twice
+			if len(created) > 0 {
+				a.printCustomers(created, false)
+			}
+			return errors.Join(append(fetchErrs, fmt.Errorf("creating customer %q: %w", customer.Name, err))...)
+		}
+		got, err := c.GetCustomer(ctx, id)
+		if err != nil {
+			fetchErrs = append(fetchErrs, fmt.Errorf("customer %q was created with id %s but could not be fetched: %w", customer.Name, id, err))
+			customer.ID = id
+			got = &customer
+		}
+		created = append(created, *got)
+	}
+	if err := a.printCustomers(created, single); err != nil {
+		return err
+	}
+	return errors.Join(fetchErrs...)
+}
+
+func (a *app) updateCustomers(ctx context.Context, args []string) error {
+	fs := a.flags("customers update")
+	file := fs.String("f", "", `JSON or YAML file of a customer or a list of customers with their id, "-" for the standard input`)
+	var fields customerFlags
+	fields.register(fs)
+	args, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%w: customers update takes a single id", errUsage)
+	}
+
+	// Changes are applied onto the current customers, leaving the fields
+	// they do not mention as they are
+	type change struct {
+		id    uuid.UUID
+		apply func(*crmclient.Customer) error
+	}
+	var (
+		changes []change
+		single  = true
+	)
+	if *file != "" {
+		if fields.given(fs) {
+			return fmt.Errorf("%w: -f cannot be combined with field flags", errUsage)
+		}
+		docs, list, err := a.readDocuments(*file)
+		if err != nil {
+			return err
+		}
+		single = !list
+		for i, doc := range docs {
+			var target struct {
+				ID uuid.UUID `json:"id"`
+			}
+			if err := json.Unmarshal(doc, &target); err != nil {
+				return fmt.Errorf("reading customer %d of %s: %w", i+1, *file, err)
+			}
+			if len(args) == 1 && len(docs) == 1 {
+				if target.ID, err = uuid.Parse(args[0]); err != nil {
+					return fmt.Errorf("%w: %q is not a customer id", errUsage, args[0])
+				}
+			} else if target.ID == uuid.Nil {
+				return fmt.Errorf("%w: customer %d of %s has no id", errUsage, i+1, *file)
+			}
+			changes = append(changes, change{target.ID, func(c *crmclient.Customer) error {
+				return json.Unmarshal(doc, c)
+			}})
+		}
+	} else {
+		if len(args) != 1 {
+			return fmt.Errorf("%w: missing customer id", errUsage)
+		}
+		id, err := uuid.Parse(args[0])
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a customer id", errUsage, args[0])
+		}
+		if !fields.given(fs) {
+			return fmt.Errorf("%w: nothing to update, pass -f or field flags", errUsage)
+		}
+		changes = append(changes, change{id, func(c *crmclient.Customer) error {
+			return fields.apply(fs, c)
+		}})
+	}
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	updated := make([]crmclient.Customer, 0, len(changes))
+	for _, ch := range changes {
+		customer, err := c.GetCustomer(ctx, ch.id)
+		if err != nil {
+			return fmt.Errorf("getting customer %s: %w", ch.id, err)
+		}
+		if err := ch.apply(customer); err != nil {
+			return err
+		}
+		customer.ID = ch.id
+		if customer, err = c.UpdateCustomer(ctx, *customer); err != nil {
+			return fmt.Errorf("updating customer %s: %w", ch.id, err)
+		}
+		updated = append(updated, *customer)
+	}
+	return a.printCustomers(updated, single)
+}
+
+func (a *app) deleteCustomers(ctx context.Context, args []string) error {
+	fs := a.flags("customers delete")
+	args, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	ids, err := a.ids(args)
+	if err != nil {
+		return err
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := c.DeleteCustomer(ctx, id); err != nil {
+			return fmt.Errorf("deleting customer %s: %w", id, err)
+		}
+		fmt.Fprintf(a.stderr, "Deleted customer %s\n", id)
+	}
+	return nil
+}
+
+func (a *app) importCustomers(ctx context.Context, args []string) error {
+	fs := a.flags("customers import")
+	file := fs.String("f", "-", `JSON or YAML file of a list of customers, "-" for the standard input`)
+	args, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("%w: customers import takes no arguments", errUsage)
+	}
+	docs, _, err := a.readDocuments(*file)
+	if err != nil {
+		return err
+	}
+	customers := make([]crmclient.Customer, len(docs))
+	for i, doc := range docs {
+		if err := json.Unmarshal(doc, &customers[i]); err != nil {
+			return fmt.Errorf("reading customer %d of %s: %w", i+1, *file, err)
+		}
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	ids, err := c.ImportCustomers(ctx, customers)
+	if err != nil {
+		return fmt.Errorf("importing %d customers: %w", len(customers), err)
+	}
+	return a.printIDs(ids)
+}
+
+func (a *app) exportCustomers(ctx context.Context, args []string) error {
+	fs := a.flags("customers export")
+	file := fs.String("f", "-", `file to write, "-" for the standard output`)
+	limit := fs.Int("limit", 100, "customers fetched per request")
+	args, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("%w: customers export takes no arguments", errUsage)
+	}
+	// Exports are meant to be imported, tables cannot be
+	if a.output == "table" {
+		a.output = "json"
+	}
+	customers, err := a.allCustomers(ctx, *limit, false)
+	if err != nil {
+		return err
+	}
+	if *file == "-" {
+		return a.printCustomers(customers, false)
+	}
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	out := *a
+	out.stdout = f
+	if err := out.printCustomers(customers, false); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "Exported %d customers to %s\n", len(customers), *file)
+	return nil
+}
+
+func (a *app) allCustomers(ctx context.Context, pageSize int, trash bool) ([]crmclient.Customer, error) {
+	c, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+	seq := c.Customers(ctx, pageSize)
+	if trash {
+		seq = c.Trash(ctx, pageSize)
+	}
+	customers := []crmclient.Customer{}
+	for customer, err := range seq {
+		if err != nil {
+			return nil, fmt.Errorf("listing customers: %w", err)
+		}
+		customers = append(customers, customer)
+	}
+	return customers, nil
+}
+
+// Parses the flags of a command, which may be interleaved with its
+// arguments, and returns the arguments
+func (a *app) parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errFlags
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	switch a.output {
+	case "table", "json", "yaml":
+		return positional, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown output %q, expected table, json or yaml", errUsage, a.output)
+	}
+}
+
+// Returns the customer ids given as arguments, or on the standard input,
+// one per line, when the only argument is "-"
+func (a *app) ids(args []string) ([]uuid.UUID, error) {
+	if len(args) == 1 && args[0] == "-" {
+		args = nil
+		scanner := bufio.NewScanner(a.stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				args = append(args, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading ids: %w", err)
+		}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: missing customer id", errUsage)
+	}
+	ids := make([]uuid.UUID, len(args))
+	for i, arg := range args {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is not a customer id", errUsage, arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// Reads the JSON or YAML documents of path, or of the standard input for
+// "-", a single one or a list, and returns them as JSON. Roles may be
+// given by name.
+func (a *app) readDocuments(path string) (docs []json.RawMessage, list bool, err error) {
+	var b []byte
+	if path == "-" {
+		b, err = io.ReadAll(a.stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// YAML being a superset of JSON, both are read the same way
+	var content any
+	if err := yaml.Unmarshal(b, &content); err != nil {
+		return nil, false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	var items []any
+	switch v := content.(type) {
+	case []any:
+		items, list = v, true
+	case map[string]any:
+		items = []any{v}
+	case nil:
+		return nil, false, fmt.Errorf("reading %s: no customer", path)
+	default:
+		return nil, false, fmt.Errorf("reading %s: expected a customer or a list of customers", path)
+	}
+	for i, item := range items {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			return nil, false, fmt.Errorf("reading %s: item %d is not a customer", path, i+1)
+		}
+		if name, ok := fields["role"].(string); ok {
+			role, err := parseRole(name)
+			if err != nil {
+				return nil, false, err
+			}
+			fields["role"] = role
+		}
+		doc, err := json.Marshal(fields)
+		if err != nil {
+			return nil, false, fmt.Errorf("reading %s: %w", path, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, list, nil
+}
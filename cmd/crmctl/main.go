@@ -0,0 +1,231 @@
+// crmctl manages the customers of a CRM server from the command line.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/pkg/crmclient"
+)
+
+const usage = `Usage: crmctl [flags] <command> [command flags] [arguments]
+
+Commands:
+  customers list [-limit n] [-trash]
+  customers get <id>... | -
+  customers create [-f file|-] [-name ...] [-email ...] [-phone ...] [-role ...] [-contacted]
+  customers update -f file|- [<id>]
+  customers update <id> [-name ...] [-email ...] [-phone ...] [-role ...] [-contacted=true|false]
+  customers delete <id>... | -
+  customers import [-f file|-]
+  customers export [-f file] [-limit n]
+  profiles list
+  profiles use <name>
+  profiles set <name> [-server url] [-token token] [-tenant tenant] [-header key=value]
+
+A file or id of "-" is read from the standard input. Files are JSON or YAML.
+
+Flags:
+`
+
+// errUsage reports a command line crmctl does not understand
+var errUsage = errors.New("invalid usage")
+
+// errFlags reports invalid flags, already printed by the flag package
+var errFlags = fmt.Errorf("%w: invalid flags", errUsage)
+
+type app struct {
+	getenv func(string) string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	profile string
+	server  string
+	token   string
+	tenant  string
+	output  string
+	config  string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Getenv, os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command line args and returns the exit code: 1 when
+// the command failed, 2 when it could not be understood.
+func run(ctx context.Context, args []string, getenv func(string) string, stdin io.Reader, stdout, stderr io.Writer) int {
+	a := &app{getenv: getenv, stdin: stdin, stdout: stdout, stderr: stderr}
+	fs := flag.NewFlagSet("crmctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&a.profile, "profile", getenv("CRMCTL_PROFILE"), "profile to use instead of the current one (CRMCTL_PROFILE)")
+	fs.StringVar(&a.server, "server", getenv("CRMCTL_SERVER"), "base URL of the server, overriding the profile (CRMCTL_SERVER)")
+	fs.StringVar(&a.token, "token", getenv("CRMCTL_TOKEN"), "bearer token, overriding the profile (CRMCTL_TOKEN)")
+	fs.StringVar(&a.tenant, "tenant", "", "tenant, overriding the profile")
+	fs.StringVar(&a.config, "config", "", "profiles file (CRMCTL_CONFIG, default crmctl/config.yaml in the user configuration directory)")
+	a.outputFlag(fs)
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	err := a.dispatch(ctx, fs.Args())
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errFlags):
+		return 2
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "crmctl: %v\n", err)
+		fmt.Fprintln(stderr, "Run crmctl -h for usage.")
+		return 2
+	default:
+		a.printError(err)
+		return 1
+	}
+}
+
+func (a *app) dispatch(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("%w: missing command", errUsage)
+	}
+	var commands map[string]func(context.Context, []string) error
+	switch args[0] {
+	case "customers", "customer":
+		commands = map[string]func(context.Context, []string) error{
+			"list":   a.listCustomers,
+			"get":    a.getCustomers,
+			"create": a.createCustomers,
+			"update": a.updateCustomers,
+			"delete": a.deleteCustomers,
+			"import": a.importCustomers,
+			"export": a.exportCustomers,
+		}
+	case "profiles", "profile":
+		commands = map[string]func(context.Context, []string) error{
+			"list": a.listProfiles,
+			"use":  a.useProfile,
+			"set":  a.setProfile,
+		}
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+	command, ok := commands[args[1]]
+	if !ok {
+		return fmt.Errorf("%w: unknown command %q", errUsage, args[0]+" "+args[1])
+	}
+	return command(ctx, args[2:])
+}
+
+// Returns a flag set for a command, accepting the output flag again so
+// that it may follow the command
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("crmctl "+name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	a.outputFlag(fs)
+	return fs
+}
+
+func (a *app) outputFlag(fs *flag.FlagSet) {
+	if a.output == "" {
+		a.output = "table"
+	}
+	fs.StringVar(&a.output, "o", a.output, "output format: table, json or yaml")
+	fs.StringVar(&a.output, "output", a.output, "output format: table, json or yaml")
+}
+
+func (a *app) configPath() (string, error) {
+	if a.config != "" {
+		return a.config, nil
+	}
+	return configPath(a.getenv)
+}
+
+// Returns a client for the selected profile, its settings overridden by
+// the flags
+func (a *app) client() (*crmclient.Client, error) {
+	path, err := a.configPath()
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := profiles.get(a.profile)
+	if err != nil {
+		return nil, err
+	}
+	if a.server != "" {
+		p.Server = a.server
+	}
+	if a.token != "" {
+		p.Token = a.token
+	}
+	if a.tenant != "" {
+		p.Tenant = a.tenant
+	}
+	if p.Server == "" {
+		return nil, fmt.Errorf("%w: no server, pass -server or select a profile, see crmctl profiles set", errUsage)
+	}
+
+	opts := []crmclient.Option{crmclient.WithRetries(3, 200*time.Millisecond)}
+	if p.Token != "" {
+		opts = append(opts, crmclient.WithToken(p.Token))
+	}
+	if p.Tenant != "" {
+		opts = append(opts, crmclient.WithTenant(p.Tenant))
+	}
+	for key, value := range p.Headers {
+		opts = append(opts, crmclient.WithHeader(key, value))
+	}
+	return crmclient.New(p.Server, opts...)
+}
+
+// Prints err, each of joined errors and every invalid field of API errors
+// on its own line
+func (a *app) printError(err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			a.printError(err)
+		}
+		return
+	}
+	var apiErr *crmclient.Error
+	if !errors.As(err, &apiErr) {
+		fmt.Fprintf(a.stderr, "crmctl: %v\n", err)
+		return
+	}
+	msg := apiErr.Detail
+	if msg == "" {
+		msg = apiErr.Title
+	}
+	prefix := strings.TrimSuffix(strings.TrimSuffix(err.Error(), apiErr.Error()), ": ")
+	if prefix != "" {
+		prefix += ": "
+	}
+	fmt.Fprintf(a.stderr, "crmctl: %s%s (%d %s)\n", prefix, msg, apiErr.Status, apiErr.Code)
+	for _, f := range apiErr.Errors {
+		fmt.Fprintf(a.stderr, "  %s%s: %s\n", f.Pointer, f.Parameter, f.Reason)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/assignment"
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/handlers"
+	"github.com/EdmundHusserl/CRM/internal/idempotency"
+	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/EdmundHusserl/CRM/internal/requestid"
+	"github.com/EdmundHusserl/CRM/internal/router"
+	"github.com/EdmundHusserl/CRM/internal/tenant"
+	"github.com/EdmundHusserl/CRM/internal/webhooks"
+	"github.com/EdmundHusserl/CRM/pkg/crmclient"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Returns the URL of the real router over an in-memory repository, served
+// to anonymous administrators
+func newServer(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(newRouter(t))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func newRouter(t *testing.T) http.Handler {
+	t.Helper()
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	repo := providers.NewInMemoryCustomerRepository(nil)
+	bus := events.NewBus(16)
+	repo.Events = bus
+	dispatcher := webhooks.NewDispatcher(l, providers.NewInMemoryWebhookRepository())
+	keys := idempotency.NewKeys(l, providers.NewIdempotencyRepository(repo), time.Hour, time.Hour)
+	return router.NewRouter(
+		handlers.NewCustomerHandler(l, repo, assignment.NewStrategy(l, ""), bus),
+		handlers.NewWebhookHandler(l, dispatcher.Store, dispatcher),
+		handlers.NewHealthHandler(l, repo, "in-memory"),
+		requestid.Middleware,
+		auth.Middleware(l, auth.AnonymousResolver{}),
+		tenant.Middleware(l, tenant.DefaultResolver{}),
+		keys.Middleware,
+	)
+}
+
+// Runs crmctl with the profiles file of env and returns its outputs and
+// exit code
+type result struct {
+	stdout, stderr string
+	code           int
+}
+
+func crmctl(t *testing.T, env map[string]string, stdin string, args ...string) result {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, func(key string) string { return env[key] }, strings.NewReader(stdin), &stdout, &stderr)
+	return result{stdout.String(), stderr.String(), code}
+}
+
+func newEnv(t *testing.T) map[string]string {
+	return map[string]string{"CRMCTL_CONFIG": filepath.Join(t.TempDir(), "config.yaml")}
+}
+
+func TestProfiles(t *testing.T) {
+	env := newEnv(t)
+	if r := crmctl(t, env, "", "profiles", "set", "staging", "-server", "https://staging.corp.com", "-token", "s3cret", "-header", "X-Team=sales"); r.code != 0 {
+		t.Fatalf("profiles set staging = %+v", r)
+	}
+	if r := crmctl(t, env, "", "profiles", "set", "prod", "-server", "https://crm.corp.com", "-tenant", "acme"); r.code != 0 {
+		t.Fatalf("profiles set prod = %+v", r)
+	}
+	if info, err := os.Stat(env["CRMCTL_CONFIG"]); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("profiles file = %v, %v, want mode 0600", info, err)
+	}
+
+	r := crmctl(t, env, "", "profiles", "list")
+	if r.code != 0 || !strings.Contains(r.stdout, "*        staging  https://staging.corp.com") {
+		t.Errorf("profiles list = %+v, want staging current", r)
+	}
+	if r := crmctl(t, env, "", "profiles", "use", "prod"); r.code != 0 {
+		t.Fatalf("profiles use prod = %+v", r)
+	}
+	var profiles Profiles
+	r = crmctl(t, env, "", "profiles", "list", "-o", "json")
+	if err := json.Unmarshal([]byte(r.stdout), &profiles); err != nil {
+		t.Fatalf("profiles list -o json = %+v: %v", r, err)
+	}
+	if profiles.Current != "prod" || profiles.Profiles["staging"].Token != "********" || profiles.Profiles["staging"].Headers["X-Team"] != "sales" {
+		t.Errorf("profiles list -o json = %+v, want prod current and tokens hidden", profiles)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"unknown profile", []string{"profiles", "use", "dev"}, 1},
+		{"no server", []string{"profiles", "set", "dev", "-token", "t"}, 2},
+		{"invalid header", []string{"profiles", "set", "dev", "-header", "X-Team"}, 2},
+		{"unknown profile flag", []string{"-profile", "dev", "customers", "list"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if r := crmctl(t, env, "", tt.args...); r.code != tt.code {
+				t.Errorf("crmctl %v = %+v, want exit code %d", tt.args, r, tt.code)
+			}
+		})
+	}
+}
+
+func TestCustomers(t *testing.T) {
+	env := newEnv(t)
+	if r := crmctl(t, env, "", "profiles", "set", "local", "-server", newServer(t)); r.code != 0 {
+		t.Fatalf("profiles set = %+v", r)
+	}
+
+	// Create from flags
+	var jorge crmclient.Customer
+	r := crmctl(t, env, "", "customers", "create", "-name", "jorge", "-email", "jorge@corp.com", "-phone", "+1 555 010 0100", "-role", "premium", "-o", "json")
+	if err := json.Unmarshal([]byte(r.stdout), &jorge); err != nil || jorge.Name != "jorge" || jorge.Role != crmclient.Premium {
+		t.Fatalf("customers create = %+v, %+v, %v", r, jorge, err)
+	}
+	r = crmctl(t, env, "", "customers", "get", jorge.ID.String())
+	if r.code != 0 || !strings.Contains(r.stdout, "jorge") || !strings.Contains(r.stdout, "premium") {
+		t.Errorf("customers get = %+v", r)
+	}
+
+	// Update from flags, leaving the other fields as they are
+	r = crmctl(t, env, "", "customers", "update", jorge.ID.String(), "-contacted", "-o", "yaml")
+	if r.code != 0 || !strings.HasPrefix(r.stdout, "id: "+jorge.ID.String()) || !strings.Contains(r.stdout, "contacted: true") || !strings.Contains(r.stdout, "name: jorge") {
+		t.Errorf("customers update = %+v", r)
+	}
+
+	// Create and update from YAML on the standard input, roles by name
+	r = crmctl(t, env, "- name: ana\n  role: partner\n  email: ana@corp.com\n  phone_number: \"+1 555 010 0101\"\n- name: luis\n  email: luis@corp.com\n  phone_number: \"+1 555 010 0102\"\n",
+		"customers", "create", "-f", "-", "-o", "json")
+	var created []crmclient.Customer
+	if err := json.Unmarshal([]byte(r.stdout), &created); err != nil || len(created) != 2 || created[0].Role != crmclient.Partner {
+		t.Fatalf("customers create -f - = %+v, %+v, %v", r, created, err)
+	}
+	r = crmctl(t, env, `{"name": "ana maria"}`, "customers", "update", "-f", "-", created[0].ID.String(), "-o", "json")
+	var ana crmclient.Customer
+	if err := json.Unmarshal([]byte(r.stdout), &ana); err != nil || ana.Name != "ana maria" || ana.Email != "ana@corp.com" {
+		t.Errorf("customers update -f - = %+v, %+v, %v", r, ana, err)
+	}
+
+	// Export then import into another environment
+	if r := crmctl(t, env, "", "profiles", "set", "staging", "-server", newServer(t)); r.code != 0 {
+		t.Fatalf("profiles set = %+v", r)
+	}
+	r = crmctl(t, env, "", "customers", "export", "-o", "yaml", "-limit", "2")
+	export := r.stdout
+	var exported []map[string]any
+	if err := yaml.Unmarshal([]byte(export), &exported); err != nil || len(exported) != 3 {
+		t.Fatalf("customers export = %+v, %v", r, err)
+	}
+	r = crmctl(t, env, export, "-profile", "staging", "customers", "import")
+	if ids := strings.Fields(r.stdout); r.code != 0 || len(ids) != 3 {
+		t.Fatalf("customers import = %+v, want 3 ids", r)
+	}
+
+	// Delete the ids piped from list
+	r = crmctl(t, env, "", "-profile", "staging", "customers", "list", "-o", "json")
+	var all []crmclient.Customer
+	if err := json.Unmarshal([]byte(r.stdout), &all); err != nil || len(all) != 3 {
+		t.Fatalf("customers list = %+v, %v, want 3 customers", r, err)
+	}
+	var ids []string
+	for _, c := range all {
+		ids = append(ids, c.ID.String())
+	}
+	if r := crmctl(t, env, strings.Join(ids, "\n")+"\n", "-profile", "staging", "customers", "delete", "-"); r.code != 0 {
+		t.Fatalf("customers delete - = %+v", r)
+	}
+	r = crmctl(t, env, "", "-profile", "staging", "customers", "list")
+	if lines := strings.Split(strings.TrimSpace(r.stdout), "\n"); r.code != 0 || len(lines) != 1 {
+		t.Errorf("customers list = %+v, want a header only", r)
+	}
+	r = crmctl(t, env, "", "-profile", "staging", "customers", "list", "-trash")
+	if lines := strings.Split(strings.TrimSpace(r.stdout), "\n"); r.code != 0 || len(lines) != 4 || !strings.Contains(lines[0], "DELETED") {
+		t.Errorf("customers list -trash = %+v, want 3 deleted customers", r)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	env := newEnv(t)
+	env["CRMCTL_SERVER"] = newServer(t)
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{"validation", []string{"customers", "create", "-name", "jorge", "-email", "jorge"}, 1, "  /email: "},
+		{"not found", []string{"customers", "get", "6f1c4b4e-3a41-4b7b-9b59-6f7a4c1d2e3f"}, 1, "(404 not_found)"},
+		{"missing command", []string{"customers"}, 2, "missing command"},
+		{"unknown command", []string{"customers", "purge"}, 2, "unknown command"},
+		{"invalid id", []string{"customers", "delete", "jorge"}, 2, "is not a customer id"},
+		{"unknown role", []string{"customers", "create", "-role", "vip"}, 2, "unknown role"},
+		{"unknown output", []string{"customers", "list", "-o", "xml"}, 2, "unknown output"},
+		{"unknown flag", []string{"customers", "list", "-all"}, 2, "flag provided but not defined"},
+		{"nothing to update", []string{"customers", "update", "6f1c4b4e-3a41-4b7b-9b59-6f7a4c1d2e3f"}, 2, "nothing to update"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := crmctl(t, env, "", tt.args...)
+			if r.code != tt.code || !strings.Contains(r.stderr, tt.stderr) {
+				t.Errorf("crmctl %v = %+v, want exit code %d and %q", tt.args, r, tt.code, tt.stderr)
+			}
+		})
+	}
+
+	delete(env, "CRMCTL_SERVER")
+	if r := crmctl(t, env, "", "customers", "list"); r.code != 2 || !strings.Contains(r.stderr, "no server") {
+		t.Errorf("crmctl without server = %+v", r)
+	}
+}
+
+func TestCreateUnfetched(t *testing.T) {
+	router := newRouter(t)
+	// Customers are created but cannot be read back
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			http.Error(w, `{"status":403,"code":"forbidden","detail":"Forbidden"}`, http.StatusForbidden)
+			return
+		}
+		router.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	env := newEnv(t)
+	env["CRMCTL_SERVER"] = srv.URL
+
+	r := crmctl(t, env, `[{"name": "ana", "email": "ana@corp.com", "phone_number": "555"}, {"name": "luis", "email": "luis@corp.com", "phone_number": "555"}]`,
+		"customers", "create", "-f", "-", "-o", "json")
+	var created []crmclient.Customer
+	if err := json.Unmarshal([]byte(r.stdout), &created); err != nil || len(created) != 2 || created[0].ID == uuid.Nil || created[1].ID == uuid.Nil {
+		t.Fatalf("customers create = %+v, %v, want both customers with their id", r, err)
+	}
+	if r.code != 1 || strings.Count(r.stderr, "could not be fetched") != 2 || strings.Contains(r.stderr, "creating customer") {
+		t.Errorf("customers create = %+v, want fetch failures only", r)
+	}
+}
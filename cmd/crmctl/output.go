@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/EdmundHusserl/CRM/pkg/crmclient"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Prints v in the selected output, table printing the table instead
+func (a *app) print(v any, table func(w io.Writer)) error {
+	switch a.output {
+	case "json":
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		// Converted from JSON to keep the names and order of its fields
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		node, err := yamlNode(dec)
+		if err != nil {
+			return err
+		}
+		enc := yaml.NewEncoder(a.stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(node); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+		table(tw)
+		return tw.Flush()
+	}
+}
+
+// Prints customers, the only one rather than a list when single
+func (a *app) printCustomers(customers []crmclient.Customer, single bool) error {
+	var v any = customers
+	if single && len(customers) == 1 {
+		v = customers[0]
+	}
+	return a.print(v, func(w io.Writer) {
+		deleted := false
+		for _, c := range customers {
+			deleted = deleted || c.DeletedAt != nil
+		}
+		header := "ID\tNAME\tROLE\tEMAIL\tPHONE\tCONTACTED\tOWNER"
+		if deleted {
+			header += "\tDELETED"
+		}
+		fmt.Fprintln(w, header)
+		for _, c := range customers {
+			owner := "-"
+			if c.OwnerID != uuid.Nil {
+				owner = c.OwnerID.String()
+			}
+			row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%t\t%s", c.ID, c.Name, roleName(c.Role), c.Email, c.PhoneNumber, c.Contacted, owner)
+			if deleted {
+				at := "-"
+				if c.DeletedAt != nil {
+					at = c.DeletedAt.Format("2006-01-02 15:04:05")
+				}
+				row += "\t" + at
+			}
+			fmt.Fprintln(w, row)
+		}
+	})
+}
+
+// Prints ids, one per line in tables so that they can be piped
+func (a *app) printIDs(ids []uuid.UUID) error {
+	return a.print(ids, func(w io.Writer) {
+		for _, id := range ids {
+			fmt.Fprintln(w, id)
+		}
+	})
+}
+
+// Reads the next JSON value of dec as a YAML node
+func yamlNode(dec *json.Decoder) (*yaml.Node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if t == '{' {
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		for dec.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string)})
+			}
+			child, err := yamlNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		// Closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t}, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(t.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: t.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(t)}, nil
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+}
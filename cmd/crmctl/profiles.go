@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile is an environment crmctl connects to.
+type Profile struct {
+	Server string `yaml:"server" json:"server"`
+	Token  string `yaml:"token,omitempty" json:"token,omitempty"`
+	Tenant string `yaml:"tenant,omitempty" json:"tenant,omitempty"`
+	// Headers are sent with every request, such as the X-User-ID and
+	// X-User-Role of servers behind an authenticating proxy
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// Profiles are read from the YAML file at CRMCTL_CONFIG, or crmctl/config.yaml
+// in the user configuration directory.
+type Profiles struct {
+	// Current is the profile used when none is given
+	Current  string             `yaml:"current" json:"current"`
+	Profiles map[string]Profile `yaml:"profiles" json:"profiles"`
+}
+
+func configPath(getenv func(string) string) (string, error) {
+	if path := getenv("CRMCTL_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("locating the configuration file, set CRMCTL_CONFIG: %w", err)
+	}
+	return filepath.Join(dir, "crmctl", "config.yaml"), nil
+}
+
+// Reads the profiles at path, none when the file does not exist
+func loadProfiles(path string) (Profiles, error) {
+	p := Profiles{Profiles: map[string]Profile{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return p, fmt.Errorf("reading profiles from %s: %w", path, err)
+	}
+	if p.Profiles == nil {
+		p.Profiles = map[string]Profile{}
+	}
+	return p, nil
+}
+
+// Writes the profiles to path, readable by the user only as they hold tokens
+func (p Profiles) save(path string) error {
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o600)
+}
+
+// Returns the profile of the given name, or the current one when empty
+func (p Profiles) get(name string) (Profile, error) {
+	if name == "" {
+		name = p.Current
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	profile, ok := p.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q, known ones are %s", name, strings.Join(p.names(), ", "))
+	}
+	return profile, nil
+}
+
+func (p Profiles) names() []string {
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Header flags, key=value, repeatable
+type headerFlags map[string]string
+
+func (h headerFlags) String() string { return "" }
+
+func (h headerFlags) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	h[key] = value
+	return nil
+}
+
+func (a *app) listProfiles(_ context.Context, args []string) error {
+	fs := a.flags("profiles list")
+	args, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("%w: profiles list takes no arguments", errUsage)
+	}
+	path, err := a.configPath()
+	if err != nil {
+		return err
+	}
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		return err
+	}
+	// Tokens are secrets, they are only shown to be set
+	for name, p := range profiles.Profiles {
+		if p.Token != "" {
+			p.Token = "********"
+			profiles.Profiles[name] = p
+		}
+	}
+	return a.print(profiles, func(w io.Writer) {
+		fmt.Fprintln(w, "CURRENT\tNAME\tSERVER\tTENANT")
+		for _, name := range profiles.names() {
+			current := ""
+			if name == profiles.Current {
+				current = "*"
+			}
+			p := profiles.Profiles[name]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, name, p.Server, p.Tenant)
+		}
+	})
+}
+
+func (a *app) useProfile(_ context.Context, args []string) error {
+	fs := a.flags("profiles use")
+	args, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("%w: profiles use takes a profile name", errUsage)
+	}
+	path, err := a.configPath()
+	if err != nil {
+		return err
+	}
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		return err
+	}
+	if _, err := profiles.get(args[0]); err != nil {
+		return err
+	}
+	profiles.Current = args[0]
+	if err := profiles.save(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "Using profile %s\n", args[0])
+	return nil
+}
+
+func (a *app) setProfile(_ context.Context, args []string) error {
+	fs := a.flags("profiles set")
+	server := fs.String("server", "", "base URL of the server")
+	token := fs.String("token", "", "bearer token")
+	tenant := fs.String("tenant", "", "tenant")
+	headers := headerFlags{}
+	fs.Var(headers, "header", "header sent with every request, key=value, repeatable, an empty value removes it")
+	args, err := a.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("%w: profiles set takes a profile name", errUsage)
+	}
+	name := args[0]
+	path, err := a.configPath()
+	if err != nil {
+		return err
+	}
+	profiles, err := loadProfiles(path)
+	if err != nil {
+		return err
+	}
+
+	p := profiles.Profiles[name]
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "server":
+			p.Server = *server
+		case "token":
+			p.Token = *token
+		case "tenant":
+			p.Tenant = *tenant
+		}
+	})
+	for key, value := range headers {
+		if p.Headers == nil {
+			p.Headers = map[string]string{}
+		}
+		if value == "" {
+			delete(p.Headers, key)
+		} else {
+			p.Headers[key] = value
+		}
+	}
+	if p.Server == "" {
+		return fmt.Errorf("%w: profile %s has no server, pass -server", errUsage, name)
+	}
+	profiles.Profiles[name] = p
+	if profiles.Current == "" {
+		profiles.Current = name
+	}
+	if err := profiles.save(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "Saved profile %s to %s\n", name, path)
+	return nil
+}
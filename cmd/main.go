@@ -1,18 +1,47 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/EdmundHusserl/CRM/internal/config"
 	"github.com/EdmundHusserl/CRM/internal/server"
 )
 
 func main() {
-	dbProvider := flag.String("db", "in-memory", "DB provider: in-memory|psql")
-	serverPort := flag.Int("port", 3000, "Server port")
-	flag.Parse()
+	args := os.Args[1:]
+	// `config print` shows the effective configuration instead of serving
+	printConfig := len(args) >= 2 && args[0] == "config" && args[1] == "print"
+	if printConfig {
+		args = args[2:]
+	}
 
-	server := server.NewServer(*dbProvider, *serverPort)
-	defer server.DB.CloseDBConnection()
+	cfg, err := config.Load(args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	server.Listen()
+	server := server.NewServer(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := server.Listen(ctx); err != nil {
+		server.Logger.WithField("error", err.Error()).Fatal("Server failure")
+	}
 }
@@ -15,9 +15,100 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/audit": {
+            "get": {
+                "description": "Get the audit entries of every customer, oldest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Search the audit log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer id",
+                        "name": "customer_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Id of the user behind the mutation",
+                        "name": "actor_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Operation",
+                        "name": "operation",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 lower bound, inclusive",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 upper bound, exclusive",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of entries",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.AuditEntry"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
         "/api/customers": {
             "get": {
-                "description": "Get all customers",
+                "description": "Get all customers ordered by id, by pages when limit is set",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,6 +116,20 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "summary": "Get all customers",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of customers, up to 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Id of the last customer of the previous page",
+                        "name": "after",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -33,12 +138,36 @@ const docTemplate = `{
                             "items": {
                                 "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Customer"
                             }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "URL of the next page, as rel next"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.HandlerError"
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     }
                 }
@@ -87,6 +216,12 @@ const docTemplate = `{
                         "name": "contacted",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key under which retries get the response of the first request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -99,19 +234,43 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.HandlerError"
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     },
                     "422": {
                         "description": "Unprocessable Entity",
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.HandlerError"
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.HandlerError"
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     }
                 }
@@ -172,115 +331,1470 @@ const docTemplate = `{
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.HandlerError"
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     },
                     "422": {
                         "description": "Unprocessable Entity",
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.HandlerError"
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.HandlerError"
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/customers/{id}": {
-            "get": {
-                "description": "Get a customer by id",
+        "/api/customers/import": {
+            "post": {
+                "description": "Create customers in bulk, reserved to admins",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Get a customer by id",
+                "summary": "Import customers",
+                "parameters": [
+                    {
+                        "description": "Customers to import",
+                        "name": "customers",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Customer"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key under which retries get the response of the first request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Customer"
+                            "$ref": "#/definitions/internal_handlers.CustomersImportedResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.HandlerError"
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     },
                     "422": {
                         "description": "Unprocessable Entity",
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.HandlerError"
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Get a customer",
+            }
+        },
+        "/api/customers/reassign": {
+            "post": {
+                "description": "Transfer every customer of an owner to another, reserved to admins",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
-                "summary": "Delete a customer",
+                "summary": "Reassign customers in bulk",
+                "parameters": [
+                    {
+                        "description": "Previous and new owner",
+                        "name": "reassignment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.ReassignRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key under which retries get the response of the first request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.ReassignResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
                     },
                     "422": {
                         "description": "Unprocessable Entity",
                         "schema": {
-                            "$ref": "#/definitions/internal_handlers.HandlerError"
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "github_com_EdmundHusserl_CRM_internal_repository.Customer": {
-            "type": "object",
-            "properties": {
-                "contacted": {
-                    "type": "boolean"
-                },
-                "email": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "name": {
-                    "type": "string"
-                },
-                "phone_number": {
-                    "type": "string"
-                },
-                "role": {
-                    "type": "integer"
+        },
+        "/api/customers/stream": {
+            "get": {
+                "description": "Server-Sent Events stream of customer.created, customer.updated and customer.deleted events. Reconnecting clients sending Last-Event-ID receive the events they missed while they remain buffered. Customers deleted from the database directly are only known by their customer_id, their deletion being sent to every stream.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "summary": "Stream customer changes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Id of the last event received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only customers of this role",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only customers owned by this user",
+                        "name": "owner_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_events.Event"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
                 }
             }
         },
-        "internal_handlers.CustomerCreatedResponse": {
-            "type": "object",
-            "properties": {
-                "id": {
-                    "type": "string"
+        "/api/customers/trash": {
+            "get": {
+                "description": "Get the customers in the trash ordered by id, reserved to admins, by pages when limit is set",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get deleted customers",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of customers, up to 1000",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Id of the last customer of the previous page",
+                        "name": "after",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Customer"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "URL of the next page, as rel next"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/customers/{id}": {
+            "get": {
+                "description": "Get a customer by id",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get a customer by id",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Customer"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Move a customer to the trash",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Delete a customer",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/customers/{id}/assign": {
+            "post": {
+                "description": "Make a user the owner of a customer, reserved to admins",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Assign a customer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New owner",
+                        "name": "assignment",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.AssignRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key under which retries get the response of the first request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Customer"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/customers/{id}/history": {
+            "get": {
+                "description": "Get every audit entry of a customer, oldest first",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get the history of a customer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.AuditEntry"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/customers/{id}/restore": {
+            "post": {
+                "description": "Move a customer out of the trash, reserved to admins",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Restore a deleted customer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key under which retries get the response of the first request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Customer"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks": {
+            "get": {
+                "description": "Get every webhook subscription, without their secret, reserved to admins",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get webhook subscriptions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.WebhookSubscription"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Register a URL receiving the given customer events, reserved to admins. The secret signing the deliveries is generated when omitted and only returned by this call.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Subscribe to customer events",
+                "parameters": [
+                    {
+                        "description": "Subscription, events among customer.created, customer.updated, customer.deleted or *",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.WebhookSubscription"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key under which retries get the response of the first request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.WebhookSubscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks/deliveries": {
+            "get": {
+                "description": "Get webhook deliveries, the dead-letter list by default, reserved to admins",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Get webhook deliveries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Delivery status, defaults to dead",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks/deliveries/{id}/redeliver": {
+            "post": {
+                "description": "Send a dead or delivered delivery again with a fresh set of attempts, reserved to admins",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Redeliver a webhook delivery",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Delivery id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key under which retries get the response of the first request",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.WebhookDelivery"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhooks/{id}": {
+            "delete": {
+                "description": "Delete a webhook subscription and its pending deliveries, reserved to admins",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Unsubscribe from customer events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Answers as long as the process serves requests",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Pings the repository and checks that its schema is up to date",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.ReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.ReadinessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "Git SHA and time of the build, and the repository provider in use",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Build information",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_handlers.VersionResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "github_com_EdmundHusserl_CRM_internal_events.Event": {
+            "type": "object",
+            "properties": {
+                "actor_id": {
+                    "type": "string"
+                },
+                "changes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.FieldChange"
+                    }
+                },
+                "customer": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Customer"
+                },
+                "customer_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.TenantID"
+                },
+                "type": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_events.Type"
+                }
+            }
+        },
+        "github_com_EdmundHusserl_CRM_internal_events.Type": {
+            "type": "string",
+            "enum": [
+                "customer.created",
+                "customer.updated",
+                "customer.deleted"
+            ],
+            "x-enum-varnames": [
+                "CustomerCreated",
+                "CustomerUpdated",
+                "CustomerDeleted"
+            ]
+        },
+        "github_com_EdmundHusserl_CRM_internal_problem.Code": {
+            "type": "string",
+            "enum": [
+                "invalid_payload",
+                "invalid_parameter",
+                "validation_failed",
+                "unauthenticated",
+                "forbidden",
+                "not_found",
+                "method_not_allowed",
+                "conflict",
+                "request_in_progress",
+                "idempotency_key_reused",
+                "invalid_tenant",
+                "rate_limited",
+                "quota_exceeded",
+                "maintenance",
+                "internal_error"
+            ],
+            "x-enum-varnames": [
+                "InvalidPayload",
+                "InvalidParameter",
+                "ValidationFailed",
+                "Unauthenticated",
+                "Forbidden",
+                "NotFound",
+                "MethodNotAllowed",
+                "Conflict",
+                "RequestInProgress",
+                "IdempotencyKeyReused",
+                "InvalidTenant",
+                "RateLimited",
+                "QuotaExceeded",
+                "Maintenance",
+                "Internal"
+            ]
+        },
+        "github_com_EdmundHusserl_CRM_internal_problem.FieldError": {
+            "type": "object",
+            "properties": {
+                "parameter": {
+                    "type": "string"
+                },
+                "pointer": {
+                    "description": "JSON pointer of the member, such as /email or /2/phone_number",
+                    "type": "string",
+                    "example": "/email"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "\"jorge@\" is not an e-mail address"
+                }
+            }
+        },
+        "github_com_EdmundHusserl_CRM_internal_problem.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.Code"
+                        }
+                    ],
+                    "example": "validation_failed"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "Invalid customer"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_problem.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/customers"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 422
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Validation failed"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "https://github.com/EdmundHusserl/CRM/blob/main/docs/problems.md#validation_failed"
+                }
+            }
+        },
+        "github_com_EdmundHusserl_CRM_internal_repository.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "actor_id": {
+                    "type": "string"
+                },
+                "actor_name": {
+                    "type": "string"
+                },
+                "after": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Customer"
+                },
+                "before": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Customer"
+                },
+                "customer_id": {
+                    "type": "string"
+                },
+                "diff": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.FieldChange"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "occurred_at": {
+                    "type": "string"
+                },
+                "operation": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.Operation"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.TenantID"
+                }
+            }
+        },
+        "github_com_EdmundHusserl_CRM_internal_repository.Customer": {
+            "type": "object",
+            "required": [
+                "email",
+                "name",
+                "phone_number"
+            ],
+            "properties": {
+                "contacted": {
+                    "type": "boolean"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "owner_id": {
+                    "type": "string"
+                },
+                "phone_number": {
+                    "type": "string",
+                    "maxLength": 50
+                },
+                "role": {
+                    "type": "integer",
+                    "enum": [
+                        0,
+                        1,
+                        2
+                    ]
+                },
+                "tenant_id": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.TenantID"
+                }
+            }
+        },
+        "github_com_EdmundHusserl_CRM_internal_repository.DeliveryStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "delivered",
+                "dead"
+            ],
+            "x-enum-varnames": [
+                "DeliveryPending",
+                "DeliveryDelivered",
+                "DeliveryDead"
+            ]
+        },
+        "github_com_EdmundHusserl_CRM_internal_repository.FieldChange": {
+            "type": "object",
+            "properties": {
+                "after": {},
+                "before": {}
+            }
+        },
+        "github_com_EdmundHusserl_CRM_internal_repository.Operation": {
+            "type": "string",
+            "enum": [
+                "create",
+                "update",
+                "delete",
+                "assign",
+                "restore",
+                "purge"
+            ],
+            "x-enum-varnames": [
+                "OperationCreate",
+                "OperationUpdate",
+                "OperationDelete",
+                "OperationAssign",
+                "OperationRestore",
+                "OperationPurge"
+            ]
+        },
+        "github_com_EdmundHusserl_CRM_internal_repository.TenantID": {
+            "type": "string",
+            "enum": [
+                "default"
+            ],
+            "x-enum-varnames": [
+                "DefaultTenant"
+            ]
+        },
+        "github_com_EdmundHusserl_CRM_internal_repository.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "event_type": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "status": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.DeliveryStatus"
+                },
+                "subscription_id": {
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.TenantID"
+                }
+            }
+        },
+        "github_com_EdmundHusserl_CRM_internal_repository.WebhookSubscription": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "secret": {
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "$ref": "#/definitions/github_com_EdmundHusserl_CRM_internal_repository.TenantID"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.AssignRequest": {
+            "type": "object",
+            "properties": {
+                "owner_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.CustomerCreatedResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.CustomersImportedResponse": {
+            "type": "object",
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "internal_handlers.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
                 }
             }
         },
-        "internal_handlers.HandlerError": {
+        "internal_handlers.ReadinessResponse": {
             "type": "object",
             "properties": {
                 "error_message": {
                     "type": "string"
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "schema_version": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.ReassignRequest": {
+            "type": "object",
+            "properties": {
+                "from_owner_id": {
+                    "type": "string"
+                },
+                "to_owner_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_handlers.ReassignResponse": {
+            "type": "object",
+            "properties": {
+                "reassigned": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_handlers.VersionResponse": {
+            "type": "object",
+            "properties": {
+                "build_time": {
+                    "type": "string"
+                },
+                "git_sha": {
+                    "type": "string"
+                },
+                "go_version": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string"
                 }
             }
         }
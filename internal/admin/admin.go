@@ -0,0 +1,140 @@
+// Package admin serves the diagnostics and maintenance routes, meant for
+// operators only, on a listener separate from the public API.
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+
+	"github.com/EdmundHusserl/CRM/internal/metrics"
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+type Handler struct {
+	Logger *logrus.Logger
+	// DB is the connection pool of the repository, nil for the providers
+	// without one
+	DB          *sql.DB
+	Maintenance *Maintenance
+}
+
+type LogLevel struct {
+	Level string `json:"level" example:"debug"`
+}
+
+type MaintenanceMode struct {
+	Enabled bool `json:"enabled"`
+}
+
+// PoolStats describes the connection pool of the repository
+type PoolStats struct {
+	MaxOpen           int    `json:"max_open_connections"`
+	Open              int    `json:"open_connections"`
+	InUse             int    `json:"in_use"`
+	Idle              int    `json:"idle"`
+	WaitCount         int64  `json:"wait_count"`
+	WaitDuration      string `json:"wait_duration"`
+	MaxIdleClosed     int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64  `json:"max_lifetime_closed"`
+}
+
+// NewRouter registers the admin routes. They are not authenticated, the
+// listener serving them being expected to be reachable by operators only.
+func NewRouter(l *logrus.Logger, db *sql.DB, m *Maintenance) *mux.Router {
+	h := Handler{Logger: l, DB: db, Maintenance: m}
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(problem.NotFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(problem.MethodNotAllowedHandler)
+	router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+	router.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	router.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	router.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	router.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// Index serves the named profiles too, such as /debug/pprof/heap
+	router.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
+	router.HandleFunc("/admin/loglevel", h.GetLogLevel).Methods(http.MethodGet)
+	router.HandleFunc("/admin/loglevel", h.SetLogLevel).Methods(http.MethodPut)
+	router.HandleFunc("/admin/maintenance", h.GetMaintenance).Methods(http.MethodGet)
+	router.HandleFunc("/admin/maintenance", h.SetMaintenance).Methods(http.MethodPut)
+	router.HandleFunc("/admin/goroutines", h.Goroutines).Methods(http.MethodGet)
+	router.HandleFunc("/admin/db", h.Pool).Methods(http.MethodGet)
+	return router
+}
+
+func (h Handler) GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LogLevel{Level: h.Logger.GetLevel().String()})
+}
+
+// SetLogLevel changes the level of the logger at runtime, until the next
+// restart.
+func (h Handler) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var body LogLevel
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		problem.New(http.StatusBadRequest, problem.InvalidPayload, err.Error()).Write(w, r)
+		return
+	}
+	level, err := logrus.ParseLevel(body.Level)
+	if err != nil {
+		problem.New(http.StatusUnprocessableEntity, problem.ValidationFailed, "Invalid log level",
+			problem.FieldError{Pointer: "/level", Reason: fmt.Sprintf("%q is not one of panic, fatal, error, warn, info, debug, trace", body.Level)}).Write(w, r)
+		return
+	}
+	previous := h.Logger.GetLevel()
+	h.Logger.SetLevel(level)
+	h.Logger.WithField("event", fmt.Sprintf("from %s to %s", previous, level)).Warn("Log level changed")
+	h.GetLogLevel(w, r)
+}
+
+func (h Handler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MaintenanceMode{Enabled: h.Maintenance.Enabled()})
+}
+
+// SetMaintenance makes the public API read-only, or writable again.
+func (h Handler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var body MaintenanceMode
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		problem.New(http.StatusBadRequest, problem.InvalidPayload, err.Error()).Write(w, r)
+		return
+	}
+	if body.Enabled != h.Maintenance.Enabled() {
+		h.Maintenance.Set(body.Enabled)
+		h.Logger.WithField("event", fmt.Sprintf("enabled: %v", body.Enabled)).Warn("Maintenance mode changed")
+	}
+	h.GetMaintenance(w, r)
+}
+
+// Goroutines dumps the stack of every goroutine as text.
+func (h Handler) Goroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// Pool reports the usage of the connection pool of the repository.
+func (h Handler) Pool(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		problem.New(http.StatusNotFound, problem.NotFound, "The repository has no connection pool").Write(w, r)
+		return
+	}
+	s := h.DB.Stats()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PoolStats{
+		MaxOpen:           s.MaxOpenConnections,
+		Open:              s.OpenConnections,
+		InUse:             s.InUse,
+		Idle:              s.Idle,
+		WaitCount:         s.WaitCount,
+		WaitDuration:      s.WaitDuration.String(),
+		MaxIdleClosed:     s.MaxIdleClosed,
+		MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+		MaxLifetimeClosed: s.MaxLifetimeClosed,
+	})
+}
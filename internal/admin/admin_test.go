@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRouter(t *testing.T) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	m := &Maintenance{}
+	h := NewRouter(l, nil, m)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"log level", http.MethodGet, "/admin/loglevel", "", http.StatusOK, `{"level":"info"}`},
+		{"change log level", http.MethodPut, "/admin/loglevel", `{"level":"debug"}`, http.StatusOK, `{"level":"debug"}`},
+		{"unknown log level", http.MethodPut, "/admin/loglevel", `{"level":"verbose"}`, http.StatusUnprocessableEntity, `"pointer":"/level"`},
+		{"enable maintenance", http.MethodPut, "/admin/maintenance", `{"enabled":true}`, http.StatusOK, `{"enabled":true}`},
+		{"maintenance", http.MethodGet, "/admin/maintenance", "", http.StatusOK, `{"enabled":true}`},
+		{"goroutines", http.MethodGet, "/admin/goroutines", "", http.StatusOK, "goroutine "},
+		{"pool without database", http.MethodGet, "/admin/db", "", http.StatusNotFound, `"code":"not_found"`},
+		{"pprof profile", http.MethodGet, "/debug/pprof/heap?debug=1", "", http.StatusOK, "heap profile"},
+		{"metrics", http.MethodGet, "/metrics", "", http.StatusOK, "go_goroutines"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s = %d %s, want %d with %s", tt.method, tt.target, rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+	if l.GetLevel() != logrus.DebugLevel || !m.Enabled() {
+		t.Errorf("level %v and maintenance %v after the changes", l.GetLevel(), m.Enabled())
+	}
+}
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	m := &Maintenance{}
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		method      string
+		maintenance bool
+		want        int
+	}{
+		{http.MethodPost, false, http.StatusOK},
+		{http.MethodGet, true, http.StatusOK},
+		{http.MethodHead, true, http.StatusOK},
+		{http.MethodPost, true, http.StatusServiceUnavailable},
+		{http.MethodPatch, true, http.StatusServiceUnavailable},
+		{http.MethodDelete, true, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		m.Set(tt.maintenance)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, "/api/customers", nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s in maintenance %v = %d, want %d", tt.method, tt.maintenance, rec.Code, tt.want)
+		}
+	}
+}
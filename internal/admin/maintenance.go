@@ -0,0 +1,35 @@
+package admin
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/EdmundHusserl/CRM/internal/problem"
+)
+
+// Maintenance makes the public API read-only while enabled. Its zero value
+// is disabled.
+type Maintenance struct {
+	enabled atomic.Bool
+}
+
+func (m *Maintenance) Enabled() bool {
+	return m.enabled.Load()
+}
+
+func (m *Maintenance) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// Middleware answers the requests changing data with 503 while in
+// maintenance, reads being served as usual.
+func (m *Maintenance) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		safe := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+		if !safe && m.Enabled() {
+			problem.New(http.StatusServiceUnavailable, problem.Maintenance, "The API is read-only during maintenance").Write(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,154 @@
+package assignment
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	roundRobin  string = "round_robin"
+	roleTier    string = "role_tier"
+	emailDomain string = "email_domain"
+)
+
+// Strategy picks the owner of a newly created customer. ok is false when
+// the strategy has no opinion about c.
+type Strategy interface {
+	Assign(c repository.Customer) (owner uuid.UUID, ok bool)
+}
+
+// None never assigns an owner.
+type None struct{}
+
+func (None) Assign(c repository.Customer) (uuid.UUID, bool) {
+	return uuid.Nil, false
+}
+
+// RoundRobin cycles through its owners regardless of the customer.
+type RoundRobin struct {
+	Owners []uuid.UUID
+	next   atomic.Uint64
+}
+
+func (r *RoundRobin) Assign(c repository.Customer) (uuid.UUID, bool) {
+	if len(r.Owners) == 0 {
+		return uuid.Nil, false
+	}
+	i := r.next.Add(1) - 1
+	return r.Owners[i%uint64(len(r.Owners))], true
+}
+
+// RoleTier round-robins customers of the given roles among its owners.
+type RoleTier struct {
+	Roles []repository.CustomerRole
+	RoundRobin
+}
+
+func (r *RoleTier) Assign(c repository.Customer) (uuid.UUID, bool) {
+	if !slices.Contains(r.Roles, c.Role) {
+		return uuid.Nil, false
+	}
+	return r.RoundRobin.Assign(c)
+}
+
+// EmailDomain round-robins customers whose e-mail belongs to one of the
+// territory's domains among its owners.
+type EmailDomain struct {
+	Domains []string
+	RoundRobin
+}
+
+func (e *EmailDomain) Assign(c repository.Customer) (uuid.UUID, bool) {
+	_, domain, found := strings.Cut(c.Email, "@")
+	if !found || !slices.Contains(e.Domains, strings.ToLower(domain)) {
+		return uuid.Nil, false
+	}
+	return e.RoundRobin.Assign(c)
+}
+
+// Chain returns the owner picked by the first strategy having one.
+type Chain []Strategy
+
+func (ch Chain) Assign(c repository.Customer) (uuid.UUID, bool) {
+	for _, s := range ch {
+		if owner, ok := s.Assign(c); ok {
+			return owner, true
+		}
+	}
+	return uuid.Nil, false
+}
+
+type rule struct {
+	Strategy string                    `json:"strategy"`
+	Owners   []uuid.UUID               `json:"owners"`
+	Roles    []repository.CustomerRole `json:"roles"`
+	Domains  []string                  `json:"domains"`
+}
+
+type rules struct {
+	Rules []rule `json:"rules"`
+}
+
+// Parse builds a Chain from a JSON document listing rules in order of
+// precedence.
+func Parse(b []byte) (Chain, error) {
+	var rs rules
+	if err := json.Unmarshal(b, &rs); err != nil {
+		return nil, err
+	}
+
+	chain := Chain{}
+	for i, r := range rs.Rules {
+		if len(r.Owners) == 0 {
+			return nil, fmt.Errorf("rule %d: no owners", i)
+		}
+		switch r.Strategy {
+		case roundRobin:
+			chain = append(chain, &RoundRobin{Owners: r.Owners})
+		case roleTier:
+			if len(r.Roles) == 0 {
+				return nil, fmt.Errorf("rule %d: no roles", i)
+			}
+			chain = append(chain, &RoleTier{Roles: r.Roles, RoundRobin: RoundRobin{Owners: r.Owners}})
+		case emailDomain:
+			if len(r.Domains) == 0 {
+				return nil, fmt.Errorf("rule %d: no domains", i)
+			}
+			domains := make([]string, len(r.Domains))
+			for j, d := range r.Domains {
+				domains[j] = strings.ToLower(d)
+			}
+			chain = append(chain, &EmailDomain{Domains: domains, RoundRobin: RoundRobin{Owners: r.Owners}})
+		default:
+			return nil, fmt.Errorf("rule %d: unknown strategy %q", i, r.Strategy)
+		}
+	}
+	return chain, nil
+}
+
+// Returns the Strategy described by the rules file at path, or None when
+// path is empty.
+func NewStrategy(l *logrus.Logger, path string) Strategy {
+	if len(path) == 0 {
+		return None{}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		l.WithField("error", err.Error()).Fatal(fmt.Sprintf("error reading assignment rules %q", path))
+	}
+	chain, err := Parse(b)
+	if err != nil {
+		l.WithField("error", err.Error()).Fatal(fmt.Sprintf("error parsing assignment rules %q", path))
+	}
+	l.WithField("event", fmt.Sprintf("%d rules loaded from %s", len(chain), path)).Info("Auto-assignment enabled")
+	return chain
+}
@@ -0,0 +1,75 @@
+package assignment
+
+import (
+	"testing"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+)
+
+func TestChainAssign(t *testing.T) {
+	territory, premium := uuid.New(), uuid.New()
+	pool := []uuid.UUID{uuid.New(), uuid.New()}
+
+	chain := Chain{
+		&EmailDomain{Domains: []string{"corp.com"}, RoundRobin: RoundRobin{Owners: []uuid.UUID{territory}}},
+		&RoleTier{Roles: []repository.CustomerRole{repository.Premium}, RoundRobin: RoundRobin{Owners: []uuid.UUID{premium}}},
+		&RoundRobin{Owners: pool},
+	}
+
+	tests := []struct {
+		name string
+		data repository.Customer
+		want uuid.UUID
+	}{
+		{"Territory_wins", repository.Customer{Email: "jorge@Corp.com", Role: repository.Premium}, territory},
+		{"Role_tier", repository.Customer{Email: "jorge@acme.io", Role: repository.Premium}, premium},
+		{"Round_robin_first", repository.Customer{Email: "a@acme.io"}, pool[0]},
+		{"Round_robin_second", repository.Customer{Email: "b@acme.io"}, pool[1]},
+		{"Round_robin_wraps", repository.Customer{Email: "c@acme.io"}, pool[0]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := chain.Assign(tt.data)
+			if !ok || got != tt.want {
+				t.Errorf("Assign() = %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		count   int
+		wantErr bool
+	}{
+		{
+			"Success",
+			`{"rules": [
+				{"strategy": "email_domain", "domains": ["corp.com"], "owners": ["6f0f5c3e-5a8e-4b7e-9c41-0d7f1f0d2a11"]},
+				{"strategy": "role_tier", "roles": [1, 2], "owners": ["6f0f5c3e-5a8e-4b7e-9c41-0d7f1f0d2a11"]},
+				{"strategy": "round_robin", "owners": ["6f0f5c3e-5a8e-4b7e-9c41-0d7f1f0d2a11"]}
+			]}`,
+			3,
+			false,
+		},
+		{"Fails_on_unknown_strategy", `{"rules": [{"strategy": "random", "owners": ["6f0f5c3e-5a8e-4b7e-9c41-0d7f1f0d2a11"]}]}`, 0, true},
+		{"Fails_without_owners", `{"rules": [{"strategy": "round_robin"}]}`, 0, true},
+		{"Fails_without_domains", `{"rules": [{"strategy": "email_domain", "owners": ["6f0f5c3e-5a8e-4b7e-9c41-0d7f1f0d2a11"]}]}`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := Parse([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(chain) != tt.count {
+				t.Errorf("Rule count=%v not equal to expected=%v", len(chain), tt.count)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/EdmundHusserl/CRM/internal/logging"
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Middleware resolves the user of every request and stores it in the
+// request context, and its principal in the request logger. Requests that
+// cannot be resolved are rejected with 401.
+func Middleware(l *logrus.Logger, resolver Resolver) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, err := resolver.Resolve(r)
+			if err != nil {
+				problem.New(http.StatusUnauthorized, problem.Unauthenticated, err.Error()).Write(w, r)
+
+				logging.FromContext(r.Context(), l).WithFields(logrus.Fields{
+					"error_message": err.Error(),
+					"status":        http.StatusUnauthorized,
+				}).Info("Authentication failure")
+				return
+			}
+			logging.AddFields(r.Context(), logrus.Fields{"principal": u.Principal()})
+			next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), u)))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+)
+
+// Action is an operation a user attempts on customer records.
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	ActionImport Action = "import"
+	ActionAssign Action = "assign"
+	// Browsing the audit log across customers
+	ActionAudit Action = "audit"
+	// Browsing and restoring the trash
+	ActionRestore Action = "restore"
+	// Managing webhook subscriptions and deliveries
+	ActionWebhooks Action = "webhooks"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// Policy decides which customers a user may see and what they may do
+// with them.
+type Policy interface {
+	// Authorize returns ErrForbidden unless u may perform a on c. c is nil
+	// for actions that do not target an existing record.
+	Authorize(u User, a Action, c *repository.Customer) error
+	// Scope returns the filter restricting list queries to the records
+	// visible to u.
+	Scope(u User) repository.CustomerFilter
+}
+
+// RolePolicy grants permissions based on the user's role alone:
+// analysts read everything including the audit log, sales reps read and edit the customers they
+// own and admins are unrestricted.
+type RolePolicy struct{}
+
+func (RolePolicy) Authorize(u User, a Action, c *repository.Customer) error {
+	switch u.Role {
+	case RoleAdmin:
+		return nil
+	case RoleAnalyst:
+		if a == ActionRead || a == ActionAudit {
+			return nil
+		}
+	case RoleSalesRep:
+		switch a {
+		case ActionCreate, ActionRead, ActionUpdate:
+			// Customers created for another owner would escape their creator
+			if c != nil && c.OwnerID == u.ID {
+				return nil
+			}
+		}
+	}
+	return ErrForbidden
+}
+
+func (RolePolicy) Scope(u User) repository.CustomerFilter {
+	if u.Role == RoleSalesRep {
+		return repository.CustomerFilter{OwnerID: u.ID}
+	}
+	return repository.CustomerFilter{}
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+)
+
+func TestRolePolicyAuthorize(t *testing.T) {
+	rep := User{ID: uuid.New(), Name: "Rep", Role: RoleSalesRep}
+	owned := &repository.Customer{ID: uuid.New(), OwnerID: rep.ID}
+	foreign := &repository.Customer{ID: uuid.New(), OwnerID: uuid.New()}
+
+	tests := []struct {
+		name    string
+		user    User
+		action  Action
+		data    *repository.Customer
+		wantErr bool
+	}{
+		{"Admin_deletes", User{ID: uuid.New(), Role: RoleAdmin}, ActionDelete, foreign, false},
+		{"Admin_imports", User{ID: uuid.New(), Role: RoleAdmin}, ActionImport, nil, false},
+		{"Analyst_reads", User{ID: uuid.New(), Role: RoleAnalyst}, ActionRead, foreign, false},
+		{"Analyst_audits", User{ID: uuid.New(), Role: RoleAnalyst}, ActionAudit, nil, false},
+		{"Rep_cannot_audit", rep, ActionAudit, nil, true},
+		{"Analyst_cannot_restore", User{ID: uuid.New(), Role: RoleAnalyst}, ActionRestore, nil, true},
+		{"Admin_restores", User{ID: uuid.New(), Role: RoleAdmin}, ActionRestore, nil, false},
+		{"Analyst_cannot_update", User{ID: uuid.New(), Role: RoleAnalyst}, ActionUpdate, foreign, true},
+		{"Analyst_cannot_create", User{ID: uuid.New(), Role: RoleAnalyst}, ActionCreate, nil, true},
+		{"Rep_creates_owned", rep, ActionCreate, owned, false},
+		{"Rep_cannot_create_foreign", rep, ActionCreate, foreign, true},
+		{"Rep_updates_owned", rep, ActionUpdate, owned, false},
+		{"Rep_cannot_update_foreign", rep, ActionUpdate, foreign, true},
+		{"Rep_cannot_read_foreign", rep, ActionRead, foreign, true},
+		{"Rep_cannot_delete_owned", rep, ActionDelete, owned, true},
+		{"Rep_cannot_import", rep, ActionImport, nil, true},
+		{"Rep_cannot_assign_owned", rep, ActionAssign, owned, true},
+		{"Admin_assigns", User{ID: uuid.New(), Role: RoleAdmin}, ActionAssign, foreign, false},
+		{"Unknown_role_denied", User{ID: uuid.New()}, ActionRead, owned, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RolePolicy{}.Authorize(tt.user, tt.action, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authorize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrForbidden) {
+				t.Errorf("Authorize() error = %v, want ErrForbidden", err)
+			}
+		})
+	}
+}
+
+func TestRolePolicyScope(t *testing.T) {
+	rep := User{ID: uuid.New(), Role: RoleSalesRep}
+
+	tests := []struct {
+		name string
+		user User
+		want repository.CustomerFilter
+	}{
+		{"Rep_sees_owned", rep, repository.CustomerFilter{OwnerID: rep.ID}},
+		{"Analyst_sees_all", User{ID: uuid.New(), Role: RoleAnalyst}, repository.CustomerFilter{}},
+		{"Admin_sees_all", User{ID: uuid.New(), Role: RoleAdmin}, repository.CustomerFilter{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (RolePolicy{}).Scope(tt.user); got != tt.want {
+				t.Errorf("Scope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ModeNone   string = "none"
+	ModeHeader string = "header"
+	ModeToken  string = "token"
+
+	UserIDHeader   string = "X-User-ID"
+	UserRoleHeader string = "X-User-Role"
+)
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Resolver identifies the user behind an incoming request.
+type Resolver interface {
+	Resolve(r *http.Request) (User, error)
+}
+
+// AnonymousResolver treats every caller as an administrator. It keeps
+// deployments without an identity provider working as before.
+type AnonymousResolver struct{}
+
+func (AnonymousResolver) Resolve(r *http.Request) (User, error) {
+	return User{Name: "anonymous", Role: RoleAdmin}, nil
+}
+
+// HeaderResolver trusts identity headers set by an authenticating proxy.
+type HeaderResolver struct{}
+
+func (HeaderResolver) Resolve(r *http.Request) (User, error) {
+	id, err := uuid.Parse(r.Header.Get(UserIDHeader))
+	if err != nil || id == uuid.Nil {
+		return User{}, fmt.Errorf("%w: invalid %s header", ErrUnauthenticated, UserIDHeader)
+	}
+	role, err := ParseRole(r.Header.Get(UserRoleHeader))
+	if err != nil {
+		return User{}, fmt.Errorf("%w: %s", ErrUnauthenticated, err.Error())
+	}
+	return User{ID: id, Role: role}, nil
+}
+
+// TokenResolver maps bearer tokens to known users.
+type TokenResolver struct {
+	Users map[string]User
+}
+
+type tokenEntry struct {
+	User
+	Token string `json:"token"`
+}
+
+// LoadTokenResolver reads a JSON array of users, each with its own token.
+func LoadTokenResolver(path string) (*TokenResolver, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []tokenEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]User, len(entries))
+	for _, e := range entries {
+		if err := checkUser(e.User); err != nil {
+			return nil, err
+		}
+		if e.Token == "" {
+			return nil, fmt.Errorf("user %s has no token", e.ID)
+		}
+		users[e.Token] = e.User
+	}
+	return &TokenResolver{Users: users}, nil
+}
+
+// Checks the users read from files
+func checkUser(u User) error {
+	if u.ID == uuid.Nil {
+		return fmt.Errorf("user %q has no id", u.Name)
+	}
+	if _, err := ParseRole(string(u.Role)); err != nil {
+		return fmt.Errorf("user %s: %s", u.ID, err.Error())
+	}
+	if u.Tenant != "" {
+		if _, err := repository.ParseTenantID(string(u.Tenant)); err != nil {
+			return fmt.Errorf("user %s: %s", u.ID, err.Error())
+		}
+	}
+	return nil
+}
+
+func (t *TokenResolver) Resolve(r *http.Request) (User, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return User{}, fmt.Errorf("%w: missing bearer token", ErrUnauthenticated)
+	}
+	u, ok := t.Users[token]
+	if !ok {
+		return User{}, fmt.Errorf("%w: unknown token", ErrUnauthenticated)
+	}
+	return u, nil
+}
+
+// CertificateResolver authenticates the callers presenting a verified
+// client certificate as the user its subject maps to, and the others with
+// Next.
+type CertificateResolver struct {
+	// Users by certificate subject, such as CN=billing,O=Acme
+	Users map[string]User
+	Next  Resolver
+}
+
+type certificateEntry struct {
+	User
+	Subject string `json:"subject"`
+}
+
+// LoadCertificateResolver reads a JSON array of users, each with the
+// subject of its certificate.
+func LoadCertificateResolver(path string, next Resolver) (*CertificateResolver, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []certificateEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]User, len(entries))
+	for _, e := range entries {
+		if err := checkUser(e.User); err != nil {
+			return nil, err
+		}
+		subject, err := parseSubject(e.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %s", e.ID, err.Error())
+		}
+		users[subject] = e.User
+	}
+	return &CertificateResolver{Users: users, Next: next}, nil
+}
+
+// Returns the canonical form of a distinguished name, as printed by
+// pkix.Name, for subjects to match whatever their spacing
+func parseSubject(s string) (string, error) {
+	var rdns []string
+	for _, rdn := range splitRDNs(s) {
+		attr, value, ok := strings.Cut(strings.TrimSpace(rdn), "=")
+		if !ok || attr == "" || value == "" {
+			return "", fmt.Errorf("invalid certificate subject %q, use a form such as CN=billing,O=Acme", s)
+		}
+		rdns = append(rdns, strings.ToUpper(strings.TrimSpace(attr))+"="+strings.TrimSpace(value))
+	}
+	return strings.Join(rdns, ","), nil
+}
+
+// Splits a distinguished name on its unescaped commas
+func splitRDNs(s string) []string {
+	var rdns []string
+	start, escaped := 0, false
+	for i, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == ',':
+			rdns = append(rdns, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(rdns, s[start:])
+}
+
+func (c *CertificateResolver) Resolve(r *http.Request) (User, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return c.Next.Resolve(r)
+	}
+	subject := r.TLS.VerifiedChains[0][0].Subject.String()
+	u, ok := c.Users[subject]
+	if !ok {
+		return User{}, fmt.Errorf("%w: unknown client certificate %q", ErrUnauthenticated, subject)
+	}
+	return u, nil
+}
+
+// NewCertificateResolver returns a resolver authenticating the users of
+// clientsFile by their certificate, and the other callers with next.
+func NewCertificateResolver(l *logrus.Logger, clientsFile string, next Resolver) Resolver {
+	r, err := LoadCertificateResolver(clientsFile, next)
+	if err != nil {
+		l.WithField(
+			"error", err.Error(),
+		).Fatal(fmt.Sprintf("error loading clients file %q", clientsFile))
+	}
+	return r
+}
+
+// Returns a Resolver for the given mode. Token mode reads its users from
+// usersFile.
+func NewResolver(l *logrus.Logger, mode, usersFile string) Resolver {
+	switch strings.ToLower(mode) {
+	case ModeHeader:
+		return HeaderResolver{}
+	case ModeToken:
+		r, err := LoadTokenResolver(usersFile)
+		if err != nil {
+			l.WithField(
+				"error", err.Error(),
+			).Fatal(fmt.Sprintf("error loading users file %q", usersFile))
+		}
+		return r
+	default:
+		if mode != ModeNone {
+			l.WithField(
+				"event", fmt.Sprintf("defaulting to %s", ModeNone),
+			).Info("Unknown auth mode")
+		}
+		l.WithField(
+			"event", "every request is treated as an administrator",
+		).Warn("Authentication disabled")
+		return AnonymousResolver{}
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCertificateResolver(t *testing.T) {
+	billing := User{ID: uuid.New(), Name: "billing", Role: RoleAnalyst, Tenant: "acme"}
+	path := filepath.Join(t.TempDir(), "clients.json")
+	os.WriteFile(path, []byte(`[{"subject": "cn=billing, O=Acme\\, Inc", "id": "`+billing.ID.String()+`", "name": "billing", "role": "analyst", "tenant": "acme"}]`), 0o600)
+	resolver, err := LoadCertificateResolver(path, HeaderResolver{})
+	if err != nil {
+		t.Fatalf("LoadCertificateResolver() error = %v", err)
+	}
+
+	rep := uuid.New()
+	tests := []struct {
+		name    string
+		subject *pkix.Name
+		header  string
+		want    User
+		wantErr bool
+	}{
+		{"known certificate", &pkix.Name{CommonName: "billing", Organization: []string{"Acme, Inc"}}, "", billing, false},
+		{"unknown certificate", &pkix.Name{CommonName: "billing", Organization: []string{"Acme"}}, "", User{}, true},
+		{"without certificate", nil, rep.String(), User{ID: rep, Role: RoleSalesRep}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/customers", nil)
+			r.Header.Set(UserIDHeader, tt.header)
+			r.Header.Set(UserRoleHeader, string(RoleSalesRep))
+			if tt.subject != nil {
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: *tt.subject}}}}
+			}
+			got, err := resolver.Resolve(r)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Resolve() = %+v, %v, want %+v", got, err, tt.want)
+			}
+			if err != nil && !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("Resolve() error = %v, want ErrUnauthenticated", err)
+			}
+		})
+	}
+}
+
+func TestLoadCertificateResolverErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		clients string
+		want    string
+	}{
+		{"missing id", `[{"subject": "CN=billing", "role": "admin"}]`, "has no id"},
+		{"invalid subject", `[{"subject": "billing", "id": "` + uuid.NewString() + `", "role": "admin"}]`, "invalid certificate subject"},
+		{"unknown role", `[{"subject": "CN=billing", "id": "` + uuid.NewString() + `", "role": "root"}]`, "unknown role"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "clients.json")
+			os.WriteFile(path, []byte(tt.clients), 0o600)
+			_, err := LoadCertificateResolver(path, AnonymousResolver{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadCertificateResolver() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Role describes what a CRM user is allowed to do.
+type Role string
+
+const (
+	// Analysts can read every customer but change none.
+	RoleAnalyst Role = "analyst"
+	// Sales reps can create customers and edit the ones they own.
+	RoleSalesRep Role = "sales_rep"
+	// Admins can do everything, including deletes and imports.
+	RoleAdmin Role = "admin"
+)
+
+func ParseRole(s string) (Role, error) {
+	switch r := Role(strings.ToLower(strings.TrimSpace(s))); r {
+	case RoleAnalyst, RoleSalesRep, RoleAdmin:
+		return r, nil
+	default:
+		return "", fmt.Errorf("unknown role: %q", s)
+	}
+}
+
+// User is the authenticated principal behind a request.
+type User struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Role Role      `json:"role"`
+	// Tenant the user belongs to, empty when it may act for any
+	Tenant repository.TenantID `json:"tenant,omitempty"`
+}
+
+// Principal identifies u in logs, by id or by name for the anonymous user.
+func (u User) Principal() string {
+	if u.ID == uuid.Nil {
+		return u.Name
+	}
+	return u.ID.String()
+}
@@ -0,0 +1,214 @@
+// Package certs terminates TLS with certificates reloaded on SIGHUP or when
+// their files change, new connections getting the new certificates while
+// established ones are kept.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// How often the files are checked for changes
+const pollInterval = 10 * time.Second
+
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuths = map[string]tls.ClientAuthType{
+	"none":     tls.NoClientCert,
+	"optional": tls.VerifyClientCertIfGiven,
+	"require":  tls.RequireAndVerifyClientCert,
+}
+
+// ParseCipherSuites returns the ids of comma separated cipher suite names,
+// nil for an empty list. Suites known to be insecure are refused.
+func ParseCipherSuites(s string) ([]uint16, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	var ids []uint16
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Modification time and size of a file, telling when it changes
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Reloader serves the certificates of its files, see Run.
+type Reloader struct {
+	Logger       *logrus.Logger
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	Interval     time.Duration
+
+	// The settings the certificates are added to
+	base    *tls.Config
+	current atomic.Pointer[tls.Config]
+
+	mu     sync.Mutex
+	stamps map[string]stamp
+}
+
+// NewReloader loads the certificates of cfg, failing when they cannot be
+// used.
+func NewReloader(l *logrus.Logger, cfg config.TLS) (*Reloader, error) {
+	version, ok := versions[cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS version %q", cfg.MinVersion)
+	}
+	clientAuth, ok := clientAuths[cfg.ClientAuth]
+	if !ok {
+		return nil, fmt.Errorf("unknown client authentication %q", cfg.ClientAuth)
+	}
+	suites, err := ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reloader{
+		Logger:       l,
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		ClientCAFile: cfg.ClientCAFile,
+		Interval:     pollInterval,
+		base: &tls.Config{
+			MinVersion:   version,
+			CipherSuites: suites,
+			ClientAuth:   clientAuth,
+			NextProtos:   []string{"h2", "http/1.1"},
+		},
+	}
+	if clientAuth == tls.NoClientCert {
+		r.ClientCAFile = ""
+	}
+	return r, r.Reload()
+}
+
+// TLSConfig returns the settings of a server, handing the certificates
+// loaded last to every new connection.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.base.MinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+	}
+}
+
+// Reload reads the files again. The certificates loaded before are kept
+// when they cannot be read, until the files change again.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Stamped first, for a change while reading to be seen by the next check
+	r.stamps = r.stampFiles()
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+	cfg := r.base.Clone()
+	cfg.Certificates = []tls.Certificate{cert}
+	if r.ClientCAFile != "" {
+		b, err := os.ReadFile(r.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("loading client CA: %w", err)
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(b) {
+			return errors.New("loading client CA: no PEM encoded certificate found")
+		}
+	}
+	r.current.Store(cfg)
+	return nil
+}
+
+func (r *Reloader) stampFiles() map[string]stamp {
+	stamps := map[string]stamp{}
+	for _, path := range []string{r.CertFile, r.KeyFile, r.ClientCAFile} {
+		// Stat follows symbolic links, such as the ones of mounted secrets
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = stamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+// Reports whether a file changed since the last reload
+func (r *Reloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stamps := r.stampFiles()
+	if len(stamps) != len(r.stamps) {
+		return true
+	}
+	for path, s := range stamps {
+		if r.stamps[path] != s {
+			return true
+		}
+	}
+	return false
+}
+
+// Run reloads the certificates on SIGHUP, and when their files change,
+// until ctx is done.
+func (r *Reloader) Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			r.reload("on SIGHUP")
+		case <-ticker.C:
+			if r.changed() {
+				r.reload("on file change")
+			}
+		}
+	}
+}
+
+func (r *Reloader) reload(reason string) {
+	if err := r.Reload(); err != nil {
+		r.Logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+			"event": "previous certificates kept",
+		}).Error("Failed to reload TLS certificates")
+		return
+	}
+	r.Logger.WithField("event", reason).Info("TLS certificates reloaded")
+}
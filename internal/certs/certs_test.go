@@ -0,0 +1,210 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// A certificate and its key, signed by parent or self-signed
+type keyPair struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newKeyPair(t *testing.T, subject pkix.Name, serial int64, parent *keyPair) *keyPair {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      subject,
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &keyPair{cert: cert, key: key}
+}
+
+func (p *keyPair) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.cert.Raw})
+}
+
+// Writes the certificate and key of p to certFile and keyFile
+func (p *keyPair) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, p.certPEM(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (p *keyPair) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{p.cert.Raw}, PrivateKey: p.key}
+}
+
+func servedSerial(r *Reloader) int64 {
+	leaf, _ := x509.ParseCertificate(r.current.Load().Certificates[0].Certificate[0])
+	return leaf.SerialNumber.Int64()
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "crm.pem"), filepath.Join(dir, "crm.key")
+	ca := newKeyPair(t, pkix.Name{CommonName: "CA"}, 1, nil)
+	newKeyPair(t, pkix.Name{CommonName: "localhost"}, 2, ca).write(t, certFile, keyFile)
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	r, err := NewReloader(l, config.TLS{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2", ClientAuth: "none"})
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	if r.changed() || servedSerial(r) != 2 {
+		t.Fatalf("serving serial %d, changed %v", servedSerial(r), r.changed())
+	}
+
+	// Files may keep their modification time when rewritten within the same tick
+	renewed := newKeyPair(t, pkix.Name{CommonName: "localhost"}, 3, ca)
+	renewed.write(t, certFile, keyFile)
+	os.Chtimes(certFile, time.Now(), time.Now().Add(time.Minute))
+	if !r.changed() {
+		t.Fatal("renewed certificate not seen as a change")
+	}
+	if err := r.Reload(); err != nil || servedSerial(r) != 3 {
+		t.Fatalf("Reload() error = %v, serving serial %d, want 3", err, servedSerial(r))
+	}
+
+	os.WriteFile(certFile, []byte("not a certificate"), 0o600)
+	if err := r.Reload(); err == nil || servedSerial(r) != 3 {
+		t.Errorf("Reload() error = %v, serving serial %d, want the previous certificate", err, servedSerial(r))
+	}
+	if r.changed() {
+		t.Error("failed reload retried before the files change again")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "crm.pem"), filepath.Join(dir, "crm.key"), filepath.Join(dir, "clients.pem")
+	ca := newKeyPair(t, pkix.Name{CommonName: "CA"}, 1, nil)
+	newKeyPair(t, pkix.Name{CommonName: "localhost"}, 2, ca).write(t, certFile, keyFile)
+	clientCA := newKeyPair(t, pkix.Name{CommonName: "Clients CA"}, 3, nil)
+	os.WriteFile(caFile, clientCA.certPEM(), 0o600)
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	r, err := NewReloader(l, config.TLS{
+		CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile,
+		MinVersion: "1.2", ClientAuth: "optional", CipherSuites: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	})
+	if err != nil {
+		t.Fatalf("NewReloader() error = %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) > 0 {
+			io.WriteString(w, r.TLS.VerifiedChains[0][0].Subject.String())
+		}
+	}))
+	srv.TLS = r.TLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	tests := []struct {
+		name    string
+		client  *keyPair
+		want    string
+		wantErr bool
+	}{
+		{"without certificate", nil, "", false},
+		{"trusted certificate", newKeyPair(t, pkix.Name{CommonName: "billing", Organization: []string{"Acme"}}, 4, clientCA), "CN=billing,O=Acme", false},
+		{"untrusted certificate", newKeyPair(t, pkix.Name{CommonName: "billing"}, 5, ca), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &tls.Config{RootCAs: roots, ServerName: "localhost", MaxVersion: tls.VersionTLS12}
+			if tt.client != nil {
+				// Sent even when not signed by a CA the server accepts
+				cert := tt.client.tlsCertificate()
+				cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return &cert, nil }
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+			resp, err := client.Get(srv.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			if string(b) != tt.want || resp.TLS.CipherSuite != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+				t.Errorf("subject %q with suite %s, want %q", b, tls.CipherSuiteName(resp.TLS.CipherSuite), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []uint16
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, false},
+		{"TLS_RSA_WITH_RC4_128_SHA", nil, true},
+		{"AES128", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCipherSuites(tt.in)
+		if (err != nil) != tt.wantErr || len(got) != len(tt.want) {
+			t.Errorf("ParseCipherSuites(%q) = %v, %v", tt.in, got, err)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseCipherSuites(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,621 @@
+// Package config loads the configuration of the server from a YAML or
+// TOML file, environment variables and command line flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ProviderInMemory string = "in-memory"
+	ProviderPostgres string = "psql"
+)
+
+// Secret is a configuration value never printed.
+type Secret string
+
+const redacted = "<redacted>"
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+// SecretURLs are URLs printed with their passwords redacted.
+type SecretURLs []string
+
+func (u SecretURLs) MarshalYAML() (any, error) {
+	out := make([]string, len(u))
+	for i, raw := range u {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			out[i] = redacted
+			continue
+		}
+		out[i] = parsed.Redacted()
+	}
+	return out, nil
+}
+
+type Config struct {
+	Server      Server      `yaml:"server" toml:"server"`
+	Database    Database    `yaml:"database" toml:"database"`
+	Auth        Auth        `yaml:"auth" toml:"auth"`
+	Tenancy     Tenancy     `yaml:"tenancy" toml:"tenancy"`
+	Cache       Cache       `yaml:"cache" toml:"cache"`
+	Trash       Trash       `yaml:"trash" toml:"trash"`
+	Assignment  Assignment  `yaml:"assignment" toml:"assignment"`
+	Outbox      Outbox      `yaml:"outbox" toml:"outbox"`
+	RateLimit   RateLimit   `yaml:"rate_limit" toml:"rate_limit"`
+	Idempotency Idempotency `yaml:"idempotency" toml:"idempotency"`
+	Logging     Logging     `yaml:"logging" toml:"logging"`
+	Admin       Admin       `yaml:"admin" toml:"admin"`
+	TLS         TLS         `yaml:"tls" toml:"tls"`
+}
+
+type Server struct {
+	Port              int           `yaml:"port" toml:"port"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	// WriteTimeout does not apply to change streams
+	WriteTimeout   time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	MaxHeaderBytes int           `yaml:"max_header_bytes" toml:"max_header_bytes"`
+	// ShutdownTimeout bounds the draining of in-flight requests and
+	// background workers on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+type Database struct {
+	Provider string `yaml:"provider" toml:"provider"`
+	// URL is a postgres:// connection URL, its parts override the
+	// settings below
+	URL      Secret `yaml:"url" toml:"url"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password Secret `yaml:"password" toml:"password"`
+	// PasswordFile holds the password, for secrets mounted as files
+	PasswordFile string `yaml:"password_file" toml:"password_file"`
+	Name         string `yaml:"name" toml:"name"`
+
+	// SSLMode is one of disable, require, verify-ca and verify-full
+	SSLMode     string `yaml:"sslmode" toml:"sslmode"`
+	SSLRootCert string `yaml:"sslrootcert" toml:"sslrootcert"`
+	SSLCert     string `yaml:"sslcert" toml:"sslcert"`
+	SSLKey      string `yaml:"sslkey" toml:"sslkey"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+	// ConnectTimeout bounds the retries of the first connection on startup
+	ConnectTimeout time.Duration `yaml:"connect_timeout" toml:"connect_timeout"`
+
+	// Replicas are postgres:// URLs of read replicas, the parts they leave
+	// out are the ones of the primary
+	Replicas SecretURLs `yaml:"replicas" toml:"replicas"`
+	// MaxReplicaLag evicts the replicas replaying changes later than it
+	MaxReplicaLag time.Duration `yaml:"max_replica_lag" toml:"max_replica_lag"`
+	// ReplicaCheckInterval is how often the health and lag of replicas
+	// are checked
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" toml:"replica_check_interval"`
+}
+
+// SSL modes supported by the psql provider
+var sslModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+type Auth struct {
+	Mode      string `yaml:"mode" toml:"mode"`
+	UsersFile string `yaml:"users_file" toml:"users_file"`
+}
+
+// Tenancy tells how the tenant of requests is resolved, Domain being the
+// parent domain of the tenants in the subdomain mode.
+type Tenancy struct {
+	Mode   string `yaml:"mode" toml:"mode"`
+	Domain string `yaml:"domain" toml:"domain"`
+}
+
+// Cache sizes the cache put in front of the repository, a zero Size
+// disables it.
+type Cache struct {
+	Size int           `yaml:"size" toml:"size"`
+	TTL  time.Duration `yaml:"ttl" toml:"ttl"`
+}
+
+type Trash struct {
+	Retention time.Duration `yaml:"retention" toml:"retention"`
+}
+
+type Assignment struct {
+	RulesFile string `yaml:"rules_file" toml:"rules_file"`
+}
+
+type Outbox struct {
+	Sinks string `yaml:"sinks" toml:"sinks"`
+}
+
+// RateLimit bounds the requests of every client. Limits read like 20/s or
+// 600/m, optionally followed by the burst as in 20/s:40, and are unlimited
+// when empty.
+type RateLimit struct {
+	Limit string `yaml:"limit" toml:"limit"`
+	// Routes are comma separated limits of given routes, overriding Limit,
+	// such as "POST /api/customers=5/s"
+	Routes string `yaml:"routes" toml:"routes"`
+	// DailyQuota is the number of requests of a client per UTC day, zero
+	// being unlimited
+	DailyQuota int `yaml:"daily_quota" toml:"daily_quota"`
+	// TrustProxy identifies clients by the last X-Forwarded-For address
+	TrustProxy bool `yaml:"trust_proxy" toml:"trust_proxy"`
+}
+
+// Idempotency keeps the responses to the requests sent with an
+// Idempotency-Key for TTL.
+type Idempotency struct {
+	TTL time.Duration `yaml:"ttl" toml:"ttl"`
+}
+
+// Logging tells how the e-mail addresses and phone numbers in logs are
+// redacted: masked, replaced by a hash to keep them correlatable, or left
+// as is with none.
+type Logging struct {
+	Redaction string `yaml:"redaction" toml:"redaction"`
+}
+
+// Admin is the listener of the diagnostics and maintenance routes, kept off
+// the public port. An empty Addr disables it.
+type Admin struct {
+	// Addr is a host:port such as localhost:9090
+	Addr string `yaml:"addr" toml:"addr"`
+}
+
+// TLS serves the public port over HTTPS when CertFile and KeyFile are set,
+// both reloaded on SIGHUP or when they change.
+type TLS struct {
+	CertFile string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `yaml:"key_file" toml:"key_file"`
+	// MinVersion is 1.2 or 1.3
+	MinVersion string `yaml:"min_version" toml:"min_version"`
+	// CipherSuites are comma separated names of TLS 1.2 suites, such as
+	// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, empty for Go's defaults
+	CipherSuites string `yaml:"cipher_suites" toml:"cipher_suites"`
+
+	// ClientAuth is none, optional or require. Clients presenting a
+	// certificate signed by ClientCAFile are authenticated as the user its
+	// subject maps to in ClientsFile.
+	ClientAuth   string `yaml:"client_auth" toml:"client_auth"`
+	ClientCAFile string `yaml:"client_ca_file" toml:"client_ca_file"`
+	ClientsFile  string `yaml:"clients_file" toml:"clients_file"`
+}
+
+// Default returns the configuration used for the settings left unset.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:              3000,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: Database{
+			Provider: ProviderInMemory,
+			Host:     "localhost",
+			Port:     5432,
+			User:     "crm_app",
+			Name:     "customers",
+			SSLMode:  "disable",
+
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectTimeout:  30 * time.Second,
+
+			MaxReplicaLag:        10 * time.Second,
+			ReplicaCheckInterval: 5 * time.Second,
+		},
+		Auth:    Auth{Mode: "none"},
+		Tenancy: Tenancy{Mode: "none"},
+		Cache:   Cache{TTL: time.Minute},
+		Trash:   Trash{Retention: 30 * 24 * time.Hour},
+
+		Idempotency: Idempotency{TTL: 24 * time.Hour},
+		Logging:     Logging{Redaction: "mask"},
+		TLS:         TLS{MinVersion: "1.2", ClientAuth: "none"},
+	}
+}
+
+// A setting of Config, settable from the environment and the command line
+type setting struct {
+	key   string
+	env   string
+	flag  string
+	usage string
+	// Pointer to the field of the setting
+	value any
+}
+
+// Names the sources of a setting in error messages
+func (s setting) String() string {
+	if s.flag == "" {
+		return fmt.Sprintf("%s (%s)", s.key, s.env)
+	}
+	return fmt.Sprintf("%s (-%s, %s)", s.key, s.flag, s.env)
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"server.port", "CRM_PORT", "port", "Server port", &c.Server.Port},
+		{"server.read_timeout", "CRM_READ_TIMEOUT", "read-timeout", "Maximum duration for reading a request, body included", &c.Server.ReadTimeout},
+		{"server.read_header_timeout", "CRM_READ_HEADER_TIMEOUT", "read-header-timeout", "Maximum duration for reading request headers", &c.Server.ReadHeaderTimeout},
+		{"server.write_timeout", "CRM_WRITE_TIMEOUT", "write-timeout", "Maximum duration for writing a response, change streams excepted", &c.Server.WriteTimeout},
+		{"server.idle_timeout", "CRM_IDLE_TIMEOUT", "idle-timeout", "How long idle keep-alive connections are kept open", &c.Server.IdleTimeout},
+		{"server.max_header_bytes", "CRM_MAX_HEADER_BYTES", "max-header-bytes", "Maximum size of request headers", &c.Server.MaxHeaderBytes},
+		{"server.shutdown_timeout", "CRM_SHUTDOWN_TIMEOUT", "shutdown-timeout", "How long in-flight requests and background workers are waited for on shutdown", &c.Server.ShutdownTimeout},
+		{"database.provider", "CRM_DB_PROVIDER", "db", "DB provider: in-memory|psql", &c.Database.Provider},
+		// URLs usually embed the password
+		{"database.url", "DATABASE_URL", "", "", &c.Database.URL},
+		{"database.host", "DB_HOST", "db-host", "Hostname or IP address of the database server", &c.Database.Host},
+		{"database.port", "DB_PORT", "db-port", "Port of the database server", &c.Database.Port},
+		{"database.user", "DB_USER", "db-user", "Database user", &c.Database.User},
+		// Passwords are kept off the command line, where any user can read them
+		{"database.password", "DB_PASSWORD", "", "", &c.Database.Password},
+		{"database.password_file", "DB_PASSWORD_FILE", "db-password-file", "File holding the database password", &c.Database.PasswordFile},
+		{"database.name", "DB_NAME", "db-name", "Database name", &c.Database.Name},
+		{"database.sslmode", "DB_SSLMODE", "db-sslmode", "TLS mode: disable|require|verify-ca|verify-full", &c.Database.SSLMode},
+		{"database.sslrootcert", "DB_SSLROOTCERT", "db-sslrootcert", "CA certificate file verifying the database server", &c.Database.SSLRootCert},
+		{"database.sslcert", "DB_SSLCERT", "db-sslcert", "Client certificate file", &c.Database.SSLCert},
+		{"database.sslkey", "DB_SSLKEY", "db-sslkey", "Client private key file", &c.Database.SSLKey},
+		{"database.max_open_conns", "DB_MAX_OPEN_CONNS", "db-max-open-conns", "Maximum number of open database connections, 0 is unlimited", &c.Database.MaxOpenConns},
+		{"database.max_idle_conns", "DB_MAX_IDLE_CONNS", "db-max-idle-conns", "Maximum number of idle database connections", &c.Database.MaxIdleConns},
+		{"database.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "How long a database connection is reused, 0 is forever", &c.Database.ConnMaxLifetime},
+		{"database.conn_max_idle_time", "DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "How long a database connection stays idle, 0 is forever", &c.Database.ConnMaxIdleTime},
+		{"database.connect_timeout", "DB_CONNECT_TIMEOUT", "db-connect-timeout", "How long the database is waited for on startup", &c.Database.ConnectTimeout},
+		{"database.replicas", "DB_REPLICAS", "db-replicas", "Comma separated postgres:// URLs of read replicas, inheriting the missing parts from the primary", &c.Database.Replicas},
+		{"database.max_replica_lag", "DB_MAX_REPLICA_LAG", "db-max-replica-lag", "Replication lag beyond which a replica stops serving reads", &c.Database.MaxReplicaLag},
+		{"database.replica_check_interval", "DB_REPLICA_CHECK_INTERVAL", "db-replica-check-interval", "How often the health and lag of replicas are checked", &c.Database.ReplicaCheckInterval},
+		{"auth.mode", "CRM_AUTH_MODE", "auth", "Authentication mode: none|header|token", &c.Auth.Mode},
+		{"auth.users_file", "AUTH_USERS_FILE", "auth-users-file", "JSON file of the users and their tokens, for the token mode", &c.Auth.UsersFile},
+		{"tenancy.mode", "CRM_TENANCY_MODE", "tenancy", "Tenant resolution: none|header|subdomain|claim", &c.Tenancy.Mode},
+		{"tenancy.domain", "CRM_TENANCY_DOMAIN", "tenancy-domain", "Parent domain of the tenant subdomains, for the subdomain mode", &c.Tenancy.Domain},
+		{"cache.size", "CRM_CACHE_SIZE", "cache-size", "Number of customers cached by id, 0 disables the cache", &c.Cache.Size},
+		{"cache.ttl", "CRM_CACHE_TTL", "cache-ttl", "How long a customer stays cached", &c.Cache.TTL},
+		{"trash.retention", "CRM_TRASH_RETENTION", "trash-retention", "How long deleted customers stay in the trash", &c.Trash.Retention},
+		{"assignment.rules_file", "ASSIGNMENT_RULES_FILE", "assignment-rules-file", "JSON file of the auto-assignment rules", &c.Assignment.RulesFile},
+		{"outbox.sinks", "OUTBOX_SINKS", "outbox-sinks", "Comma separated event sinks: log, http=<url>", &c.Outbox.Sinks},
+		{"rate_limit.limit", "CRM_RATE_LIMIT", "rate-limit", "Requests allowed per client, such as 20/s or 600/m:100 with a burst, empty for unlimited", &c.RateLimit.Limit},
+		{"rate_limit.routes", "CRM_RATE_LIMIT_ROUTES", "rate-limit-routes", "Comma separated limits of given routes, such as POST /api/customers=5/s", &c.RateLimit.Routes},
+		{"rate_limit.daily_quota", "CRM_DAILY_QUOTA", "daily-quota", "Requests allowed per client and UTC day, 0 for unlimited", &c.RateLimit.DailyQuota},
+		{"rate_limit.trust_proxy", "CRM_TRUST_PROXY", "trust-proxy", "Identify anonymous clients by the last X-Forwarded-For address", &c.RateLimit.TrustProxy},
+		{"idempotency.ttl", "CRM_IDEMPOTENCY_TTL", "idempotency-ttl", "How long responses are replayed to the requests retried with the same Idempotency-Key", &c.Idempotency.TTL},
+		{"logging.redaction", "CRM_LOG_REDACTION", "log-redaction", "Redaction of e-mail addresses and phone numbers in logs: mask|hash|none", &c.Logging.Redaction},
+		{"tls.cert_file", "CRM_TLS_CERT", "tls-cert", "Certificate file, PEM encoded with its intermediates, serving HTTPS", &c.TLS.CertFile},
+		{"tls.key_file", "CRM_TLS_KEY", "tls-key", "Private key file of the certificate", &c.TLS.KeyFile},
+		{"tls.min_version", "CRM_TLS_MIN_VERSION", "tls-min-version", "Minimum TLS version: 1.2|1.3", &c.TLS.MinVersion},
+		{"tls.cipher_suites", "CRM_TLS_CIPHER_SUITES", "tls-cipher-suites", "Comma separated TLS 1.2 cipher suites, empty for Go's defaults", &c.TLS.CipherSuites},
+		{"tls.client_auth", "CRM_TLS_CLIENT_AUTH", "tls-client-auth", "Client certificate authentication: none|optional|require", &c.TLS.ClientAuth},
+		{"tls.client_ca_file", "CRM_TLS_CLIENT_CA", "tls-client-ca", "CA certificates file verifying client certificates", &c.TLS.ClientCAFile},
+		{"tls.clients_file", "CRM_TLS_CLIENTS_FILE", "tls-clients-file", "JSON file of the users of client certificates, by subject", &c.TLS.ClientsFile},
+		{"admin.addr", "CRM_ADMIN_ADDR", "admin-addr", "Address of the admin listener serving pprof, metrics and maintenance routes, such as localhost:9090, empty to disable it", &c.Admin.Addr},
+	}
+}
+
+// Parses raw into the field value points to
+func set(value any, raw string) error {
+	switch v := value.(type) {
+	case *string:
+		*v = raw
+	case *Secret:
+		*v = Secret(raw)
+	case *SecretURLs:
+		*v = nil
+		for _, u := range strings.Split(raw, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				*v = append(*v, u)
+			}
+		}
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		*v = n
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		*v = b
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 1h", raw)
+		}
+		*v = d
+	default:
+		panic(fmt.Sprintf("unsupported setting type %T", value))
+	}
+	return nil
+}
+
+// Load returns the configuration given by, in increasing precedence, the
+// defaults, the file named by -config or CRM_CONFIG_FILE, the environment
+// and the command line flags in args. The password is read from its file
+// when one is set. The configuration is validated.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	c := Default()
+	settings := c.settings()
+
+	fs := flag.NewFlagSet("crm", flag.ContinueOnError)
+	path := fs.String("config", getenv("CRM_CONFIG_FILE"), "YAML or TOML configuration file")
+	flags := map[string]string{}
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		fs.Func(s.flag, fmt.Sprintf("%s (%s, default %v)", s.usage, s.env, display(s.value)), func(raw string) error {
+			flags[s.flag] = raw
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	if fs.NArg() > 0 {
+		return c, fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+
+	if *path != "" {
+		if err := c.loadFile(*path); err != nil {
+			return c, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if raw := getenv(s.env); raw != "" {
+			if err := set(s.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s, err))
+			}
+		}
+		if raw, ok := flags[s.flag]; ok {
+			if err := set(s.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return c, errors.Join(errs...)
+	}
+
+	if c.Database.PasswordFile != "" {
+		if c.Database.Password != "" {
+			return c, errors.New("database.password (DB_PASSWORD) and database.password_file (DB_PASSWORD_FILE) are both set, keep only one")
+		}
+		b, err := os.ReadFile(c.Database.PasswordFile)
+		if err != nil {
+			return c, fmt.Errorf("database.password_file: %w", err)
+		}
+		c.Database.Password = Secret(strings.TrimRight(string(b), "\r\n"))
+	}
+	return c, c.Validate()
+}
+
+// Formats the default value of a setting for the usage message
+func display(value any) string {
+	switch v := value.(type) {
+	case *string:
+		return strconv.Quote(*v)
+	case *int:
+		return strconv.Itoa(*v)
+	case *bool:
+		return strconv.FormatBool(*v)
+	case *time.Duration:
+		return v.String()
+	case *SecretURLs:
+		return strconv.Quote(strings.Join(*v, ","))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Decodes the file at path over c, by extension. Unknown keys are errors.
+func (c *Config) loadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading configuration file: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(b)))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(b), c)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("parsing %s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("configuration file %s: unknown format %q, use .yaml, .yml or .toml", path, ext)
+	}
+	return nil
+}
+
+// Validate checks every setting and reports all the invalid ones.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", c.describe(key), fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(c.Server.Port >= 0 && c.Server.Port <= 65535, "server.port", "%d is not a valid port, use 0-65535", c.Server.Port)
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"trash.retention", c.Trash.Retention},
+		{"idempotency.ttl", c.Idempotency.TTL},
+	} {
+		check(d.value > 0, d.key, "%v must be positive", d.value)
+	}
+	check(c.Server.MaxHeaderBytes >= 4096, "server.max_header_bytes", "%d is too small to hold common headers, use at least 4096", c.Server.MaxHeaderBytes)
+
+	check(c.Database.Provider == ProviderInMemory || c.Database.Provider == ProviderPostgres,
+		"database.provider", "unknown provider %q, use %s or %s", c.Database.Provider, ProviderInMemory, ProviderPostgres)
+	if c.Database.Provider == ProviderPostgres {
+		c.validateDatabase(check)
+	}
+
+	check(c.Auth.Mode == "none" || c.Auth.Mode == "header" || c.Auth.Mode == "token",
+		"auth.mode", "unknown mode %q, use none, header or token", c.Auth.Mode)
+	if c.Auth.Mode == "token" {
+		check(c.Auth.UsersFile != "", "auth.users_file", "required by the token mode")
+	}
+
+	switch c.Tenancy.Mode {
+	case "none", "header", "claim":
+	case "subdomain":
+		check(c.Tenancy.Domain != "", "tenancy.domain", "required by the subdomain mode")
+	default:
+		check(false, "tenancy.mode", "unknown mode %q, use none, header, subdomain or claim", c.Tenancy.Mode)
+	}
+	if c.Tenancy.Mode == "claim" {
+		check(c.Auth.Mode == "token", "tenancy.mode", "the claim mode reads tenants from tokens, use the token auth mode")
+	}
+
+	check(c.Cache.Size >= 0, "cache.size", "%d must not be negative", c.Cache.Size)
+	if c.Cache.Size > 0 {
+		check(c.Cache.TTL > 0, "cache.ttl", "%v must be positive when the cache is enabled", c.Cache.TTL)
+	}
+	check(c.RateLimit.DailyQuota >= 0, "rate_limit.daily_quota", "%d must not be negative", c.RateLimit.DailyQuota)
+	check(c.Logging.Redaction == "mask" || c.Logging.Redaction == "hash" || c.Logging.Redaction == "none",
+		"logging.redaction", "unknown policy %q, use mask, hash or none", c.Logging.Redaction)
+	c.validateTLS(check)
+	if c.Admin.Addr != "" {
+		_, port, err := net.SplitHostPort(c.Admin.Addr)
+		check(err == nil && port != strconv.Itoa(c.Server.Port), "admin.addr", "%q is not a host:port address other than the public port", c.Admin.Addr)
+	}
+	return errors.Join(errs...)
+}
+
+// Checks the settings of HTTPS and client certificates
+func (c Config) validateTLS(check func(ok bool, key, format string, args ...any)) {
+	t := c.TLS
+	check(t.KeyFile != "" || t.CertFile == "", "tls.key_file", "required with tls.cert_file")
+	check(t.CertFile != "" || t.KeyFile == "", "tls.cert_file", "required with tls.key_file")
+	check(t.MinVersion == "1.2" || t.MinVersion == "1.3", "tls.min_version", "unsupported version %q, use 1.2 or 1.3", t.MinVersion)
+	check(t.CipherSuites == "" || t.MinVersion != "1.3", "tls.cipher_suites", "TLS 1.3 suites are not configurable, use 1.2 as minimum version")
+	switch t.ClientAuth {
+	case "none":
+	case "optional", "require":
+		check(t.CertFile != "", "tls.client_auth", "client certificates require HTTPS, set tls.cert_file")
+		check(t.ClientCAFile != "", "tls.client_ca_file", "required to verify client certificates")
+		check(t.ClientsFile != "", "tls.clients_file", "required to map client certificates to users")
+	default:
+		check(false, "tls.client_auth", "unknown mode %q, use none, optional or require", t.ClientAuth)
+	}
+}
+
+// Checks the settings of the psql provider
+func (c Config) validateDatabase(check func(ok bool, key, format string, args ...any)) {
+	db := c.Database
+	if db.URL != "" {
+		u, err := url.Parse(string(db.URL))
+		// The error would print the password
+		check(err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql"),
+			"database.url", "not a postgres:// or postgresql:// URL")
+	} else {
+		check(db.Host != "", "database.host", "required by the %s provider", ProviderPostgres)
+		check(db.User != "", "database.user", "required by the %s provider", ProviderPostgres)
+		check(db.Name != "", "database.name", "required by the %s provider", ProviderPostgres)
+	}
+	check(db.Port > 0 && db.Port <= 65535, "database.port", "%d is not a valid port, use 1-65535", db.Port)
+
+	check(slices.Contains(sslModes, db.SSLMode), "database.sslmode", "unknown mode %q, use %s", db.SSLMode, strings.Join(sslModes, ", "))
+	check((db.SSLCert == "") == (db.SSLKey == ""), "database.sslkey", "the client certificate and key are set together")
+	for _, f := range []struct{ key, path string }{
+		{"database.sslrootcert", db.SSLRootCert},
+		{"database.sslcert", db.SSLCert},
+		{"database.sslkey", db.SSLKey},
+	} {
+		if f.path != "" {
+			_, err := os.Stat(f.path)
+			check(err == nil, f.key, "%v", err)
+		}
+	}
+
+	check(db.MaxOpenConns >= 0, "database.max_open_conns", "%d must not be negative", db.MaxOpenConns)
+	check(db.MaxIdleConns >= 0, "database.max_idle_conns", "%d must not be negative", db.MaxIdleConns)
+	if db.MaxOpenConns > 0 {
+		check(db.MaxIdleConns <= db.MaxOpenConns, "database.max_idle_conns",
+			"%d exceeds database.max_open_conns (%d)", db.MaxIdleConns, db.MaxOpenConns)
+	}
+	check(db.ConnMaxLifetime >= 0, "database.conn_max_lifetime", "%v must not be negative", db.ConnMaxLifetime)
+	check(db.ConnMaxIdleTime >= 0, "database.conn_max_idle_time", "%v must not be negative", db.ConnMaxIdleTime)
+	check(db.ConnectTimeout > 0, "database.connect_timeout", "%v must be positive", db.ConnectTimeout)
+
+	for i, replica := range db.Replicas {
+		u, err := url.Parse(replica)
+		check(err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql"),
+			"database.replicas", "replica %d is not a postgres:// or postgresql:// URL", i+1)
+	}
+	if len(db.Replicas) > 0 {
+		check(db.MaxReplicaLag > 0, "database.max_replica_lag", "%v must be positive", db.MaxReplicaLag)
+		check(db.ReplicaCheckInterval > 0, "database.replica_check_interval", "%v must be positive", db.ReplicaCheckInterval)
+	}
+}
+
+// Names the sources of the setting key
+func (c Config) describe(key string) string {
+	for _, s := range c.settings() {
+		if s.key == key {
+			return s.String()
+		}
+	}
+	return key
+}
+
+// Print writes c as YAML, secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(c)
+}
@@ -0,0 +1,40 @@
+// Package consistency flags the requests whose reads must observe their
+// own writes, which read replicas may not have replayed yet.
+package consistency
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	Header string = "X-Consistency"
+	// ReadYourWritesValue of Header sends the reads of a request to the
+	// primary
+	ReadYourWritesValue string = "read-your-writes"
+)
+
+type key struct{}
+
+// WithReadYourWrites returns a copy of ctx whose reads go to the primary.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, key{}, true)
+}
+
+// ReadYourWrites reports whether ctx was flagged by WithReadYourWrites.
+func ReadYourWrites(ctx context.Context) bool {
+	flagged, _ := ctx.Value(key{}).(bool)
+	return flagged
+}
+
+// Middleware flags the requests changing data, which read what they are
+// about to change, and those asking for it with Header.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+		if !safe || r.Header.Get(Header) == ReadYourWritesValue {
+			r = r.WithContext(WithReadYourWrites(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,207 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Capacity of a subscription channel before it is considered too slow
+const subscriptionBuffer = 64
+
+// Bus fans events out to in-process consumers. Every event is numbered
+// and the last ones are kept so subscribers can resume after a
+// disconnection.
+type Bus struct {
+	mu          sync.Mutex
+	seq         uint64
+	replay      []Event
+	replaySize  int
+	handlers    []*handlerQueue
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription delivers events asynchronously on C. C is closed when the
+// subscription is closed or when the subscriber falls too far behind.
+type Subscription struct {
+	C   <-chan Event
+	c   chan Event
+	bus *Bus
+}
+
+// NewBus returns a Bus keeping the last replaySize events.
+func NewBus(replaySize int) *Bus {
+	return &Bus{replaySize: replaySize, subscribers: map[*Subscription]struct{}{}}
+}
+
+// Handle registers a consumer called, in publication order, for every
+// event. Each handler consumes its own queue on its own goroutine, so that
+// a slow handler holds up neither the publishers nor the other handlers.
+// Handlers are never dropped.
+func (b *Bus) Handle(p Publisher) {
+	q := &handlerQueue{p: p, ready: make(chan struct{}, 1), done: make(chan struct{})}
+	go q.run()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, q)
+}
+
+// Publish numbers e, keeps it for replay and hands it to every handler
+// and subscriber without waiting for them.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.Seq = b.seq
+	b.replay = append(b.replay, e)
+	if len(b.replay) > b.replaySize {
+		b.replay = b.replay[len(b.replay)-b.replaySize:]
+	}
+	for s := range b.subscribers {
+		select {
+		case s.c <- e:
+		default:
+			// Slow subscribers resume from the replay buffer
+			b.unsubscribe(s)
+		}
+	}
+	// Handlers outlive the request publishing the event
+	ctx = context.WithoutCancel(ctx)
+	for _, q := range b.handlers {
+		q.push(ctx, e)
+	}
+}
+
+// Close waits for the handlers to consume the events queued so far, until
+// ctx is done. The events published afterwards are handled synchronously.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	handlers := b.handlers
+	b.mu.Unlock()
+
+	for _, q := range handlers {
+		q.close()
+	}
+	for _, q := range handlers {
+		select {
+		case <-q.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a subscription to the events published from now on,
+// along with the buffered events numbered after lastSeq. A zero lastSeq
+// replays nothing.
+func (b *Bus) Subscribe(lastSeq uint64) (*Subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	// A sequence ahead of the bus was issued before a restart
+	if lastSeq > 0 && lastSeq < b.seq {
+		for _, e := range b.replay {
+			if e.Seq > lastSeq {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	c := make(chan Event, subscriptionBuffer)
+	s := &Subscription{C: c, c: c, bus: b}
+	b.subscribers[s] = struct{}{}
+	return s, missed
+}
+
+// Close stops the delivery of events to s.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.unsubscribe(s)
+}
+
+// CloseSubscriptions closes every subscription, ending the streams
+// consuming them.
+func (b *Bus) CloseSubscriptions() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers {
+		b.unsubscribe(s)
+	}
+}
+
+// Removes s, b.mu must be held
+func (b *Bus) unsubscribe(s *Subscription) {
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.c)
+	}
+}
+
+// Events waiting for a handler, unbounded so that publishing never blocks
+type handlerQueue struct {
+	p      Publisher
+	mu     sync.Mutex
+	queued []queuedEvent
+	closed bool
+	// ready is signaled when events are queued or the queue is closed
+	ready chan struct{}
+	// done is closed once the queue is closed and drained
+	done chan struct{}
+}
+
+type queuedEvent struct {
+	ctx context.Context
+	e   Event
+}
+
+func (q *handlerQueue) push(ctx context.Context, e Event) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		q.p.Publish(ctx, e)
+		return
+	}
+	q.queued = append(q.queued, queuedEvent{ctx, e})
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *handlerQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *handlerQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Hands the queued events to the handler until the queue is closed and
+// drained
+func (q *handlerQueue) run() {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		batch, closed := q.queued, q.closed
+		q.queued = nil
+		q.mu.Unlock()
+
+		for _, item := range batch {
+			q.p.Publish(item.ctx, item.e)
+		}
+		if len(batch) == 0 {
+			if closed {
+				return
+			}
+			<-q.ready
+		}
+	}
+}
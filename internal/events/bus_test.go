@@ -0,0 +1,162 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type recorder []Event
+
+func (r *recorder) Publish(ctx context.Context, e Event) {
+	*r = append(*r, e)
+}
+
+func TestBusReplay(t *testing.T) {
+	bus := NewBus(3)
+	for i := 0; i < 5; i++ {
+		bus.Publish(context.Background(), Event{ID: uuid.New(), Type: CustomerUpdated})
+	}
+
+	tests := []struct {
+		name    string
+		lastSeq uint64
+		want    []uint64
+	}{
+		{"Fresh_connection", 0, nil},
+		{"Resumes_within_buffer", 3, []uint64{4, 5}},
+		{"Resumes_past_buffer", 1, []uint64{3, 4, 5}},
+		{"Up_to_date", 5, nil},
+		{"Sequence_from_before_restart", 42, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, missed := bus.Subscribe(tt.lastSeq)
+			defer sub.Close()
+
+			if len(missed) != len(tt.want) {
+				t.Fatalf("Replayed %v events, want %v", len(missed), len(tt.want))
+			}
+			for i, e := range missed {
+				if e.Seq != tt.want[i] {
+					t.Errorf("Replayed event %d seq=%v, want %v", i, e.Seq, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBusFanOut(t *testing.T) {
+	bus := NewBus(10)
+	var handled recorder
+	bus.Handle(&handled)
+
+	sub, _ := bus.Subscribe(0)
+	slow, _ := bus.Subscribe(0)
+
+	for i := 0; i < subscriptionBuffer+1; i++ {
+		bus.Publish(context.Background(), Event{ID: uuid.New(), Type: CustomerCreated})
+		<-sub.C
+	}
+
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(handled) != subscriptionBuffer+1 {
+		t.Errorf("Handled %v events, want %v", len(handled), subscriptionBuffer+1)
+	}
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != subscriptionBuffer {
+		t.Errorf("Slow subscriber received %v events before being dropped, want %v", received, subscriptionBuffer)
+	}
+
+	sub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Errorf("Closed subscription still delivers events")
+	}
+}
+
+func TestBusCloseSubscriptions(t *testing.T) {
+	bus := NewBus(10)
+	first, _ := bus.Subscribe(0)
+	second, _ := bus.Subscribe(0)
+
+	bus.CloseSubscriptions()
+	for _, sub := range []*Subscription{first, second} {
+		if _, ok := <-sub.C; ok {
+			t.Errorf("Subscription still open after CloseSubscriptions")
+		}
+		// Closing again is harmless
+		sub.Close()
+	}
+	bus.Publish(context.Background(), Event{ID: uuid.New(), Type: CustomerCreated})
+}
+
+// Blocks on every event until released
+type blockingHandler struct {
+	release chan struct{}
+	handled []Event
+}
+
+func (h *blockingHandler) Publish(ctx context.Context, e Event) {
+	<-h.release
+	h.handled = append(h.handled, e)
+}
+
+func TestBusSlowHandler(t *testing.T) {
+	bus := NewBus(10)
+	slow := &blockingHandler{release: make(chan struct{})}
+	var fast recorder
+	bus.Handle(slow)
+	bus.Handle(&fast)
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < 3; i++ {
+			bus.Publish(context.Background(), Event{ID: uuid.New(), Type: CustomerCreated})
+		}
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish() waits for a slow handler")
+	}
+
+	closed := make(chan error)
+	go func() { closed <- bus.Close(context.Background()) }()
+	close(slow.release)
+	if err := <-closed; err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	for name, handled := range map[string][]Event{"slow": slow.handled, "fast": fast} {
+		if len(handled) != 3 {
+			t.Fatalf("%s handler handled %v events, want 3", name, len(handled))
+		}
+		for i, e := range handled {
+			if e.Seq != uint64(i+1) {
+				t.Errorf("%s handler event %d seq=%v, want %v", name, i, e.Seq, i+1)
+			}
+		}
+	}
+}
+
+func TestBusCloseTimeout(t *testing.T) {
+	bus := NewBus(10)
+	slow := &blockingHandler{release: make(chan struct{})}
+	defer close(slow.release)
+	bus.Handle(slow)
+	bus.Publish(context.Background(), Event{ID: uuid.New(), Type: CustomerCreated})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := bus.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Type names a customer lifecycle event.
+type Type string
+
+const (
+	CustomerCreated Type = "customer.created"
+	CustomerUpdated Type = "customer.updated"
+	CustomerDeleted Type = "customer.deleted"
+)
+
+var Types = []Type{CustomerCreated, CustomerUpdated, CustomerDeleted}
+
+// Event describes a committed change to a customer.
+type Event struct {
+	ID         uuid.UUID                         `json:"id"`
+	Type       Type                              `json:"type"`
+	OccurredAt time.Time                         `json:"occurred_at"`
+	TenantID   repository.TenantID               `json:"tenant_id"`
+	CustomerID uuid.UUID                         `json:"customer_id"`
+	Customer   *repository.Customer              `json:"customer"`
+	Changes    map[string]repository.FieldChange `json:"changes"`
+	ActorID    uuid.UUID                         `json:"actor_id"`
+	RequestID  string                            `json:"request_id"`
+	// Seq orders the events published on a Bus, it does not survive
+	// restarts.
+	Seq uint64 `json:"-"`
+}
+
+// Publisher receives events once the change they describe is committed.
+// Implementations must not block the caller for long.
+type Publisher interface {
+	Publish(ctx context.Context, e Event)
+}
+
+// FromAuditEntry maps a mutation onto the lifecycle event it represents.
+// ok is false for mutations having no lifecycle event, such as purges of
+// already deleted customers.
+func FromAuditEntry(a repository.AuditEntry) (e Event, ok bool) {
+	e = Event{
+		ID:         uuid.New(),
+		OccurredAt: a.OccurredAt,
+		TenantID:   a.TenantID,
+		CustomerID: a.CustomerID,
+		Customer:   a.After,
+		Changes:    a.Diff,
+		ActorID:    a.ActorID,
+		RequestID:  a.RequestID,
+	}
+	switch a.Operation {
+	case repository.OperationCreate:
+		e.Type = CustomerCreated
+	case repository.OperationUpdate, repository.OperationAssign, repository.OperationRestore:
+		e.Type = CustomerUpdated
+	case repository.OperationDelete:
+		e.Type = CustomerDeleted
+	default:
+		return Event{}, false
+	}
+	return e, true
+}
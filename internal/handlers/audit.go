@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/logging"
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// History customer
+// @Summary Get the history of a customer
+// @Description Get every audit entry of a customer, oldest first
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Customer id"
+// @Success 200 {object} []repository.AuditEntry
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /api/customers/{id}/history [get]
+func (h Customer) History(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, fmt.Sprintf("Invalid user ID format: %q", vars["id"]),
+			problem.FieldError{Parameter: "id", Reason: err.Error()})
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Failed to get customer history")
+		return
+	}
+
+	// Deleted customers only remain visible to users allowed to audit
+	u, _ := auth.UserFromContext(r.Context())
+	c, err := h.Repo.Get(r.Context(), id)
+	if err == nil {
+		err = h.Policy.Authorize(u, auth.ActionRead, c)
+	} else {
+		err = h.Policy.Authorize(u, auth.ActionAudit, nil)
+	}
+
+	var entries []repository.AuditEntry
+	if err == nil {
+		entries, err = h.Repo.AuditEntries(r.Context(), repository.AuditFilter{CustomerID: id})
+		if err != nil {
+			e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not get user history: %s", err.Error()))
+			e.Write(w, r)
+
+			logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+				"error_message": e.Detail,
+				"status":        http.StatusInternalServerError,
+			}).Warn("Failed to get customer history")
+			return
+		}
+		if c == nil && len(entries) == 0 {
+			err = fmt.Errorf("user not found: %v", id)
+		}
+	}
+	if err != nil {
+		e := problem.New(http.StatusNotFound, problem.NotFound, "User not found")
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusNotFound,
+		}).Info("Failed to get customer history")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	jsonEnc.Encode(entries)
+}
+
+// AuditLog
+// @Summary Search the audit log
+// @Description Get the audit entries of every customer, oldest first
+// @Accept  json
+// @Produce  json
+// @Param customer_id query string false "Customer id"
+// @Param actor_id query string false "Id of the user behind the mutation"
+// @Param operation query string false "Operation" "Enum: create, update, delete, assign, restore, purge"
+// @Param since query string false "RFC 3339 lower bound, inclusive"
+// @Param until query string false "RFC 3339 upper bound, exclusive"
+// @Param limit query int false "Maximum number of entries"
+// @Success 200 {object} []repository.AuditEntry
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /api/audit [get]
+func (h Customer) AuditLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	u, _ := auth.UserFromContext(r.Context())
+	if err := h.Policy.Authorize(u, auth.ActionAudit, nil); err != nil {
+		h.forbidden(w, r, u, auth.ActionAudit)
+		return
+	}
+
+	f, invalid := parseAuditFilter(r)
+	if len(invalid) > 0 {
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, "Invalid audit filter", invalid...)
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Error(),
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Failed to search audit log")
+		return
+	}
+
+	entries, err := h.Repo.AuditEntries(r.Context(), f)
+	if err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not search audit log: %s", err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusInternalServerError,
+		}).Warn("Failed to search audit log")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	jsonEnc.Encode(entries)
+}
+
+// Reads an AuditFilter from the query string, reporting every invalid
+// parameter
+func parseAuditFilter(r *http.Request) (repository.AuditFilter, []problem.FieldError) {
+	var (
+		f       repository.AuditFilter
+		invalid []problem.FieldError
+		err     error
+	)
+	reject := func(param, reason string) {
+		invalid = append(invalid, problem.FieldError{Parameter: param, Reason: reason})
+	}
+	q := r.URL.Query()
+	if v := q.Get("customer_id"); v != "" {
+		if f.CustomerID, err = uuid.Parse(v); err != nil {
+			reject("customer_id", fmt.Sprintf("%q is not a UUID", v))
+		}
+	}
+	if v := q.Get("actor_id"); v != "" {
+		if f.ActorID, err = uuid.Parse(v); err != nil {
+			reject("actor_id", fmt.Sprintf("%q is not a UUID", v))
+		}
+	}
+	if v := q.Get("operation"); v != "" {
+		switch op := repository.Operation(v); op {
+		case repository.OperationCreate, repository.OperationUpdate, repository.OperationDelete,
+			repository.OperationAssign, repository.OperationRestore, repository.OperationPurge:
+			f.Operation = op
+		default:
+			reject("operation", fmt.Sprintf("unknown operation %q", v))
+		}
+	}
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			reject("since", fmt.Sprintf("%q is not an RFC 3339 time", v))
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			reject("until", fmt.Sprintf("%q is not an RFC 3339 time", v))
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 0 {
+			reject("limit", fmt.Sprintf("%q is not a positive integer", v))
+		}
+	}
+	return f, invalid
+}
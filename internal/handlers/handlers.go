@@ -2,38 +2,82 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/EdmundHusserl/CRM/internal/assignment"
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/logging"
+	"github.com/EdmundHusserl/CRM/internal/problem"
 	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/EdmundHusserl/CRM/internal/tracing"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Customer struct {
-	Logger *logrus.Logger
-	Repo   repository.CustomerRepository
+	Logger   *logrus.Logger
+	Repo     repository.CustomerRepository
+	Policy   auth.Policy
+	Assigner assignment.Strategy
+	Events   *events.Bus
 }
 
 type CustomerCreatedResponse struct {
 	ID uuid.UUID `json:"id"`
 }
 
-type HandlerError struct {
-	ErrorMsg string `json:"error_message"`
+type CustomersImportedResponse struct {
+	IDs []uuid.UUID `json:"ids"`
+}
+
+type AssignRequest struct {
+	OwnerID uuid.UUID `json:"owner_id"`
+}
+
+type ReassignRequest struct {
+	FromOwnerID uuid.UUID `json:"from_owner_id"`
+	ToOwnerID   uuid.UUID `json:"to_owner_id"`
+}
+
+type ReassignResponse struct {
+	Reassigned int `json:"reassigned"`
 }
 
 type CustomerHandler interface {
+	Assign(w http.ResponseWriter, r *http.Request)
+	AuditLog(w http.ResponseWriter, r *http.Request)
 	Create(w http.ResponseWriter, r *http.Request)
 	Delete(w http.ResponseWriter, r *http.Request)
 	Get(w http.ResponseWriter, r *http.Request)
 	GetAll(w http.ResponseWriter, r *http.Request)
+	History(w http.ResponseWriter, r *http.Request)
+	Import(w http.ResponseWriter, r *http.Request)
+	Reassign(w http.ResponseWriter, r *http.Request)
+	Restore(w http.ResponseWriter, r *http.Request)
+	Stream(w http.ResponseWriter, r *http.Request)
+	Trash(w http.ResponseWriter, r *http.Request)
 	Update(w http.ResponseWriter, r *http.Request)
 }
 
-func NewCustomerHandler(logger *logrus.Logger, repo repository.CustomerRepository) CustomerHandler {
-	return Customer{Logger: logger, Repo: repo}
+func NewCustomerHandler(logger *logrus.Logger, repo repository.CustomerRepository, assigner assignment.Strategy, bus *events.Bus) CustomerHandler {
+	return Customer{Logger: logger, Repo: repo, Policy: auth.RolePolicy{}, Assigner: assigner, Events: bus}
+}
+
+// Writes a 403 response for a user lacking permission to perform an action
+func (h Customer) forbidden(w http.ResponseWriter, r *http.Request, u auth.User, a auth.Action) {
+	e := problem.New(http.StatusForbidden, problem.Forbidden, fmt.Sprintf("Role %q is not allowed to %s this customer", u.Role, a))
+	e.Write(w, r)
+
+	logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+		"error_message": e.Detail,
+		"user_id":       u.ID,
+		"status":        http.StatusForbidden,
+	}).Info("Authorization failure")
 }
 
 // Create create a new customer
@@ -46,10 +90,16 @@ func NewCustomerHandler(logger *logrus.Logger, repo repository.CustomerRepositor
 // @Param email query string true "Customer e-mail"
 // @Param phone_number query string true "Customer phone number"
 // @Param contacted query boolean true "Customer Contacted status"
+// @Param owner_id query uuid.UUID false "Owning user id, defaults to the caller for sales reps then to the assignment rules"
+// @Param Idempotency-Key header string false "Key under which retries get the response of the first request"
 // @Success 200 {object} CustomerCreatedResponse
-// @Failure 400 {object} HandlerError
-// @Failure 422 {object} HandlerError
-// @Failure 500 {object} HandlerError
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /api/customers [post]
 func (h Customer) Create(w http.ResponseWriter, r *http.Request) {
 	var c repository.Customer
@@ -57,51 +107,62 @@ func (h Customer) Create(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	jsonEnc := json.NewEncoder(w)
 
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		e := HandlerError{ErrorMsg: fmt.Sprintf("Invalid e-mail format: %s", err.Error())}
-		jsonEnc.Encode(e)
+	_, span := tracing.Start(r.Context(), "decode")
+	err := json.NewDecoder(r.Body).Decode(&c)
+	tracing.End(span, err)
+	if err != nil {
+		e := problem.New(http.StatusBadRequest, problem.InvalidPayload, fmt.Sprintf("Invalid request payload: %s", err.Error()))
+		e.Write(w, r)
 
-		h.Logger.WithFields(logrus.Fields{
-			"error_message": e.ErrorMsg,
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
 			"status":        http.StatusBadRequest,
 		}).Info("Failed to create new customer")
 		return
 	}
 	c.ID = uuid.New()
+	c.TenantID = repository.TenantFromContext(r.Context())
+	c.DeletedAt = nil
 
-	if _, err := c.ValidateEmail(); err != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		e := HandlerError{ErrorMsg: fmt.Sprintf("Invalid e-mail format: %s", c.Email)}
-		jsonEnc.Encode(e)
-
-		h.Logger.WithFields(logrus.Fields{
-			"error_message": e.ErrorMsg,
-			"status":        http.StatusUnprocessableEntity,
-		}).Info("Failed to create new customer")
+	u, _ := auth.UserFromContext(r.Context())
+	if c.OwnerID == uuid.Nil && u.Role == auth.RoleSalesRep {
+		c.OwnerID = u.ID
+	}
+	if c.OwnerID == uuid.Nil {
+		c.OwnerID, _ = h.Assigner.Assign(c)
+	}
+	if err := h.Policy.Authorize(u, auth.ActionCreate, &c); err != nil {
+		h.forbidden(w, r, u, auth.ActionCreate)
 		return
 	}
 
-	if err := c.ValidatePhone(); err != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		e := HandlerError{ErrorMsg: fmt.Sprintf("Invalid phone number format: %s", c.PhoneNumber)}
-		jsonEnc.Encode(e)
+	_, span = tracing.Start(r.Context(), "validate")
+	err = c.Validate()
+	tracing.End(span, err)
+	if err != nil {
+		e := problem.New(http.StatusUnprocessableEntity, problem.ValidationFailed, "Invalid customer", problem.Fields("", err)...)
+		e.Write(w, r)
 
-		h.Logger.WithFields(logrus.Fields{
-			"error_message": e.ErrorMsg,
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": err.Error(),
 			"status":        http.StatusUnprocessableEntity,
 		}).Info("Failed to create new customer")
 		return
 	}
 
-	if err := h.Repo.Create(c); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		e := HandlerError{ErrorMsg: fmt.Sprintf("Could not create euser: %s", err.Error())}
-		jsonEnc.Encode(e)
-
-		h.Logger.WithFields(logrus.Fields{
-			"error_message": e.ErrorMsg,
-			"status":        http.StatusInternalServerError,
+	ctx, span := tracing.Start(r.Context(), "repository.Create")
+	err = h.Repo.Create(ctx, c)
+	tracing.End(span, err)
+	if err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not create user: %s", err.Error()))
+		if errors.Is(err, repository.ErrConflict) {
+			e = problem.New(http.StatusConflict, problem.Conflict, fmt.Sprintf("E-mail %s is already taken", c.Email))
+		}
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": err.Error(),
+			"status":        e.Status,
 		}).Warn("Failed to create new customer")
 		return
 	}
@@ -109,34 +170,52 @@ func (h Customer) Create(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	jsonEnc.Encode(CustomerCreatedResponse{ID: c.ID})
 
-	h.Logger.WithFields(logrus.Fields{
+	logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
 		"event":  fmt.Sprintf("ID: %v", c.ID),
-		"status": http.StatusOK,
+		"status": http.StatusCreated,
 	}).Info("New record created")
 }
 
 // GetAll Get all customers
 // @Summary Get all customers
-// @Description Get all customers
+// @Description Get all customers ordered by id, by pages when limit is set
 // @Accept  json
 // @Produce  json
+// @Param limit query int false "Maximum number of customers, up to 1000"
+// @Param after query string false "Id of the last customer of the previous page"
 // @Success 200 {object} []repository.Customer
-// @Failure 500 {object} HandlerError
+// @Header 200 {string} Link "URL of the next page, as rel next"
+// @Failure 401 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /api/customers [get]
 func (h Customer) GetAll(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	jsonEnc := json.NewEncoder(w)
 
-	customers, err := h.Repo.GetAll()
+	u, _ := auth.UserFromContext(r.Context())
+	f := h.Policy.Scope(u)
+	if invalid := parsePage(r, &f); len(invalid) > 0 {
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, "Invalid page", invalid...)
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Error(),
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Failed to get customers")
+
+		return
+	}
+	customers, err := h.listPage(w, r, f)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		e := HandlerError{ErrorMsg: fmt.Sprintf("Could not get users: %s", err.Error())}
-		jsonEnc.Encode(e)
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not get users: %s", err.Error()))
+		e.Write(w, r)
 
-		h.Logger.WithFields(logrus.Fields{
-			"error_message": e.ErrorMsg,
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
 			"status":        http.StatusInternalServerError,
-		}).Warn("Failed to create new customer")
+		}).Warn("Failed to get customers")
 
 		return
 	}
@@ -151,8 +230,10 @@ func (h Customer) GetAll(w http.ResponseWriter, r *http.Request) {
 // @Produce  json
 // @Param id query uuid.UUID true "User id"
 // @Success 200 {object} repository.Customer
-// @Failure 404 {object} HandlerError
-// @Failure 422 {object} HandlerError
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
 // @Router /api/customers/{id} [get]
 func (h Customer) Get(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -161,28 +242,32 @@ func (h Customer) Get(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := uuid.Parse(vars["id"])
 	if err != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		e := HandlerError{ErrorMsg: fmt.Sprintf("Invalid user ID format: %s", id)}
-		jsonEnc.Encode(e)
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, fmt.Sprintf("Invalid user ID format: %q", vars["id"]),
+			problem.FieldError{Parameter: "id", Reason: err.Error()})
+		e.Write(w, r)
 
-		h.Logger.WithFields(logrus.Fields{
-			"error_message": e.ErrorMsg,
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
 			"status":        http.StatusUnprocessableEntity,
-		}).Warn("Failed to create new customer")
+		}).Info("Failed to get customer")
 
 		return
 	}
 
-	c, err := h.Repo.Get(id)
+	u, _ := auth.UserFromContext(r.Context())
+	c, err := h.Repo.Get(r.Context(), id)
+	if err == nil {
+		// Customers the user may not read are reported as missing
+		err = h.Policy.Authorize(u, auth.ActionRead, c)
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		e := HandlerError{ErrorMsg: "User not found"}
-		jsonEnc.Encode(e)
+		e := problem.New(http.StatusNotFound, problem.NotFound, "User not found")
+		e.Write(w, r)
 
-		h.Logger.WithFields(logrus.Fields{
-			"error_message": e.ErrorMsg,
-			"status":        http.StatusNotFound,
-		}).Warn("Failed to create new customer")
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"event":  fmt.Sprintf("ID: %v", id),
+			"status": http.StatusNotFound,
+		}).Info("Failed to get customer")
 
 		return
 	}
@@ -193,41 +278,62 @@ func (h Customer) Get(w http.ResponseWriter, r *http.Request) {
 
 // Delete customer
 // @Summary Delete a customer
-// @Description Get a customer
+// @Description Move a customer to the trash
 // @Accept  json
 // @Produce  json
 // @Param id query uuid.UUID true "User id"
 // @Success 204 {object} nil
-// @Failure 422 {object} HandlerError
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /api/customers/{id} [delete]
 func (h Customer) Delete(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	jsonEnc := json.NewEncoder(w)
-
 	vars := mux.Vars(r)
 	id, err := uuid.Parse(vars["id"])
 	if err != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		e := HandlerError{ErrorMsg: fmt.Sprintf("Invalid user ID format: %s", id)}
-		jsonEnc.Encode(e)
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, fmt.Sprintf("Invalid user ID format: %q", vars["id"]),
+			problem.FieldError{Parameter: "id", Reason: err.Error()})
+		e.Write(w, r)
 
-		h.Logger.WithFields(logrus.Fields{
-			"event":  fmt.Sprintf("ID: %v", id),
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"event":  fmt.Sprintf("ID: %s", vars["id"]),
 			"status": http.StatusUnprocessableEntity,
 		}).Info("Deletion failure")
 
 		return
 	}
 
-	err = h.Repo.Delete(id)
+	u, _ := auth.UserFromContext(r.Context())
+	c, err := h.Repo.Get(r.Context(), id)
+	if err == nil {
+		err = h.Policy.Authorize(u, auth.ActionRead, c)
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		e := HandlerError{ErrorMsg: fmt.Sprintf("Could not delete user %s: %s", id, err.Error())}
-		jsonEnc.Encode(e)
+		e := problem.New(http.StatusNotFound, problem.NotFound, "User not found")
+		e.Write(w, r)
 
-		h.Logger.WithFields(logrus.Fields{
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
 			"event":  fmt.Sprintf("ID: %v", id),
-			"status": http.StatusBadRequest,
+			"status": http.StatusNotFound,
+		}).Info("Deletion failure")
+
+		return
+	}
+	if err := h.Policy.Authorize(u, auth.ActionDelete, c); err != nil {
+		h.forbidden(w, r, u, auth.ActionDelete)
+		return
+	}
+
+	if err := h.Repo.Delete(r.Context(), id); err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not delete user %s: %s", id, err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"event":  fmt.Sprintf("ID: %v", id),
+			"status": http.StatusInternalServerError,
 		}).Warn("Deletion failure")
 
 		return
@@ -235,9 +341,9 @@ func (h Customer) Delete(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 
-	h.Logger.WithFields(logrus.Fields{
+	logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
 		"event":  fmt.Sprintf("ID: %v", id),
-		"status": http.StatusOK,
+		"status": http.StatusNoContent,
 	}).Info("New record deleted")
 }
 
@@ -253,9 +359,14 @@ func (h Customer) Delete(w http.ResponseWriter, r *http.Request) {
 // @Param phone_number query string true "Customer phone number"
 // @Param contacted query boolean true "Customer Contacted status"
 // @Success 200 {object} repository.Customer
-// @Failure 400 {object} HandlerError
-// @Failure 422 {object} HandlerError
-// @Failure 500 {object} HandlerError
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /api/customers [patch]
 func (h Customer) Update(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -263,12 +374,14 @@ func (h Customer) Update(w http.ResponseWriter, r *http.Request) {
 
 	var c repository.Customer
 
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		e := HandlerError{ErrorMsg: fmt.Sprintf("Invalid request payload: %s", err.Error())}
-		jsonEnc.Encode(e)
+	_, span := tracing.Start(r.Context(), "decode")
+	err := json.NewDecoder(r.Body).Decode(&c)
+	tracing.End(span, err)
+	if err != nil {
+		e := problem.New(http.StatusBadRequest, problem.InvalidPayload, fmt.Sprintf("Invalid request payload: %s", err.Error()))
+		e.Write(w, r)
 
-		h.Logger.WithFields(logrus.Fields{
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
 			"event":  fmt.Sprintf("ID: %v", c.ID),
 			"status": http.StatusBadRequest,
 		}).Info("Update failure")
@@ -276,14 +389,62 @@ func (h Customer) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Repo.Update(c); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		e := HandlerError{ErrorMsg: fmt.Sprintf("Could not update user: %s", err.Error())}
-		jsonEnc.Encode(e)
+	_, span = tracing.Start(r.Context(), "validate")
+	err = c.Validate()
+	tracing.End(span, err)
+	if err != nil {
+		e := problem.New(http.StatusUnprocessableEntity, problem.ValidationFailed, "Invalid customer", problem.Fields("", err)...)
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"event":         fmt.Sprintf("ID: %v", c.ID),
+			"error_message": err.Error(),
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Update failure")
 
-		h.Logger.WithFields(logrus.Fields{
+		return
+	}
+
+	u, _ := auth.UserFromContext(r.Context())
+	ctx, span := tracing.Start(r.Context(), "repository.Get")
+	existing, err := h.Repo.Get(ctx, c.ID)
+	tracing.End(span, err)
+	if err == nil {
+		err = h.Policy.Authorize(u, auth.ActionRead, existing)
+	}
+	if err != nil {
+		e := problem.New(http.StatusNotFound, problem.NotFound, "User not found")
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
 			"event":  fmt.Sprintf("ID: %v", c.ID),
-			"status": http.StatusBadRequest,
+			"status": http.StatusNotFound,
+		}).Info("Update failure")
+
+		return
+	}
+	if err := h.Policy.Authorize(u, auth.ActionUpdate, existing); err != nil {
+		h.forbidden(w, r, u, auth.ActionUpdate)
+		return
+	}
+
+	// Ownership and tenancy are not changed through updates
+	c.OwnerID = existing.OwnerID
+	c.TenantID = existing.TenantID
+	ctx, span = tracing.Start(r.Context(), "repository.Update")
+	err = h.Repo.Update(ctx, c)
+	tracing.End(span, err)
+	if err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not update user: %s", err.Error()))
+		if errors.Is(err, repository.ErrConflict) {
+			e = problem.New(http.StatusConflict, problem.Conflict, fmt.Sprintf("E-mail %s is already taken", c.Email))
+		}
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"event":         fmt.Sprintf("ID: %v", c.ID),
+			"error_message": err.Error(),
+			"status":        e.Status,
 		}).Warn("Update failure")
 
 		return
@@ -292,3 +453,420 @@ func (h Customer) Update(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	jsonEnc.Encode(c)
 }
+
+// Import customers
+// @Summary Import customers
+// @Description Create customers in bulk, reserved to admins
+// @Accept  json
+// @Produce  json
+// @Param customers body []repository.Customer true "Customers to import"
+// @Param Idempotency-Key header string false "Key under which retries get the response of the first request"
+// @Success 201 {object} CustomersImportedResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /api/customers/import [post]
+func (h Customer) Import(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	u, _ := auth.UserFromContext(r.Context())
+	if err := h.Policy.Authorize(u, auth.ActionImport, nil); err != nil {
+		h.forbidden(w, r, u, auth.ActionImport)
+		return
+	}
+
+	var customers []repository.Customer
+	_, span := tracing.Start(r.Context(), "decode")
+	err := json.NewDecoder(r.Body).Decode(&customers)
+	tracing.End(span, err)
+	if err != nil {
+		e := problem.New(http.StatusBadRequest, problem.InvalidPayload, fmt.Sprintf("Invalid request payload: %s", err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusBadRequest,
+		}).Info("Import failure")
+		return
+	}
+
+	// Every invalid customer is reported before any is created
+	_, span = tracing.Start(r.Context(), "validate")
+	var invalid []problem.FieldError
+	for i, c := range customers {
+		if err := c.Validate(); err != nil {
+			invalid = append(invalid, problem.Fields(fmt.Sprintf("/%d", i), err)...)
+		}
+		customers[i].ID = uuid.New()
+		customers[i].TenantID = repository.TenantFromContext(r.Context())
+		customers[i].DeletedAt = nil
+	}
+	if len(invalid) > 0 {
+		e := problem.New(http.StatusUnprocessableEntity, problem.ValidationFailed, "Invalid customers", invalid...)
+		tracing.End(span, e)
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Error(),
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Import failure")
+		return
+	}
+	tracing.End(span, nil)
+
+	// Owners default as in Create
+	for i, c := range customers {
+		if c.OwnerID == uuid.Nil && u.Role == auth.RoleSalesRep {
+			customers[i].OwnerID = u.ID
+		}
+		if customers[i].OwnerID == uuid.Nil {
+			customers[i].OwnerID, _ = h.Assigner.Assign(c)
+		}
+	}
+
+	ctx, span := tracing.Start(r.Context(), "repository.Create", attribute.Int("customers", len(customers)))
+	resp := CustomersImportedResponse{IDs: []uuid.UUID{}}
+	for _, c := range customers {
+		if err := h.Repo.Create(ctx, c); err != nil {
+			tracing.End(span, err)
+			e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not import user %s after %d imported: %s", c.Email, len(resp.IDs), err.Error()))
+			if errors.Is(err, repository.ErrConflict) {
+				e = problem.New(http.StatusConflict, problem.Conflict, fmt.Sprintf("E-mail %s is already taken, %d users imported", c.Email, len(resp.IDs)))
+			}
+			e.Write(w, r)
+
+			logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+				"error_message": e.Detail,
+				"status":        e.Status,
+			}).Warn("Import failure")
+			return
+		}
+		resp.IDs = append(resp.IDs, c.ID)
+	}
+	tracing.End(span, nil)
+
+	w.WriteHeader(http.StatusCreated)
+	jsonEnc.Encode(resp)
+
+	logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+		"event":  fmt.Sprintf("%d customers", len(resp.IDs)),
+		"status": http.StatusCreated,
+	}).Info("Records imported")
+}
+
+// Assign customer
+// @Summary Assign a customer
+// @Description Make a user the owner of a customer, reserved to admins
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Customer id"
+// @Param assignment body AssignRequest true "New owner"
+// @Param Idempotency-Key header string false "Key under which retries get the response of the first request"
+// @Success 200 {object} repository.Customer
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /api/customers/{id}/assign [post]
+func (h Customer) Assign(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, fmt.Sprintf("Invalid user ID format: %q", vars["id"]),
+			problem.FieldError{Parameter: "id", Reason: err.Error()})
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Assignment failure")
+		return
+	}
+
+	var req AssignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		e := problem.New(http.StatusBadRequest, problem.InvalidPayload, fmt.Sprintf("Invalid request payload: %s", err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusBadRequest,
+		}).Info("Assignment failure")
+		return
+	}
+	if req.OwnerID == uuid.Nil {
+		e := problem.New(http.StatusUnprocessableEntity, problem.ValidationFailed, "Invalid assignment",
+			problem.FieldError{Pointer: "/owner_id", Reason: "is required"})
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Assignment failure")
+		return
+	}
+
+	u, _ := auth.UserFromContext(r.Context())
+	c, err := h.Repo.Get(r.Context(), id)
+	if err == nil {
+		err = h.Policy.Authorize(u, auth.ActionRead, c)
+	}
+	if err != nil {
+		e := problem.New(http.StatusNotFound, problem.NotFound, "User not found")
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusNotFound,
+		}).Info("Assignment failure")
+		return
+	}
+	if err := h.Policy.Authorize(u, auth.ActionAssign, c); err != nil {
+		h.forbidden(w, r, u, auth.ActionAssign)
+		return
+	}
+
+	if err := h.Repo.Assign(r.Context(), id, req.OwnerID); err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not assign user %s: %s", id, err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"event":  fmt.Sprintf("ID: %v", id),
+			"status": http.StatusInternalServerError,
+		}).Warn("Assignment failure")
+		return
+	}
+	c.OwnerID = req.OwnerID
+
+	w.WriteHeader(http.StatusOK)
+	jsonEnc.Encode(c)
+
+	logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+		"event":  fmt.Sprintf("ID: %v, owner: %v", id, req.OwnerID),
+		"status": http.StatusOK,
+	}).Info("Record assigned")
+}
+
+// Reassign customers
+// @Summary Reassign customers in bulk
+// @Description Transfer every customer of an owner to another, reserved to admins
+// @Accept  json
+// @Produce  json
+// @Param reassignment body ReassignRequest true "Previous and new owner"
+// @Param Idempotency-Key header string false "Key under which retries get the response of the first request"
+// @Success 200 {object} ReassignResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /api/customers/reassign [post]
+func (h Customer) Reassign(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	u, _ := auth.UserFromContext(r.Context())
+	if err := h.Policy.Authorize(u, auth.ActionAssign, nil); err != nil {
+		h.forbidden(w, r, u, auth.ActionAssign)
+		return
+	}
+
+	var req ReassignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		e := problem.New(http.StatusBadRequest, problem.InvalidPayload, fmt.Sprintf("Invalid request payload: %s", err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusBadRequest,
+		}).Info("Reassignment failure")
+		return
+	}
+	var invalid []problem.FieldError
+	if req.FromOwnerID == uuid.Nil {
+		invalid = append(invalid, problem.FieldError{Pointer: "/from_owner_id", Reason: "is required"})
+	}
+	if req.ToOwnerID == uuid.Nil {
+		invalid = append(invalid, problem.FieldError{Pointer: "/to_owner_id", Reason: "is required"})
+	}
+	if len(invalid) > 0 {
+		e := problem.New(http.StatusUnprocessableEntity, problem.ValidationFailed, "Invalid reassignment", invalid...)
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Reassignment failure")
+		return
+	}
+
+	n, err := h.Repo.Reassign(r.Context(), req.FromOwnerID, req.ToOwnerID)
+	if err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not reassign users: %s", err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusInternalServerError,
+		}).Warn("Reassignment failure")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	jsonEnc.Encode(ReassignResponse{Reassigned: n})
+
+	logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+		"event":  fmt.Sprintf("%d customers from %v to %v", n, req.FromOwnerID, req.ToOwnerID),
+		"status": http.StatusOK,
+	}).Info("Records reassigned")
+}
+
+// Trash
+// @Summary Get deleted customers
+// @Description Get the customers in the trash ordered by id, reserved to admins, by pages when limit is set
+// @Accept  json
+// @Produce  json
+// @Param limit query int false "Maximum number of customers, up to 1000"
+// @Param after query string false "Id of the last customer of the previous page"
+// @Success 200 {object} []repository.Customer
+// @Header 200 {string} Link "URL of the next page, as rel next"
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /api/customers/trash [get]
+func (h Customer) Trash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	u, _ := auth.UserFromContext(r.Context())
+	if err := h.Policy.Authorize(u, auth.ActionRestore, nil); err != nil {
+		h.forbidden(w, r, u, auth.ActionRestore)
+		return
+	}
+
+	f := h.Policy.Scope(u)
+	f.Deleted = true
+	if invalid := parsePage(r, &f); len(invalid) > 0 {
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, "Invalid page", invalid...)
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Error(),
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Failed to get trash")
+		return
+	}
+	customers, err := h.listPage(w, r, f)
+	if err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not get deleted users: %s", err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusInternalServerError,
+		}).Warn("Failed to get trash")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	jsonEnc.Encode(customers)
+}
+
+// Restore customer
+// @Summary Restore a deleted customer
+// @Description Move a customer out of the trash, reserved to admins
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Customer id"
+// @Param Idempotency-Key header string false "Key under which retries get the response of the first request"
+// @Success 200 {object} repository.Customer
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /api/customers/{id}/restore [post]
+func (h Customer) Restore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, fmt.Sprintf("Invalid user ID format: %q", vars["id"]),
+			problem.FieldError{Parameter: "id", Reason: err.Error()})
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Restore failure")
+		return
+	}
+
+	u, _ := auth.UserFromContext(r.Context())
+	if err := h.Policy.Authorize(u, auth.ActionRestore, nil); err != nil {
+		h.forbidden(w, r, u, auth.ActionRestore)
+		return
+	}
+
+	if err := h.Repo.Restore(r.Context(), id); err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not restore user %s: %s", id, err.Error()))
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			e = problem.New(http.StatusNotFound, problem.NotFound, "User not found in trash")
+		case errors.Is(err, repository.ErrConflict):
+			e = problem.New(http.StatusConflict, problem.Conflict, "The e-mail of the user is taken by another one")
+		}
+		e.Write(w, r)
+
+		entry := logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"event":         fmt.Sprintf("ID: %v", id),
+			"error_message": err.Error(),
+			"status":        e.Status,
+		})
+		if e.Status == http.StatusInternalServerError {
+			entry.Warn("Restore failure")
+		} else {
+			entry.Info("Restore failure")
+		}
+		return
+	}
+
+	c, err := h.Repo.Get(r.Context(), id)
+	if err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not get restored user %s: %s", id, err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"event":  fmt.Sprintf("ID: %v", id),
+			"status": http.StatusInternalServerError,
+		}).Warn("Restore failure")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	jsonEnc.Encode(c)
+
+	logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+		"event":  fmt.Sprintf("ID: %v", id),
+		"status": http.StatusOK,
+	}).Info("Record restored")
+}
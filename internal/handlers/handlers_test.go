@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/EdmundHusserl/CRM/internal/assignment"
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Returns a router serving h to an admin
+func newTestRouter(h CustomerHandler) http.Handler {
+	return newTestRouterFor(h, auth.User{Role: auth.RoleAdmin})
+}
+
+// Returns a router serving h to u
+func newTestRouterFor(h CustomerHandler, u auth.User) http.Handler {
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), u)))
+		})
+	})
+	router.HandleFunc("/api/customers/import", h.Import).Methods(http.MethodPost)
+	router.HandleFunc("/api/customers/{id}/restore", h.Restore).Methods(http.MethodPost)
+	router.HandleFunc("/api/customers/{id}", h.Get).Methods(http.MethodGet)
+	router.HandleFunc("/api/customers", h.Update).Methods(http.MethodPatch)
+	router.HandleFunc("/api/customers", h.Create).Methods(http.MethodPost)
+	router.HandleFunc("/api/customers", h.GetAll).Methods(http.MethodGet)
+	router.HandleFunc("/api/audit", h.AuditLog).Methods(http.MethodGet)
+	return router
+}
+
+func TestProblems(t *testing.T) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	repo := providers.NewInMemoryCustomerRepository([]repository.Customer{
+		{ID: uuid.New(), Name: "Jorge", Email: "jorge@corp.com", PhoneNumber: "555"},
+	})
+	h := newTestRouter(NewCustomerHandler(l, repo, assignment.NewStrategy(l, ""), events.NewBus(16)))
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantCode   problem.Code
+		wantErrors []problem.FieldError
+	}{
+		{
+			name: "undecodable payload", method: http.MethodPost, target: "/api/customers", body: `{"name":`,
+			wantStatus: http.StatusBadRequest, wantCode: problem.InvalidPayload,
+		},
+		{
+			name: "every invalid field", method: http.MethodPost, target: "/api/customers",
+			body:       `{"role":7,"email":"jorge@","phone_number":"call me"}`,
+			wantStatus: http.StatusUnprocessableEntity, wantCode: problem.ValidationFailed,
+			wantErrors: []problem.FieldError{
+				{Pointer: "/name", Reason: "is required"},
+				{Pointer: "/role", Reason: "must be one of 0, 1, 2"},
+				{Pointer: "/email", Reason: `"jorge@" is not an e-mail address`},
+				{Pointer: "/phone_number", Reason: `"call me" is not a phone number`},
+			},
+		},
+		{
+			name: "email taken", method: http.MethodPost, target: "/api/customers",
+			body:       `{"name":"Jorge","email":"jorge@corp.com","phone_number":"555"}`,
+			wantStatus: http.StatusConflict, wantCode: problem.Conflict,
+		},
+		{
+			name: "invalid customers of an import", method: http.MethodPost, target: "/api/customers/import",
+			body:       `[{"name":"Ana","email":"ana@corp.com","phone_number":"555"},{"name":"Bo","email":"bo","phone_number":"555"}]`,
+			wantStatus: http.StatusUnprocessableEntity, wantCode: problem.ValidationFailed,
+			wantErrors: []problem.FieldError{{Pointer: "/1/email", Reason: `"bo" is not an e-mail address`}},
+		},
+		{
+			name: "missing customer", method: http.MethodPatch, target: "/api/customers",
+			body:       `{"name":"Ana","email":"ana@corp.com","phone_number":"555"}`,
+			wantStatus: http.StatusNotFound, wantCode: problem.NotFound,
+		},
+		{
+			name: "invalid id", method: http.MethodGet, target: "/api/customers/42",
+			wantStatus: http.StatusUnprocessableEntity, wantCode: problem.InvalidParameter,
+			wantErrors: []problem.FieldError{{Parameter: "id", Reason: "invalid UUID length: 2"}},
+		},
+		{
+			name: "invalid page", method: http.MethodGet, target: "/api/customers?limit=0&after=42",
+			wantStatus: http.StatusUnprocessableEntity, wantCode: problem.InvalidParameter,
+			wantErrors: []problem.FieldError{
+				{Parameter: "limit", Reason: `"0" is not an integer between 1 and 1000`},
+				{Parameter: "after", Reason: `"42" is not a UUID`},
+			},
+		},
+		{
+			name: "invalid audit filter", method: http.MethodGet, target: "/api/audit?operation=merge&limit=-1",
+			wantStatus: http.StatusUnprocessableEntity, wantCode: problem.InvalidParameter,
+			wantErrors: []problem.FieldError{
+				{Parameter: "operation", Reason: `unknown operation "merge"`},
+				{Parameter: "limit", Reason: `"-1" is not a positive integer`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			var p problem.Problem
+			json.NewDecoder(rec.Body).Decode(&p)
+			if rec.Code != tt.wantStatus || p.Status != tt.wantStatus || p.Code != tt.wantCode {
+				t.Fatalf("%s %s = %d %+v, want %d %s", tt.method, tt.target, rec.Code, p, tt.wantStatus, tt.wantCode)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Errorf("Content-Type = %q, want %q", ct, problem.ContentType)
+			}
+			if p.Type != problem.TypeBase+string(tt.wantCode) || p.Instance != strings.Split(tt.target, "?")[0] {
+				t.Errorf("type %q and instance %q", p.Type, p.Instance)
+			}
+			if !reflect.DeepEqual(p.Errors, tt.wantErrors) {
+				t.Errorf("errors = %+v, want %+v", p.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	var seeded []repository.Customer
+	for range 5 {
+		seeded = append(seeded, repository.Customer{ID: uuid.New(), Name: "Jorge", Email: "jorge@corp.com", PhoneNumber: "555"})
+	}
+	repo := providers.NewInMemoryCustomerRepository(seeded)
+	h := newTestRouter(NewCustomerHandler(l, repo, assignment.NewStrategy(l, ""), events.NewBus(16)))
+
+	var listed []uuid.UUID
+	target := "/api/customers?limit=2"
+	for pages := 0; target != ""; pages++ {
+		if pages == 3 {
+			t.Fatalf("more than 3 pages of 2 customers out of 5")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var page []repository.Customer
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d, %v", target, rec.Code, err)
+		}
+		for _, c := range page {
+			listed = append(listed, c.ID)
+		}
+		target = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			target = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		}
+	}
+
+	all, _ := repo.GetAll(t.Context(), repository.CustomerFilter{})
+	if len(listed) != len(all) {
+		t.Fatalf("listed %d customers by pages, want %d", len(listed), len(all))
+	}
+	for i, c := range all {
+		if listed[i] != c.ID {
+			t.Errorf("customer %d = %v, want %v", i, listed[i], c.ID)
+		}
+	}
+}
+
+func TestCreateOwnedBySalesRep(t *testing.T) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	rep := auth.User{ID: uuid.New(), Name: "Rep", Role: auth.RoleSalesRep}
+	tests := []struct {
+		name       string
+		owner      uuid.UUID
+		wantStatus int
+	}{
+		{"defaults to the rep", uuid.Nil, http.StatusCreated},
+		{"owned by the rep", rep.ID, http.StatusCreated},
+		{"owned by another user", uuid.New(), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := providers.NewInMemoryCustomerRepository(nil)
+			h := newTestRouterFor(NewCustomerHandler(l, repo, assignment.NewStrategy(l, ""), events.NewBus(16)), rep)
+			body := `{"name":"Jorge","email":"jorge@corp.com","phone_number":"555","owner_id":"` + tt.owner.String() + `"}`
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/customers", strings.NewReader(body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("POST /api/customers = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			customers, _ := repo.GetAll(context.Background(), repository.CustomerFilter{})
+			if tt.wantStatus == http.StatusCreated && (len(customers) != 1 || customers[0].OwnerID != rep.ID) {
+				t.Errorf("Customers = %+v, want one owned by the rep", customers)
+			}
+			if tt.wantStatus == http.StatusForbidden && len(customers) != 0 {
+				t.Errorf("Customers = %+v, want none", customers)
+			}
+		})
+	}
+}
+
+// Fails every restore with err
+type failingRestore struct {
+	repository.CustomerRepository
+	err error
+}
+
+func (r failingRestore) Restore(ctx context.Context, id uuid.UUID) error {
+	return r.err
+}
+
+func TestRestoreFailures(t *testing.T) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   problem.Code
+	}{
+		{"not in trash", fmt.Errorf("%w: user in trash", repository.ErrNotFound), http.StatusNotFound, problem.NotFound},
+		{"email taken", fmt.Errorf("%w: duplicate email", repository.ErrConflict), http.StatusConflict, problem.Conflict},
+		{"database failure", errors.New("connection reset by peer"), http.StatusInternalServerError, problem.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := failingRestore{providers.NewInMemoryCustomerRepository(nil), tt.err}
+			h := newTestRouter(NewCustomerHandler(l, repo, assignment.NewStrategy(l, ""), events.NewBus(16)))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/customers/"+uuid.NewString()+"/restore", nil))
+			var p problem.Problem
+			json.NewDecoder(rec.Body).Decode(&p)
+			if rec.Code != tt.wantStatus || p.Code != tt.wantCode {
+				t.Errorf("POST restore = %d %+v, want %d %s", rec.Code, p, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCreateNotTrashed(t *testing.T) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{"create", "/api/customers", `{"name":"Jorge","email":"jorge@corp.com","phone_number":"555","deleted_at":"2024-01-01T00:00:00Z"}`},
+		{"import", "/api/customers/import", `[{"name":"Jorge","email":"jorge@corp.com","phone_number":"555","deleted_at":"2024-01-01T00:00:00Z"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := providers.NewInMemoryCustomerRepository(nil)
+			h := newTestRouter(NewCustomerHandler(l, repo, assignment.NewStrategy(l, ""), events.NewBus(16)))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("POST %s = %d, want %d", tt.target, rec.Code, http.StatusCreated)
+			}
+			customers, _ := repo.GetAll(context.Background(), repository.CustomerFilter{})
+			trashed, _ := repo.GetAll(context.Background(), repository.CustomerFilter{Deleted: true})
+			if len(customers) != 1 || len(trashed) != 0 {
+				t.Errorf("Customers = %+v, trash = %+v, want the customer out of the trash", customers, trashed)
+			}
+		})
+	}
+}
+
+func TestImportAssigned(t *testing.T) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	first, second, chosen := uuid.New(), uuid.New(), uuid.New()
+	repo := providers.NewInMemoryCustomerRepository(nil)
+	h := newTestRouter(NewCustomerHandler(l, repo, &assignment.RoundRobin{Owners: []uuid.UUID{first, second}}, events.NewBus(16)))
+	body := `[
+		{"name":"Jorge","email":"jorge@corp.com","phone_number":"555"},
+		{"name":"Ana","email":"ana@corp.com","phone_number":"555","owner_id":"` + chosen.String() + `"},
+		{"name":"Luis","email":"luis@corp.com","phone_number":"555"}
+	]`
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/customers/import", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api/customers/import = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	want := map[string]uuid.UUID{"jorge@corp.com": first, "ana@corp.com": chosen, "luis@corp.com": second}
+	customers, _ := repo.GetAll(context.Background(), repository.CustomerFilter{})
+	for _, c := range customers {
+		if c.OwnerID != want[c.Email] {
+			t.Errorf("Owner of %s = %v, want %v", c.Email, c.OwnerID, want[c.Email])
+		}
+	}
+	if len(customers) != len(want) {
+		t.Errorf("Imported %d customers, want %d", len(customers), len(want))
+	}
+}
+
+func TestStreamFilter(t *testing.T) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	h := NewCustomerHandler(l, providers.NewInMemoryCustomerRepository(nil), assignment.NewStrategy(l, ""), events.NewBus(16)).(Customer)
+	rep := auth.User{ID: uuid.New(), Name: "Rep", Role: auth.RoleSalesRep}
+	r := httptest.NewRequest(http.MethodGet, "/api/customers/stream?role=1", nil)
+	r = r.WithContext(repository.WithTenant(auth.WithUser(r.Context(), rep), "acme"))
+	visible, invalid := h.streamFilter(r)
+	if len(invalid) > 0 {
+		t.Fatalf("streamFilter() invalid = %v", invalid)
+	}
+
+	tests := []struct {
+		name  string
+		event events.Event
+		want  bool
+	}{
+		{"owned", events.Event{TenantID: "acme", Customer: &repository.Customer{Role: repository.Premium, OwnerID: rep.ID}}, true},
+		{"owned by another user", events.Event{TenantID: "acme", Customer: &repository.Customer{Role: repository.Premium, OwnerID: uuid.New()}}, false},
+		{"another role", events.Event{TenantID: "acme", Customer: &repository.Customer{Role: repository.Basic, OwnerID: rep.ID}}, false},
+		{"deleted directly", events.Event{Type: events.CustomerDeleted, TenantID: "acme", CustomerID: uuid.New()}, true},
+		{"deleted directly in another tenant", events.Event{Type: events.CustomerDeleted, TenantID: "other", CustomerID: uuid.New()}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := visible(tt.event); got != tt.want {
+				t.Errorf("visible() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/EdmundHusserl/CRM/internal/version"
+	"github.com/sirupsen/logrus"
+)
+
+type Health struct {
+	Logger   *logrus.Logger
+	Repo     repository.CustomerRepository
+	Provider string
+	// Timeout bounds the checks of Readyz
+	Timeout time.Duration
+}
+
+type HealthHandler interface {
+	Healthz(w http.ResponseWriter, r *http.Request)
+	Readyz(w http.ResponseWriter, r *http.Request)
+	Version(w http.ResponseWriter, r *http.Request)
+}
+
+func NewHealthHandler(logger *logrus.Logger, repo repository.CustomerRepository, provider string) HealthHandler {
+	return Health{Logger: logger, Repo: repo, Provider: provider, Timeout: 2 * time.Second}
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+type ReadinessResponse struct {
+	Status        string `json:"status"`
+	Provider      string `json:"provider"`
+	SchemaVersion int    `json:"schema_version"`
+	ErrorMsg      string `json:"error_message,omitempty"`
+}
+
+type VersionResponse struct {
+	version.Info
+	Provider string `json:"provider"`
+}
+
+// Healthz liveness probe
+// @Summary Liveness probe
+// @Description Answers as long as the process serves requests
+// @Produce  json
+// @Success 200 {object} HealthResponse
+// @Router /healthz [get]
+func (h Health) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(HealthResponse{Status: "ok"})
+}
+
+// Readyz readiness probe
+// @Summary Readiness probe
+// @Description Pings the repository and checks that its schema is up to date
+// @Produce  json
+// @Success 200 {object} ReadinessResponse
+// @Failure 503 {object} ReadinessResponse
+// @Router /readyz [get]
+func (h Health) Readyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.Timeout)
+	defer cancel()
+
+	resp := ReadinessResponse{Status: "ready", Provider: h.Provider}
+	err := h.Repo.Ping(ctx)
+	if err == nil {
+		resp.SchemaVersion, err = providers.SchemaVersion(ctx, h.Repo)
+	}
+	if err == nil && resp.SchemaVersion < providers.RequiredSchemaVersion {
+		err = fmt.Errorf("schema version %d, migrations up to %d are required", resp.SchemaVersion, providers.RequiredSchemaVersion)
+	}
+	if err != nil {
+		resp.Status = "unavailable"
+		resp.ErrorMsg = err.Error()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		jsonEnc.Encode(resp)
+
+		h.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"error_message": resp.ErrorMsg,
+			"status":        http.StatusServiceUnavailable,
+		}).Warn("Readiness failure")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	jsonEnc.Encode(resp)
+}
+
+// Version build information
+// @Summary Build information
+// @Description Git SHA and time of the build, and the repository provider in use
+// @Produce  json
+// @Success 200 {object} VersionResponse
+// @Router /version [get]
+func (h Health) Version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(VersionResponse{Info: version.Get(), Provider: h.Provider})
+}
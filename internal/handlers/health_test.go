@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/sirupsen/logrus"
+)
+
+// Fails every ping
+type unreachableRepository struct {
+	repository.CustomerRepository
+}
+
+func (unreachableRepository) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		repo       repository.CustomerRepository
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "Ready",
+			repo:       &providers.InMemoryCustomerRepository{},
+			wantStatus: http.StatusOK,
+			want:       "ready",
+		},
+		{
+			name:       "Repository_unreachable",
+			repo:       unreachableRepository{},
+			wantStatus: http.StatusServiceUnavailable,
+			want:       "unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthHandler(logrus.New(), tt.repo, "in-memory")
+			rec := httptest.NewRecorder()
+			h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			var resp ReadinessResponse
+			json.NewDecoder(rec.Body).Decode(&resp)
+			if rec.Code != tt.wantStatus || resp.Status != tt.want {
+				t.Errorf("Readyz() = %d %q, want %d %q", rec.Code, resp.Status, tt.wantStatus, tt.want)
+			}
+		})
+	}
+}
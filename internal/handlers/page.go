@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Largest page of customers a request may ask for
+const maxPageSize = 1000
+
+// Reads the limit and after query parameters paginating a list of
+// customers into f, reporting every invalid one
+func parsePage(r *http.Request, f *repository.CustomerFilter) []problem.FieldError {
+	var (
+		invalid []problem.FieldError
+		err     error
+	)
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > maxPageSize {
+			invalid = append(invalid, problem.FieldError{Parameter: "limit", Reason: fmt.Sprintf("%q is not an integer between 1 and %d", v, maxPageSize)})
+		}
+	}
+	if v := q.Get("after"); v != "" {
+		if f.After, err = uuid.Parse(v); err != nil {
+			invalid = append(invalid, problem.FieldError{Parameter: "after", Reason: fmt.Sprintf("%q is not a UUID", v)})
+		}
+	}
+	return invalid
+}
+
+// Lists the customers of f, linking to the next page in the Link header of
+// w when f is limited and more customers follow
+func (h Customer) listPage(w http.ResponseWriter, r *http.Request, f repository.CustomerFilter) ([]repository.Customer, error) {
+	limit := f.Limit
+	if limit > 0 {
+		// One more tells whether a next page exists
+		f.Limit++
+	}
+	customers, err := h.Repo.GetAll(r.Context(), f)
+	if err != nil || limit == 0 || len(customers) <= limit {
+		return customers, err
+	}
+	customers = customers[:limit]
+	next := *r.URL
+	q := next.Query()
+	q.Set("after", customers[limit-1].ID.String())
+	next.RawQuery = q.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	return customers, nil
+}
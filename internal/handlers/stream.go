@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/logging"
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Interval between comments keeping idle streams open through proxies
+const heartbeatInterval = 15 * time.Second
+
+// Stream customer changes
+// @Summary Stream customer changes
+// @Description Server-Sent Events stream of customer.created, customer.updated and customer.deleted events. Reconnecting clients sending Last-Event-ID receive the events they missed while they remain buffered. Customers deleted from the database directly are only known by their customer_id, their deletion being sent to every stream.
+// @Produce  text/event-stream
+// @Param Last-Event-ID header string false "Id of the last event received"
+// @Param role query int false "Only customers of this role" "Enum: 0=Basic 1=Premium 2=Partner"
+// @Param owner_id query string false "Only customers owned by this user"
+// @Success 200 {object} events.Event
+// @Failure 401 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Router /api/customers/stream [get]
+func (h Customer) Stream(w http.ResponseWriter, r *http.Request) {
+	visible, invalid := h.streamFilter(r)
+	if len(invalid) > 0 {
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, "Invalid stream filter", invalid...)
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Error(),
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Failed to stream customers")
+		return
+	}
+
+	// An unparsable Last-Event-ID is handled as a fresh connection
+	lastSeq, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	sub, missed := h.Events.Subscribe(lastSeq)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	// Streams outlive the server write timeout
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	for _, e := range missed {
+		if visible(e) {
+			writeEvent(w, e)
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for being too slow, the client resumes on reconnection
+				return
+			}
+			if !visible(e) {
+				continue
+			}
+			writeEvent(w, e)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// Returns the predicate selecting the events a stream request may
+// receive, or its invalid parameters
+func (h Customer) streamFilter(r *http.Request) (func(e events.Event) bool, []problem.FieldError) {
+	var (
+		role    *repository.CustomerRole
+		ownerID uuid.UUID
+		invalid []problem.FieldError
+		err     error
+	)
+	q := r.URL.Query()
+	if v := q.Get("role"); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			invalid = append(invalid, problem.FieldError{Parameter: "role", Reason: fmt.Sprintf("%q is not an integer", v)})
+		}
+		cr := repository.CustomerRole(i)
+		role = &cr
+	}
+	if v := q.Get("owner_id"); v != "" {
+		if ownerID, err = uuid.Parse(v); err != nil {
+			invalid = append(invalid, problem.FieldError{Parameter: "owner_id", Reason: fmt.Sprintf("%q is not a UUID", v)})
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, invalid
+	}
+
+	u, _ := auth.UserFromContext(r.Context())
+	tenant := repository.TenantFromContext(r.Context())
+	return func(e events.Event) bool {
+		switch {
+		case e.TenantID != tenant:
+			return false
+		case e.Customer == nil:
+			// Customers deleted from the database directly are only known
+			// by their id, which tells nothing of them to other streams
+			return true
+		case role != nil && e.Customer.Role != *role,
+			ownerID != uuid.Nil && e.Customer.OwnerID != ownerID:
+			return false
+		}
+		return h.Policy.Authorize(u, auth.ActionRead, e.Customer) == nil
+	}, nil
+}
+
+// Writes e in the text/event-stream format
+func writeEvent(w http.ResponseWriter, e events.Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/logging"
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/EdmundHusserl/CRM/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+type Webhook struct {
+	Logger     *logrus.Logger
+	Store      repository.WebhookRepository
+	Dispatcher *webhooks.Dispatcher
+	Policy     auth.Policy
+}
+
+type WebhookHandler interface {
+	Create(w http.ResponseWriter, r *http.Request)
+	Delete(w http.ResponseWriter, r *http.Request)
+	GetAll(w http.ResponseWriter, r *http.Request)
+	Deliveries(w http.ResponseWriter, r *http.Request)
+	Redeliver(w http.ResponseWriter, r *http.Request)
+}
+
+func NewWebhookHandler(logger *logrus.Logger, store repository.WebhookRepository, dispatcher *webhooks.Dispatcher) WebhookHandler {
+	return Webhook{Logger: logger, Store: store, Dispatcher: dispatcher, Policy: auth.RolePolicy{}}
+}
+
+// Writes a 403 response unless the user may manage webhooks
+func (h Webhook) authorize(w http.ResponseWriter, r *http.Request) bool {
+	u, _ := auth.UserFromContext(r.Context())
+	if err := h.Policy.Authorize(u, auth.ActionWebhooks, nil); err != nil {
+		e := problem.New(http.StatusForbidden, problem.Forbidden, fmt.Sprintf("Role %q is not allowed to manage webhooks", u.Role))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"user_id":       u.ID,
+			"status":        http.StatusForbidden,
+		}).Info("Authorization failure")
+		return false
+	}
+	return true
+}
+
+// Create webhook
+// @Summary Subscribe to customer events
+// @Description Register a URL receiving the given customer events, reserved to admins. The secret signing the deliveries is generated when omitted and only returned by this call.
+// @Accept  json
+// @Produce  json
+// @Param subscription body repository.WebhookSubscription true "Subscription, events among customer.created, customer.updated, customer.deleted or *"
+// @Param Idempotency-Key header string false "Key under which retries get the response of the first request"
+// @Success 201 {object} repository.WebhookSubscription
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /api/webhooks [post]
+func (h Webhook) Create(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	if !h.authorize(w, r) {
+		return
+	}
+
+	var s repository.WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		e := problem.New(http.StatusBadRequest, problem.InvalidPayload, fmt.Sprintf("Invalid request payload: %s", err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusBadRequest,
+		}).Info("Failed to create webhook")
+		return
+	}
+
+	if invalid := validateSubscription(s); len(invalid) > 0 {
+		e := problem.New(http.StatusUnprocessableEntity, problem.ValidationFailed, "Invalid subscription", invalid...)
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Error(),
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Failed to create webhook")
+		return
+	}
+
+	s.ID = uuid.New()
+	s.TenantID = repository.TenantFromContext(r.Context())
+	s.CreatedAt = time.Now().UTC()
+	if len(s.Secret) == 0 {
+		b := make([]byte, 32)
+		rand.Read(b)
+		s.Secret = hex.EncodeToString(b)
+	}
+
+	if err := h.Store.CreateSubscription(r.Context(), s); err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not create webhook: %s", err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusInternalServerError,
+		}).Warn("Failed to create webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	jsonEnc.Encode(s)
+
+	logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+		"event":  fmt.Sprintf("ID: %v", s.ID),
+		"status": http.StatusCreated,
+	}).Info("Webhook created")
+}
+
+// Checks the URL and event types of a subscription, reporting every
+// invalid one
+func validateSubscription(s repository.WebhookSubscription) []problem.FieldError {
+	var invalid []problem.FieldError
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid = append(invalid, problem.FieldError{Pointer: "/url", Reason: fmt.Sprintf("%q is not an absolute http(s) URL", s.URL)})
+	}
+	if len(s.Events) == 0 {
+		invalid = append(invalid, problem.FieldError{Pointer: "/events", Reason: "must not be empty"})
+	}
+	for i, e := range s.Events {
+		if e != webhooks.AllEvents && !slices.Contains(events.Types, events.Type(e)) {
+			invalid = append(invalid, problem.FieldError{Pointer: fmt.Sprintf("/events/%d", i), Reason: fmt.Sprintf("unknown event %q", e)})
+		}
+	}
+	return invalid
+}
+
+// GetAll webhooks
+// @Summary Get webhook subscriptions
+// @Description Get every webhook subscription, without their secret, reserved to admins
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []repository.WebhookSubscription
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /api/webhooks [get]
+func (h Webhook) GetAll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	if !h.authorize(w, r) {
+		return
+	}
+
+	subscriptions, err := h.Store.ListSubscriptions(r.Context())
+	if err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not get webhooks: %s", err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusInternalServerError,
+		}).Warn("Failed to get webhooks")
+		return
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+
+	w.WriteHeader(http.StatusOK)
+	jsonEnc.Encode(subscriptions)
+}
+
+// Delete webhook
+// @Summary Unsubscribe from customer events
+// @Description Delete a webhook subscription and its pending deliveries, reserved to admins
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Subscription id"
+// @Success 204 {object} nil
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Router /api/webhooks/{id} [delete]
+func (h Webhook) Delete(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, fmt.Sprintf("Invalid subscription ID format: %q", vars["id"]),
+			problem.FieldError{Parameter: "id", Reason: err.Error()})
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Failed to delete webhook")
+		return
+	}
+
+	if err := h.Store.DeleteSubscription(r.Context(), id); err != nil {
+		e := problem.New(http.StatusNotFound, problem.NotFound, "Subscription not found")
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"event":  fmt.Sprintf("ID: %v", id),
+			"status": http.StatusNotFound,
+		}).Info("Failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+		"event":  fmt.Sprintf("ID: %v", id),
+		"status": http.StatusNoContent,
+	}).Info("Webhook deleted")
+}
+
+// Deliveries
+// @Summary Get webhook deliveries
+// @Description Get webhook deliveries, the dead-letter list by default, reserved to admins
+// @Accept  json
+// @Produce  json
+// @Param status query string false "Delivery status, defaults to dead" "Enum: pending, delivered, dead"
+// @Success 200 {object} []repository.WebhookDelivery
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /api/webhooks/deliveries [get]
+func (h Webhook) Deliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	if !h.authorize(w, r) {
+		return
+	}
+
+	status := repository.DeliveryStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = repository.DeliveryDead
+	case repository.DeliveryPending, repository.DeliveryDelivered, repository.DeliveryDead:
+	default:
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, fmt.Sprintf("Invalid delivery status: %q", status),
+			problem.FieldError{Parameter: "status", Reason: "must be one of pending, delivered, dead"})
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Failed to get webhook deliveries")
+		return
+	}
+
+	deliveries, err := h.Store.ListDeliveries(r.Context(), status)
+	if err != nil {
+		e := problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not get webhook deliveries: %s", err.Error()))
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusInternalServerError,
+		}).Warn("Failed to get webhook deliveries")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	jsonEnc.Encode(deliveries)
+}
+
+// Redeliver
+// @Summary Redeliver a webhook delivery
+// @Description Send a dead or delivered delivery again with a fresh set of attempts, reserved to admins
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Delivery id"
+// @Param Idempotency-Key header string false "Key under which retries get the response of the first request"
+// @Success 202 {object} repository.WebhookDelivery
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Router /api/webhooks/deliveries/{id}/redeliver [post]
+func (h Webhook) Redeliver(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEnc := json.NewEncoder(w)
+
+	if !h.authorize(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	id, err := uuid.Parse(vars["id"])
+	if err != nil {
+		e := problem.New(http.StatusUnprocessableEntity, problem.InvalidParameter, fmt.Sprintf("Invalid delivery ID format: %q", vars["id"]),
+			problem.FieldError{Parameter: "id", Reason: err.Error()})
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"error_message": e.Detail,
+			"status":        http.StatusUnprocessableEntity,
+		}).Info("Redelivery failure")
+		return
+	}
+
+	delivery, err := h.Dispatcher.Redeliver(r.Context(), id)
+	if err != nil {
+		e := problem.New(http.StatusNotFound, problem.NotFound, "Delivery not found")
+		if errors.Is(err, webhooks.ErrNotRedeliverable) {
+			e = problem.New(http.StatusConflict, problem.Conflict, "Delivery is still pending")
+		}
+		e.Write(w, r)
+
+		logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+			"event":  fmt.Sprintf("ID: %v", id),
+			"status": e.Status,
+		}).Info("Redelivery failure")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	jsonEnc.Encode(delivery)
+
+	logging.FromContext(r.Context(), h.Logger).WithFields(logrus.Fields{
+		"event":  fmt.Sprintf("ID: %v", id),
+		"status": http.StatusAccepted,
+	}).Info("Webhook redelivery scheduled")
+}
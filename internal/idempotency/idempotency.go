@@ -0,0 +1,202 @@
+// Package idempotency replays the first response to the POST requests
+// sent again with the same Idempotency-Key, for clients to retry them
+// safely.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/logging"
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Header string = "Idempotency-Key"
+	// ReplayedHeader is set on the responses replayed from a previous
+	// request
+	ReplayedHeader string = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+// Keys records the responses to the requests carrying a Header for TTL,
+// their expired records being removed every Interval.
+type Keys struct {
+	Logger   *logrus.Logger
+	Store    repository.IdempotencyRepository
+	TTL      time.Duration
+	Interval time.Duration
+
+	now func() time.Time
+}
+
+func NewKeys(l *logrus.Logger, store repository.IdempotencyRepository, ttl, interval time.Duration) *Keys {
+	return &Keys{Logger: l, Store: store, TTL: ttl, Interval: interval, now: time.Now}
+}
+
+// Records the response it wraps while writing it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Middleware handles the first POST request with a given key of a client
+// and replays its response to the next ones. Keys reused for another
+// request are rejected with 422, and those of requests still in progress
+// with 409. Requests failing with a 5xx status, panicking or left without
+// a response release their key. It runs after auth.Middleware and the
+// tenant middleware.
+func (k *Keys) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			k.reject(w, r, problem.New(http.StatusBadRequest, problem.InvalidParameter, fmt.Sprintf("Invalid %s", Header),
+				problem.FieldError{Parameter: Header, Reason: fmt.Sprintf("must be at most %d characters long", maxKeyLength)}))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			k.reject(w, r, problem.New(http.StatusBadRequest, problem.InvalidPayload, fmt.Sprintf("Invalid request payload: %s", err.Error())))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := k.now()
+		rec := repository.IdempotencyRecord{
+			Client:      client(r),
+			Key:         key,
+			Fingerprint: fingerprint(r, body),
+			ExpiresAt:   now.Add(k.TTL),
+		}
+		existing, err := k.Store.ReserveIdempotencyKey(r.Context(), rec, now)
+		switch {
+		case err != nil:
+			k.reject(w, r, problem.New(http.StatusInternalServerError, problem.Internal, fmt.Sprintf("Could not reserve %s: %s", Header, err.Error())))
+			return
+		case existing == nil:
+		case existing.Fingerprint != rec.Fingerprint:
+			k.reject(w, r, problem.New(http.StatusUnprocessableEntity, problem.IdempotencyKeyReused, fmt.Sprintf("%s %q was used for another request", Header, key)))
+			return
+		case existing.Status == 0:
+			k.reject(w, r, problem.New(http.StatusConflict, problem.RequestInProgress, fmt.Sprintf("Request with %s %q still in progress", Header, key)))
+			return
+		default:
+			if existing.ContentType != "" {
+				w.Header().Set("Content-Type", existing.ContentType)
+			}
+			w.Header().Set(ReplayedHeader, "true")
+			w.WriteHeader(existing.Status)
+			w.Write(existing.Body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		panicked := true
+		// Deferred for the key to be released while a panic goes up
+		defer func() {
+			// The response is recorded even when the client went away
+			ctx := context.WithoutCancel(r.Context())
+			var err error
+			if panicked || recorder.status == 0 || recorder.status >= http.StatusInternalServerError {
+				err = k.Store.ReleaseIdempotencyKey(ctx, rec.Client, rec.Key)
+			} else {
+				rec.Status = recorder.status
+				rec.ContentType = w.Header().Get("Content-Type")
+				rec.Body = recorder.body.Bytes()
+				err = k.Store.CompleteIdempotencyKey(ctx, rec)
+			}
+			if err != nil {
+				logging.FromContext(r.Context(), k.Logger).WithFields(logrus.Fields{
+					"error_message": err.Error(),
+					"event":         fmt.Sprintf("Key: %s", key),
+				}).Warn("Failed to record idempotent response")
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+		panicked = false
+	})
+}
+
+// Run removes the expired keys every Interval until ctx is done.
+func (k *Keys) Run(ctx context.Context) {
+	ticker := time.NewTicker(k.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := k.Store.PurgeIdempotencyKeys(ctx, k.now())
+		if err != nil {
+			k.Logger.WithField("error", err.Error()).Warn("Idempotency keys purge failure")
+		} else if n > 0 {
+			k.Logger.WithField("event", fmt.Sprintf("%d keys expired", n)).Info("Idempotency keys purged")
+		}
+	}
+}
+
+func (k *Keys) reject(w http.ResponseWriter, r *http.Request, p *problem.Problem) {
+	p.Write(w, r)
+
+	logging.FromContext(r.Context(), k.Logger).WithFields(logrus.Fields{
+		"error_message": p.Error(),
+		"status":        p.Status,
+	}).Info("Idempotent request failure")
+}
+
+// Returns the tenant and user owning the keys sent with r, anonymous
+// callers sharing theirs
+func client(r *http.Request) string {
+	user := "anonymous"
+	if u, _ := auth.UserFromContext(r.Context()); u.ID != uuid.Nil {
+		user = u.ID.String()
+	}
+	return fmt.Sprintf("%s/%s", repository.TenantFromContext(r.Context()), user)
+}
+
+// Returns a digest of the target and payload of r
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,161 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Returns keys over an in-memory store and a handler creating a customer
+// per call, failing those with a "fail" payload, panicking on those with
+// a "panic" one and leaving those with a "gone" one without a response
+func newTestKeys(t *testing.T) (*Keys, http.Handler, *int) {
+	t.Helper()
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	keys := NewKeys(l, providers.NewInMemoryIdempotencyRepository(), time.Hour, time.Minute)
+	calls := 0
+	create := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch string(b) {
+		case "fail":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "panic":
+			panic(http.ErrAbortHandler)
+		case "gone":
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%q}`, uuid.New())
+	})
+	return keys, keys.Middleware(create), &calls
+}
+
+// Sends a POST of payload with key on behalf of user in tenant
+func post(h http.Handler, key, payload string, user uuid.UUID, tenant repository.TenantID) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/customers", strings.NewReader(payload))
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	ctx := repository.WithTenant(auth.WithUser(r.Context(), auth.User{ID: user}), tenant)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r.WithContext(ctx))
+	return rec
+}
+
+func TestReplay(t *testing.T) {
+	_, h, calls := newTestKeys(t)
+	user := uuid.New()
+
+	first := post(h, "k1", `{"name":"Jorge"}`, user, "acme")
+	retry := post(h, "k1", `{"name":"Jorge"}`, user, "acme")
+	if *calls != 1 {
+		t.Errorf("handler called %d times, want once", *calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() || retry.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry = %d %s, want the replayed %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("retry Content-Type = %q", retry.Header().Get("Content-Type"))
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		payload    string
+		user       uuid.UUID
+		tenant     repository.TenantID
+		wantStatus int
+		wantCalls  int
+	}{
+		{"another payload", "k1", `{"name":"Whatever Dude"}`, user, "acme", http.StatusUnprocessableEntity, 1},
+		{"another user", "k1", `{"name":"Jorge"}`, uuid.New(), "acme", http.StatusCreated, 2},
+		{"another tenant", "k1", `{"name":"Jorge"}`, user, "globex", http.StatusCreated, 3},
+		{"no key", "", `{"name":"Jorge"}`, user, "acme", http.StatusCreated, 4},
+		{"key too long", strings.Repeat("k", 256), `{"name":"Jorge"}`, user, "acme", http.StatusBadRequest, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := post(h, tt.key, tt.payload, tt.user, tt.tenant)
+			if rec.Code != tt.wantStatus || *calls != tt.wantCalls {
+				t.Errorf("POST = %d after %d calls, want %d after %d", rec.Code, *calls, tt.wantStatus, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestFailedRequestsReleaseKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+	}{
+		{"server error", "fail"},
+		{"panic", "panic"},
+		{"no response", "gone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, h, calls := newTestKeys(t)
+			user := uuid.New()
+			// Sends the payload, recovering the panics the way net/http does
+			send := func() {
+				defer func() { recover() }()
+				post(h, "k1", tt.payload, user, "acme")
+			}
+
+			send()
+			send()
+			if *calls != 2 {
+				t.Errorf("handler called %d times, want the retry handled again", *calls)
+			}
+		})
+	}
+}
+
+func TestInProgress(t *testing.T) {
+	keys, h, calls := newTestKeys(t)
+	user := uuid.New()
+
+	// A first request still being handled holds the key
+	keys.Store.ReserveIdempotencyKey(context.Background(), repository.IdempotencyRecord{
+		Client:      "acme/" + user.String(),
+		Key:         "k1",
+		Fingerprint: fingerprint(httptest.NewRequest(http.MethodPost, "/api/customers", nil), []byte("{}")),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}, time.Now())
+	if rec := post(h, "k1", "{}", user, "acme"); rec.Code != http.StatusConflict || *calls != 0 {
+		t.Errorf("POST = %d after %d calls, want %d", rec.Code, *calls, http.StatusConflict)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	keys, h, calls := newTestKeys(t)
+	user := uuid.New()
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+
+	post(h, "k1", "{}", user, "acme")
+	now = now.Add(2 * time.Hour)
+	if rec := post(h, "k1", `{"name":"Jorge"}`, user, "acme"); rec.Code != http.StatusCreated || *calls != 2 {
+		t.Errorf("POST with an expired key = %d after %d calls, want it handled", rec.Code, *calls)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if n, _ := keys.Store.PurgeIdempotencyKeys(context.Background(), now); n != 1 {
+		t.Errorf("PurgeIdempotencyKeys() = %d, want 1", n)
+	}
+}
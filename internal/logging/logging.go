@@ -0,0 +1,120 @@
+// Package logging scopes loggers to requests, logging one access line per
+// request, and redacts the personal data of log entries.
+package logging
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/requestid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// The logger of a request, completed by the middlewares it goes through
+type scope struct {
+	mu    sync.Mutex
+	entry *logrus.Entry
+}
+
+type key struct{}
+
+// WithLogger returns a copy of ctx whose logger is entry, see FromContext.
+func WithLogger(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, key{}, &scope{entry: entry})
+}
+
+// FromContext returns the logger of the request of ctx, carrying its
+// request id, route and principal, or the one of fallback when ctx
+// belongs to no request.
+func FromContext(ctx context.Context, fallback *logrus.Logger) *logrus.Entry {
+	if s, ok := ctx.Value(key{}).(*scope); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.entry.WithContext(ctx)
+	}
+	if fallback == nil {
+		fallback = logrus.StandardLogger()
+	}
+	return fallback.WithContext(ctx)
+}
+
+// AddFields adds fields to the logger of the request of ctx, and so to its
+// access log line. It does nothing when ctx belongs to no request.
+func AddFields(ctx context.Context, fields logrus.Fields) {
+	if s, ok := ctx.Value(key{}).(*scope); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.entry = s.entry.WithFields(fields)
+	}
+}
+
+// Records the status and size of the response it wraps
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Middleware gives every request a logger carrying its id, method and
+// route template, and logs one line per request once served with its
+// status, size in bytes, latency and principal. It runs after
+// requestid.Middleware, on a mux router.
+func Middleware(l *logrus.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := "unknown"
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+			ctx := WithLogger(r.Context(), l.WithFields(logrus.Fields{
+				"request_id": requestid.FromContext(r.Context()),
+				"method":     r.Method,
+				"route":      route,
+			}))
+
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			entry := FromContext(ctx, l).WithFields(logrus.Fields{
+				"status":     rec.status,
+				"bytes":      rec.bytes,
+				"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			})
+			if rec.status >= http.StatusInternalServerError {
+				entry.Warn("Request served")
+			} else {
+				entry.Info("Request served")
+			}
+		})
+	}
+}
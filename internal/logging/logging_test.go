@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EdmundHusserl/CRM/internal/requestid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestMiddleware(t *testing.T) {
+	l, hook := test.NewNullLogger()
+	router := mux.NewRouter()
+	router.Use(requestid.Middleware, Middleware(l))
+	router.HandleFunc("/api/customers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		AddFields(r.Context(), logrus.Fields{"principal": "jorge"})
+		FromContext(r.Context(), nil).Info("Customer found")
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "short and stout")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/customers/42", nil)
+	req.Header.Set(requestid.Header, "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := hook.AllEntries()
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	scoped := logrus.Fields{"request_id": "req-1", "method": http.MethodGet, "route": "/api/customers/{id}", "principal": "jorge"}
+	for _, e := range entries {
+		for k, v := range scoped {
+			if e.Data[k] != v {
+				t.Errorf("%q logged %s = %v, want %v", e.Message, k, e.Data[k], v)
+			}
+		}
+	}
+	access := entries[1]
+	if access.Message != "Request served" || access.Data["status"] != http.StatusTeapot || access.Data["bytes"] != 15 {
+		t.Errorf("access line = %q %v", access.Message, access.Data)
+	}
+	if _, ok := access.Data["latency_ms"].(float64); !ok {
+		t.Errorf("latency_ms = %v, want milliseconds", access.Data["latency_ms"])
+	}
+}
+
+func TestFromContextWithoutRequest(t *testing.T) {
+	l, hook := test.NewNullLogger()
+	AddFields(t.Context(), logrus.Fields{"principal": "jorge"})
+	FromContext(t.Context(), l).Info("Purged")
+	if e := hook.LastEntry(); e == nil || len(e.Data) != 0 {
+		t.Errorf("logged %v, want the fallback logger", e)
+	}
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redaction policies
+const (
+	// RedactMask keeps the first letter and domain of e-mail addresses and
+	// the last two digits of phone numbers
+	RedactMask string = "mask"
+	// RedactHash replaces them by a digest, equal for equal values
+	RedactHash string = "hash"
+	RedactNone string = "none"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// Phone numbers are told apart from dates and ids by their leading +,
+	// their area code in parentheses or their 3-3-4 digit groups. Those
+	// written otherwise, such as 5550100142, are only redacted from the
+	// fields known to hold one.
+	phonePattern = regexp.MustCompile(`\+[0-9][0-9 \-()]{5,}[0-9]|\([0-9]{2,5}\) ?[0-9][0-9 \-]{4,}[0-9]|\b[0-9]{3}[\-. ][0-9]{3}[\-. ][0-9]{4}\b`)
+)
+
+// Fields holding an e-mail address or phone number, whatever their format
+var personalFields = map[string]func(p, s string) string{
+	"email":        redactEmail,
+	"phone":        redactPhone,
+	"phone_number": redactPhone,
+}
+
+// Redactor is a hook redacting the e-mail addresses and phone numbers of
+// the message and fields of log entries according to its Policy.
+type Redactor struct {
+	Policy string
+}
+
+func (Redactor) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h Redactor) Fire(e *logrus.Entry) error {
+	if h.Policy == RedactNone {
+		return nil
+	}
+	e.Message = h.redact(e.Message)
+	for k, v := range e.Data {
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case error:
+			s = v.Error()
+		default:
+			continue
+		}
+		if redact, ok := personalFields[k]; ok {
+			e.Data[k] = redact(h.Policy, s)
+		} else {
+			e.Data[k] = h.redact(s)
+		}
+	}
+	return nil
+}
+
+// Redacts the e-mail addresses and phone numbers found in s
+func (h Redactor) redact(s string) string {
+	s = emailPattern.ReplaceAllStringFunc(s, func(m string) string { return redactEmail(h.Policy, m) })
+	return phonePattern.ReplaceAllStringFunc(s, func(m string) string { return redactPhone(h.Policy, m) })
+}
+
+func redactEmail(policy, s string) string {
+	if policy == RedactHash {
+		return digest(strings.ToLower(s))
+	}
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok || local == "" {
+		return "***"
+	}
+	return local[:1] + "***@" + domain
+}
+
+func redactPhone(policy, s string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	if policy == RedactHash {
+		return digest(digits)
+	}
+	if len(digits) < 4 {
+		return "***"
+	}
+	return "***" + digits[len(digits)-2:]
+}
+
+func digest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
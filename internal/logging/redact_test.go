@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRedactor(t *testing.T) {
+	tests := []struct {
+		policy      string
+		message     string
+		fields      logrus.Fields
+		wantMessage string
+		wantFields  logrus.Fields
+	}{
+		{
+			RedactMask,
+			"Customer jorge@corp.com reached at +1 (555) 010-0142 on 2024-05-01",
+			logrus.Fields{"email": "Jorge@Corp.com", "phone_number": "555 0142", "error": errors.New("ana@corp.com is taken"), "status": 409},
+			"Customer j***@corp.com reached at ***42 on 2024-05-01",
+			logrus.Fields{"email": "J***@Corp.com", "phone_number": "***42", "error": "a***@corp.com is taken", "status": 409},
+		},
+		{
+			RedactHash,
+			"Customer jorge@corp.com reached at +1 555 010 0142",
+			logrus.Fields{"email": "Jorge@Corp.com", "phone": "+15550100142"},
+			"Customer sha256:14c8a7e6c56a reached at sha256:0e9819082119",
+			logrus.Fields{"email": "sha256:14c8a7e6c56a", "phone": "sha256:0e9819082119"},
+		},
+		{
+			RedactNone,
+			"Customer jorge@corp.com reached at +1 555 010 0142",
+			logrus.Fields{"email": "jorge@corp.com"},
+			"Customer jorge@corp.com reached at +1 555 010 0142",
+			logrus.Fields{"email": "jorge@corp.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			e := &logrus.Entry{Message: tt.message, Data: tt.fields}
+			Redactor{Policy: tt.policy}.Fire(e)
+			if e.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", e.Message, tt.wantMessage)
+			}
+			for k, v := range tt.wantFields {
+				if e.Data[k] != v {
+					t.Errorf("%s = %v, want %v", k, e.Data[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactPhoneFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"International", "Call +49 30 1234567", "Call ***67"},
+		{"Area code in parentheses", "Call (030) 1234567", "Call ***67"},
+		{"Dashes", "Call 555-123-4567", "Call ***67"},
+		{"Dots", "Call 555.123.4567 now", "Call ***67 now"},
+		{"Spaces", "Call 555 123 4567", "Call ***67"},
+		{"Date", "Created on 2024-05-01 at 12:30:45", "Created on 2024-05-01 at 12:30:45"},
+		{"Id", "Request 5f0c6d2e-1234-4567-8901-123456789012 failed", "Request 5f0c6d2e-1234-4567-8901-123456789012 failed"},
+		{"Address", "Listening on 192.168.100.12:3000", "Listening on 192.168.100.12:3000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Redactor{Policy: RedactMask}).redact(tt.message); got != tt.want {
+				t.Errorf("redact(%q) = %q, want %q", tt.message, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.HandleFunc("/customers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("{}"))
+	}).Methods(http.MethodGet)
+
+	tests := []struct {
+		name   string
+		path   string
+		status string
+		want   float64
+	}{
+		{name: "Found", path: "/customers/1", status: "200", want: 1},
+		{name: "Found_again", path: "/customers/2", status: "200", want: 2},
+		{name: "Not_found", path: "/customers/missing", status: "404", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+			got := testutil.ToFloat64(httpRequests.WithLabelValues("/customers/{id}", http.MethodGet, tt.status))
+			if got != tt.want {
+				t.Errorf("http_requests_total = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Keeps customers in a map, enough for the instrumented operations
+type mapRepository struct {
+	repository.CustomerRepository
+	customers map[uuid.UUID]repository.Customer
+}
+
+func (r *mapRepository) Create(ctx context.Context, c repository.Customer) error {
+	r.customers[c.ID] = c
+	return nil
+}
+
+func (r *mapRepository) Get(ctx context.Context, id uuid.UUID) (*repository.Customer, error) {
+	c, ok := r.customers[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &c, nil
+}
+
+func (r *mapRepository) CountByRole(ctx context.Context) (map[repository.CustomerRole]int, error) {
+	counts := map[repository.CustomerRole]int{}
+	for _, c := range r.customers {
+		counts[c.Role]++
+	}
+	return counts, nil
+}
+
+func TestRepository(t *testing.T) {
+	repo := NewRepository(&mapRepository{customers: map[uuid.UUID]repository.Customer{}}, "test")
+	ctx := context.Background()
+	id := uuid.New()
+
+	repo.Create(ctx, repository.Customer{ID: id, Name: "Willy", Email: "willy@corp.com", Role: repository.Premium})
+	repo.Get(ctx, id)
+	repo.Get(ctx, uuid.New())
+
+	if got := testutil.ToFloat64(repositoryErrors.WithLabelValues("test", "get")); got != 1 {
+		t.Errorf("get errors = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(repositoryErrors.WithLabelValues("test", "create")); got != 0 {
+		t.Errorf("create errors = %v, want 0", got)
+	}
+
+	want := `
+# HELP crm_customers Live customers by role.
+# TYPE crm_customers gauge
+crm_customers{role="0"} 0
+crm_customers{role="1"} 1
+crm_customers{role="2"} 0
+`
+	if err := testutil.CollectAndCompare(NewCustomerCollector(repo), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Repository times the operations of a CustomerRepository and counts
+// their errors, labelled with the name of its provider.
+type Repository struct {
+	repo     repository.CustomerRepository
+	provider string
+}
+
+func NewRepository(repo repository.CustomerRepository, provider string) *Repository {
+	return &Repository{repo: repo, provider: provider}
+}
+
+// Unwrap returns the instrumented repository.
+func (r *Repository) Unwrap() repository.CustomerRepository {
+	return r.repo
+}
+
+// Records an operation started at start, failed when err is not nil
+func (r *Repository) observe(operation string, start time.Time, err error) {
+	repositoryDuration.WithLabelValues(r.provider, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		repositoryErrors.WithLabelValues(r.provider, operation).Inc()
+	}
+}
+
+func (r *Repository) Assign(ctx context.Context, id, ownerID uuid.UUID) (err error) {
+	defer func(start time.Time) { r.observe("assign", start, err) }(time.Now())
+	return r.repo.Assign(ctx, id, ownerID)
+}
+
+func (r *Repository) AuditEntries(ctx context.Context, f repository.AuditFilter) (entries []repository.AuditEntry, err error) {
+	defer func(start time.Time) { r.observe("audit_entries", start, err) }(time.Now())
+	return r.repo.AuditEntries(ctx, f)
+}
+
+func (r *Repository) CloseDBConnection() error {
+	return r.repo.CloseDBConnection()
+}
+
+func (r *Repository) CountByRole(ctx context.Context) (counts map[repository.CustomerRole]int, err error) {
+	defer func(start time.Time) { r.observe("count_by_role", start, err) }(time.Now())
+	return r.repo.CountByRole(ctx)
+}
+
+func (r *Repository) Create(ctx context.Context, c repository.Customer) (err error) {
+	defer func(start time.Time) { r.observe("create", start, err) }(time.Now())
+	return r.repo.Create(ctx, c)
+}
+
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) (err error) {
+	defer func(start time.Time) { r.observe("delete", start, err) }(time.Now())
+	return r.repo.Delete(ctx, id)
+}
+
+func (r *Repository) Get(ctx context.Context, id uuid.UUID) (c *repository.Customer, err error) {
+	defer func(start time.Time) { r.observe("get", start, err) }(time.Now())
+	return r.repo.Get(ctx, id)
+}
+
+func (r *Repository) GetAll(ctx context.Context, f repository.CustomerFilter) (customers []repository.Customer, err error) {
+	defer func(start time.Time) { r.observe("get_all", start, err) }(time.Now())
+	return r.repo.GetAll(ctx, f)
+}
+
+func (r *Repository) Ping(ctx context.Context) (err error) {
+	defer func(start time.Time) { r.observe("ping", start, err) }(time.Now())
+	return r.repo.Ping(ctx)
+}
+
+func (r *Repository) Purge(ctx context.Context, deletedBefore time.Time) (n int, err error) {
+	defer func(start time.Time) { r.observe("purge", start, err) }(time.Now())
+	return r.repo.Purge(ctx, deletedBefore)
+}
+
+func (r *Repository) Reassign(ctx context.Context, from, to uuid.UUID) (n int, err error) {
+	defer func(start time.Time) { r.observe("reassign", start, err) }(time.Now())
+	return r.repo.Reassign(ctx, from, to)
+}
+
+func (r *Repository) Restore(ctx context.Context, id uuid.UUID) (err error) {
+	defer func(start time.Time) { r.observe("restore", start, err) }(time.Now())
+	return r.repo.Restore(ctx, id)
+}
+
+func (r *Repository) Update(ctx context.Context, c repository.Customer) (err error) {
+	defer func(start time.Time) { r.observe("update", start, err) }(time.Now())
+	return r.repo.Update(ctx, c)
+}
+
+// CustomerCollector exports the number of live customers per role,
+// counted at scrape time.
+type CustomerCollector struct {
+	repo    repository.CustomerRepository
+	timeout time.Duration
+	desc    *prometheus.Desc
+}
+
+func NewCustomerCollector(repo repository.CustomerRepository) *CustomerCollector {
+	return &CustomerCollector{
+		repo:    repo,
+		timeout: 5 * time.Second,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "customers"),
+			"Live customers by role.",
+			[]string{"role"}, nil),
+	}
+}
+
+func (c *CustomerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *CustomerCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	counts, err := c.repo.CountByRole(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	for _, role := range []repository.CustomerRole{repository.Basic, repository.Premium, repository.Partner} {
+		if _, ok := counts[role]; !ok {
+			counts[role] = 0
+		}
+	}
+	for role, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count), strconv.Itoa(int(role)))
+	}
+}
@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Relay publishes pending outbox messages to every sink. Messages of a
+// customer are sent in the order they were recorded: once one fails, the
+// following ones wait until it goes through, behind the messages of the
+// other customers.
+type Relay struct {
+	Logger       *logrus.Logger
+	Store        repository.OutboxRepository
+	Sinks        []Sink
+	PollInterval time.Duration
+	MaxBackoff   time.Duration
+	BatchSize    int
+
+	mu sync.Mutex
+	// Sinks which already accepted a message still pending, so that
+	// retries only go to the ones that failed
+	sent map[int64]map[int]bool
+}
+
+func NewRelay(l *logrus.Logger, store repository.OutboxRepository, sinks ...Sink) *Relay {
+	return &Relay{
+		Logger:       l,
+		Store:        store,
+		Sinks:        sinks,
+		PollInterval: 250 * time.Millisecond,
+		MaxBackoff:   time.Minute,
+		BatchSize:    100,
+		sent:         map[int64]map[int]bool{},
+	}
+}
+
+// Run relays pending messages until ctx is done, backing off while sinks
+// keep failing. The batch being relayed when ctx is done is completed,
+// and one more is relayed to flush the messages recorded meanwhile.
+func (r *Relay) Run(ctx context.Context) {
+	work := context.WithoutCancel(ctx)
+	defer r.RelayOnce(work)
+
+	delay := r.PollInterval
+	for {
+		n, err := r.RelayOnce(work)
+		switch {
+		case err != nil:
+			r.Logger.WithField("error", err.Error()).Warn("Outbox relay failure")
+			delay = min(2*delay, r.MaxBackoff)
+		case n == r.BatchSize && ctx.Err() == nil:
+			// Drain the backlog before waiting again
+			continue
+		default:
+			delay = r.PollInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// RelayOnce sends one batch of pending messages and returns how many
+// were published. The error joins the failures of every sink.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var failures []error
+	n, err := r.Store.RelayOutbox(ctx, r.BatchSize, func(messages []repository.OutboxMessage) map[int64]error {
+		results := make(map[int64]error, len(messages))
+		blocked := map[uuid.UUID]bool{}
+		for _, m := range messages {
+			if blocked[m.AggregateID] {
+				continue
+			}
+			if err := r.send(ctx, m); err != nil {
+				blocked[m.AggregateID] = true
+				failures = append(failures, fmt.Errorf("%s %v: %w", m.EventType, m.EventID, err))
+				results[m.ID] = err
+				continue
+			}
+			results[m.ID] = nil
+		}
+		return results
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, errors.Join(failures...)
+}
+
+// Sends m to the sinks which have not accepted it yet
+func (r *Relay) send(ctx context.Context, m repository.OutboxMessage) error {
+	done := r.sent[m.ID]
+	var errs []error
+	for i, s := range r.Sinks {
+		if done[i] {
+			continue
+		}
+		if err := s.Send(ctx, m); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if done == nil {
+			done = map[int]bool{}
+		}
+		done[i] = true
+	}
+	if len(errs) > 0 {
+		r.sent[m.ID] = done
+		return errors.Join(errs...)
+	}
+	delete(r.sent, m.ID)
+	return nil
+}
@@ -0,0 +1,252 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Keeps messages in memory the way the Postgres outbox does
+type store struct {
+	messages  []repository.OutboxMessage
+	published map[int64]bool
+}
+
+// Returns the pending messages of s, those of the customers held back by
+// a failure last
+func (s *store) pending() []repository.OutboxMessage {
+	blocked := map[uuid.UUID]bool{}
+	var fresh, held []repository.OutboxMessage
+	for _, m := range s.messages {
+		if s.published[m.ID] {
+			continue
+		}
+		if m.Attempts > 0 {
+			blocked[m.AggregateID] = true
+		}
+		if blocked[m.AggregateID] {
+			held = append(held, m)
+			continue
+		}
+		fresh = append(fresh, m)
+	}
+	return append(fresh, held...)
+}
+
+func (s *store) add(customerID uuid.UUID, t events.Type) repository.OutboxMessage {
+	m, _ := NewMessage(events.Event{ID: uuid.New(), Type: t, CustomerID: customerID})
+	m.ID = int64(len(s.messages) + 1)
+	s.messages = append(s.messages, m)
+	return m
+}
+
+func (s *store) RelayOutbox(ctx context.Context, limit int, send func([]repository.OutboxMessage) map[int64]error) (int, error) {
+	pending := s.pending()
+	pending = pending[:min(limit, len(pending))]
+	n := 0
+	for id, err := range send(pending) {
+		if err != nil {
+			s.messages[id-1].Attempts++
+			continue
+		}
+		s.published[id] = true
+		n++
+	}
+	return n, nil
+}
+
+// Fails the messages listed in failing once each
+type flakySink struct {
+	failing map[uuid.UUID]bool
+	sent    []uuid.UUID
+}
+
+func (s *flakySink) Send(ctx context.Context, m repository.OutboxMessage) error {
+	if s.failing[m.EventID] {
+		delete(s.failing, m.EventID)
+		return errors.New("unavailable")
+	}
+	s.sent = append(s.sent, m.EventID)
+	return nil
+}
+
+func TestRelayOrdering(t *testing.T) {
+	first, second := uuid.New(), uuid.New()
+	st := &store{published: map[int64]bool{}}
+	a1 := st.add(first, events.CustomerCreated)
+	b1 := st.add(second, events.CustomerCreated)
+	a2 := st.add(first, events.CustomerUpdated)
+	b2 := st.add(second, events.CustomerDeleted)
+
+	sink := &flakySink{failing: map[uuid.UUID]bool{a1.EventID: true}}
+	relay := NewRelay(logrus.New(), st, sink)
+
+	tests := []struct {
+		name          string
+		wantPublished int
+		wantErr       bool
+		wantSent      []uuid.UUID
+	}{
+		{
+			name:          "Failure_holds_back_the_customer",
+			wantPublished: 2,
+			wantErr:       true,
+			wantSent:      []uuid.UUID{b1.EventID, b2.EventID},
+		},
+		{
+			name:          "Retry_keeps_order",
+			wantPublished: 2,
+			wantSent:      []uuid.UUID{b1.EventID, b2.EventID, a1.EventID, a2.EventID},
+		},
+		{
+			name:          "Nothing_left",
+			wantPublished: 0,
+			wantSent:      []uuid.UUID{b1.EventID, b2.EventID, a1.EventID, a2.EventID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := relay.RelayOnce(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RelayOnce() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n != tt.wantPublished {
+				t.Errorf("RelayOnce() = %d, want %d", n, tt.wantPublished)
+			}
+			if !slices.Equal(sink.sent, tt.wantSent) {
+				t.Errorf("sent %v, want %v", sink.sent, tt.wantSent)
+			}
+		})
+	}
+}
+
+// Fails every message of the customers listed in down
+type downSink struct {
+	down map[uuid.UUID]bool
+	sent []uuid.UUID
+}
+
+func (s *downSink) Send(ctx context.Context, m repository.OutboxMessage) error {
+	if s.down[m.AggregateID] {
+		return errors.New("unavailable")
+	}
+	s.sent = append(s.sent, m.EventID)
+	return nil
+}
+
+func TestRelayBlockedBacklog(t *testing.T) {
+	failing, healthy := uuid.New(), uuid.New()
+	st := &store{published: map[int64]bool{}}
+	for range 5 {
+		st.add(failing, events.CustomerUpdated)
+	}
+	m := st.add(healthy, events.CustomerCreated)
+
+	sink := &downSink{down: map[uuid.UUID]bool{failing: true}}
+	relay := NewRelay(logrus.New(), st, sink)
+	relay.BatchSize = 2
+
+	// The first batch only holds messages of the failing customer
+	if n, err := relay.RelayOnce(context.Background()); err == nil || n != 0 {
+		t.Fatalf("RelayOnce() = %d, %v, want 0 and the sink failure", n, err)
+	}
+	if n, _ := relay.RelayOnce(context.Background()); n != 1 {
+		t.Fatalf("RelayOnce() = %d, want 1", n)
+	}
+	if !slices.Equal(sink.sent, []uuid.UUID{m.EventID}) {
+		t.Errorf("sent %v, want %v", sink.sent, []uuid.UUID{m.EventID})
+	}
+}
+
+func TestRelayRetriesFailedSinksOnly(t *testing.T) {
+	st := &store{published: map[int64]bool{}}
+	m := st.add(uuid.New(), events.CustomerCreated)
+
+	healthy := &flakySink{}
+	flaky := &flakySink{failing: map[uuid.UUID]bool{m.EventID: true}}
+	nats := &FakeNATS{}
+	relay := NewRelay(logrus.New(), st, healthy, flaky, NATSSink{Conn: nats, Prefix: "crm."})
+
+	if _, err := relay.RelayOnce(context.Background()); err == nil {
+		t.Fatal("RelayOnce() succeeded, want the flaky sink failure")
+	}
+	if n, err := relay.RelayOnce(context.Background()); err != nil || n != 1 {
+		t.Fatalf("RelayOnce() = %d, %v, want 1, nil", n, err)
+	}
+
+	if len(healthy.sent) != 1 || len(flaky.sent) != 1 {
+		t.Errorf("sent %d and %d times, want once to each sink", len(healthy.sent), len(flaky.sent))
+	}
+	published := nats.Published()
+	if len(published) != 1 || published[0].Subject != "crm.customer.created" {
+		t.Errorf("NATS messages %v, want one on crm.customer.created", published)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "Accepted", status: http.StatusNoContent},
+		{name: "Rejected", status: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got events.Event
+			var header string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header.Get(EventIDHeader)
+				payload, _ := io.ReadAll(r.Body)
+				json.Unmarshal(payload, &got)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			m, _ := NewMessage(events.Event{ID: uuid.New(), Type: events.CustomerUpdated, CustomerID: uuid.New()})
+			err := NewHTTPSink(srv.URL).Send(context.Background(), m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.ID != m.EventID || header != m.EventID.String() {
+				t.Errorf("received event %v with header %q, want %v", got.ID, header, m.EventID)
+			}
+		})
+	}
+}
+
+func TestParseSinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    int
+		wantErr bool
+	}{
+		{name: "Empty", spec: "", want: 0},
+		{name: "Log_and_HTTP", spec: "log, http=https://example.com/events", want: 2},
+		{name: "HTTP_without_URL", spec: "http", wantErr: true},
+		{name: "Unknown", spec: "kafka=broker:9092", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sinks, err := ParseSinks(logrus.New(), tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSinks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(sinks) != tt.want {
+				t.Errorf("ParseSinks() returned %d sinks, want %d", len(sinks), tt.want)
+			}
+		})
+	}
+}
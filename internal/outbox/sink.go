@@ -0,0 +1,203 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Header carrying the event ID so that receivers can drop duplicates
+const EventIDHeader string = "X-CRM-Event-ID"
+
+// Sink receives relayed messages. Delivery is at-least-once: a message
+// may be sent again after a failure or a restart, receivers are expected
+// to deduplicate on the event ID.
+type Sink interface {
+	Send(ctx context.Context, m repository.OutboxMessage) error
+}
+
+// LogSink writes every message to the log.
+type LogSink struct {
+	Logger *logrus.Logger
+}
+
+func (s LogSink) Send(ctx context.Context, m repository.OutboxMessage) error {
+	s.Logger.WithFields(logrus.Fields{
+		"event":    fmt.Sprintf("%s %v", m.EventType, m.EventID),
+		"customer": m.AggregateID.String(),
+		"payload":  string(m.Payload),
+	}).Info("Outbox message")
+	return nil
+}
+
+// HTTPSink POSTs every message to URL, any non 2xx response is an error.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{URL: url, Client: &http.Client{Timeout: 10 * time.Second, Transport: otelhttp.NewTransport(http.DefaultTransport)}}
+}
+
+func (s *HTTPSink) Send(ctx context.Context, m repository.OutboxMessage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(m.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CRM-Outbox/1.0")
+	req.Header.Set(EventIDHeader, m.EventID.String())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded %s", s.URL, resp.Status)
+	}
+	return nil
+}
+
+// NATSConn is the subset of a NATS connection the NATSSink needs,
+// satisfied by *nats.Conn.
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATSSink publishes every message on Prefix followed by the event type,
+// e.g. "crm.customer.created".
+type NATSSink struct {
+	Conn   NATSConn
+	Prefix string
+}
+
+func (s NATSSink) Send(ctx context.Context, m repository.OutboxMessage) error {
+	return s.Conn.Publish(s.Prefix+m.EventType, m.Payload)
+}
+
+// NATSMessage is a message published on a FakeNATS.
+type NATSMessage struct {
+	Subject string
+	Data    []byte
+}
+
+// FakeNATS is an in-memory NATSConn recording what is published on it.
+// Err, when set, is returned instead of recording.
+type FakeNATS struct {
+	mu       sync.Mutex
+	Messages []NATSMessage
+	Err      error
+}
+
+func (f *FakeNATS) Publish(subject string, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+	f.Messages = append(f.Messages, NATSMessage{Subject: subject, Data: data})
+	return nil
+}
+
+// Published returns a copy of the messages recorded so far.
+func (f *FakeNATS) Published() []NATSMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]NATSMessage(nil), f.Messages...)
+}
+
+// BusSink feeds relayed messages back into the in-process event bus so
+// that webhooks and change streams see them.
+type BusSink struct {
+	Bus events.Publisher
+}
+
+func (s BusSink) Send(ctx context.Context, m repository.OutboxMessage) error {
+	var e events.Event
+	if err := json.Unmarshal(m.Payload, &e); err != nil {
+		return fmt.Errorf("decoding event %v: %w", m.EventID, err)
+	}
+	s.Bus.Publish(ctx, e)
+	return nil
+}
+
+// NewMessage returns the outbox message describing e.
+func NewMessage(e events.Event) (repository.OutboxMessage, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return repository.OutboxMessage{}, err
+	}
+	return repository.OutboxMessage{
+		AggregateID: e.CustomerID,
+		EventID:     e.ID,
+		EventType:   string(e.Type),
+		Payload:     payload,
+		CreatedAt:   e.OccurredAt,
+	}, nil
+}
+
+// Publisher sends the events published to it to Sink, for repositories
+// without an outbox. Failures are logged and not retried.
+type Publisher struct {
+	Logger *logrus.Logger
+	Sink   Sink
+}
+
+func (p Publisher) Publish(ctx context.Context, e events.Event) {
+	m, err := NewMessage(e)
+	if err == nil {
+		err = p.Sink.Send(ctx, m)
+	}
+	if err != nil {
+		p.Logger.WithFields(logrus.Fields{
+			"event": fmt.Sprintf("%s %v", e.Type, e.ID),
+			"error": err.Error(),
+		}).Warn("Failed to publish event")
+	}
+}
+
+// ParseSinks builds the sinks listed in spec, a comma separated list of
+// "log" and "http=<url>" entries.
+func ParseSinks(l *logrus.Logger, spec string) ([]Sink, error) {
+	var sinks []Sink
+	for _, entry := range strings.Split(spec, ",") {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		switch kind {
+		case "":
+		case "log":
+			sinks = append(sinks, LogSink{Logger: l})
+		case "http":
+			if !strings.HasPrefix(arg, "http://") && !strings.HasPrefix(arg, "https://") {
+				return nil, fmt.Errorf("http sink needs an http(s) URL, got %q", arg)
+			}
+			sinks = append(sinks, NewHTTPSink(arg))
+		default:
+			return nil, fmt.Errorf("unknown sink %q", kind)
+		}
+	}
+	return sinks, nil
+}
+
+// NewSinks returns the sinks listed in spec, see ParseSinks.
+func NewSinks(l *logrus.Logger, spec string) []Sink {
+	sinks, err := ParseSinks(l, spec)
+	if err != nil {
+		l.WithField("error", err.Error()).Fatal(fmt.Sprintf("error parsing outbox sinks %q", spec))
+	}
+	return sinks
+}
@@ -0,0 +1,133 @@
+// Package problem writes the errors of the API as problem details
+// (RFC 9457), served as application/problem+json. Every problem carries a
+// stable Code, clients being expected to branch on it rather than on the
+// human readable title and detail.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/EdmundHusserl/CRM/internal/validate"
+)
+
+const (
+	ContentType string = "application/problem+json"
+	// TypeBase prefixes the code of a problem to form its type URI
+	TypeBase string = "https://github.com/EdmundHusserl/CRM/blob/main/docs/problems.md#"
+)
+
+// Code identifies a kind of problem, see docs/problems.md.
+type Code string
+
+const (
+	InvalidPayload       Code = "invalid_payload"
+	InvalidParameter     Code = "invalid_parameter"
+	ValidationFailed     Code = "validation_failed"
+	Unauthenticated      Code = "unauthenticated"
+	Forbidden            Code = "forbidden"
+	NotFound             Code = "not_found"
+	MethodNotAllowed     Code = "method_not_allowed"
+	Conflict             Code = "conflict"
+	RequestInProgress    Code = "request_in_progress"
+	IdempotencyKeyReused Code = "idempotency_key_reused"
+	InvalidTenant        Code = "invalid_tenant"
+	RateLimited          Code = "rate_limited"
+	QuotaExceeded        Code = "quota_exceeded"
+	Maintenance          Code = "maintenance"
+	Internal             Code = "internal_error"
+)
+
+var titles = map[Code]string{
+	InvalidPayload:       "Invalid payload",
+	InvalidParameter:     "Invalid parameter",
+	ValidationFailed:     "Validation failed",
+	Unauthenticated:      "Authentication required",
+	Forbidden:            "Forbidden",
+	NotFound:             "Not found",
+	MethodNotAllowed:     "Method not allowed",
+	Conflict:             "Conflict",
+	RequestInProgress:    "Request in progress",
+	IdempotencyKeyReused: "Idempotency key reused",
+	InvalidTenant:        "Invalid tenant",
+	RateLimited:          "Rate limit exceeded",
+	QuotaExceeded:        "Daily quota exceeded",
+	Maintenance:          "Under maintenance",
+	Internal:             "Internal error",
+}
+
+// FieldError is an invalid member of the payload, designated by Pointer,
+// or an invalid query, path or header Parameter.
+type FieldError struct {
+	// JSON pointer of the member, such as /email or /2/phone_number
+	Pointer   string `json:"pointer,omitempty" example:"/email"`
+	Parameter string `json:"parameter,omitempty"`
+	Reason    string `json:"reason" example:"\"jorge@\" is not an e-mail address"`
+}
+
+type Problem struct {
+	Type     string       `json:"type" example:"https://github.com/EdmundHusserl/CRM/blob/main/docs/problems.md#validation_failed"`
+	Title    string       `json:"title" example:"Validation failed"`
+	Status   int          `json:"status" example:"422"`
+	Detail   string       `json:"detail,omitempty" example:"Invalid customer"`
+	Instance string       `json:"instance,omitempty" example:"/api/customers"`
+	Code     Code         `json:"code" example:"validation_failed"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+func New(status int, code Code, detail string, errs ...FieldError) *Problem {
+	return &Problem{
+		Type:   TypeBase + string(code),
+		Title:  titles[code],
+		Status: status,
+		Detail: detail,
+		Code:   code,
+		Errors: errs,
+	}
+}
+
+func (p *Problem) Error() string {
+	msg := fmt.Sprintf("%d %s: %s", p.Status, p.Code, p.Detail)
+	for _, e := range p.Errors {
+		msg += fmt.Sprintf(", %s%s: %s", e.Pointer, e.Parameter, e.Reason)
+	}
+	return msg
+}
+
+// Write writes p as the response to r, its instance being the path of r
+// unless set.
+func (p *Problem) Write(w http.ResponseWriter, r *http.Request) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Fields returns the field errors of a validation error, their pointers
+// under prefix. Other errors are reported on prefix itself.
+func Fields(prefix string, err error) []FieldError {
+	var errs validate.Errors
+	if !errors.As(err, &errs) {
+		return []FieldError{{Pointer: prefix, Reason: err.Error()}}
+	}
+	fields := make([]FieldError, len(errs))
+	for i, e := range errs {
+		fields[i] = FieldError{Pointer: prefix + e.Pointer, Reason: e.Reason}
+	}
+	return fields
+}
+
+// NotFoundHandler answers the requests matching no route.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	New(http.StatusNotFound, NotFound, fmt.Sprintf("No route for %s", r.URL.Path)).Write(w, r)
+}
+
+// MethodNotAllowedHandler answers the requests whose route does not
+// accept their method.
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	New(http.StatusMethodNotAllowed, MethodNotAllowed, fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path)).Write(w, r)
+}
@@ -0,0 +1,103 @@
+// Package ratelimit bounds the requests of every client with token buckets,
+// per route when asked to, and with daily quotas.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit lets bursts of Burst requests through, the bucket refilling at Rate
+// requests per second. The zero Limit is unlimited.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+var units = map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+
+// ParseLimit reads limits such as 20/s, 600/m or 5/s:20, the burst
+// following the colon defaulting to the number of requests per unit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Limit{}, nil
+	}
+	spec, burst, hasBurst := strings.Cut(s, ":")
+	n, unit, ok := strings.Cut(spec, "/")
+	per, known := units[unit]
+	requests, err := strconv.Atoi(n)
+	if !ok || !known || err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q, use <requests>/<s|m|h>[:<burst>] such as 20/s", s)
+	}
+	l := Limit{Rate: float64(requests) / per.Seconds(), Burst: requests}
+	if hasBurst {
+		if l.Burst, err = strconv.Atoi(burst); err != nil || l.Burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid burst %q of limit %q, use a positive integer", burst, s)
+		}
+	}
+	return l, nil
+}
+
+// ParseRoutes reads comma separated limits of routes, such as
+// "POST /api/customers=5/s, GET /api/customers/{id}=50/s:100", keyed by
+// their method and path template.
+func ParseRoutes(spec string) (map[string]Limit, error) {
+	routes := map[string]Limit{}
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		route, limit, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		path = strings.TrimSpace(path)
+		if !ok || !hasPath || method != strings.ToUpper(method) || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid route limit %q, use <METHOD> <path>=<limit> such as POST /api/customers=5/s", entry)
+		}
+		l, err := ParseLimit(limit)
+		if err != nil {
+			return nil, fmt.Errorf("route %s %s: %w", method, path, err)
+		}
+		routes[method+" "+path] = l
+	}
+	return routes, nil
+}
+
+// Tokens of a client for a given limit
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(l Limit, now time.Time) *bucket {
+	return &bucket{limit: l, tokens: float64(l.Burst), last: now}
+}
+
+// Refills b up to now
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+	b.last = now
+}
+
+// Takes a token from b at now, returning whether there was one, the whole
+// tokens left and how long until b is full again. Requests denied are to
+// be retried after the returned wait.
+func (b *bucket) take(now time.Time) (ok bool, remaining int, reset, wait time.Duration) {
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		ok = true
+	} else {
+		wait = b.duration(1 - b.tokens)
+	}
+	return ok, int(b.tokens), b.duration(float64(b.limit.Burst) - b.tokens), wait
+}
+
+// Returns how long refilling n tokens takes
+func (b *bucket) duration(n float64) time.Duration {
+	return time.Duration(n / b.limit.Rate * float64(time.Second))
+}
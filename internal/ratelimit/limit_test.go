@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Limit
+		wantErr bool
+	}{
+		{"", Limit{}, false},
+		{"20/s", Limit{Rate: 20, Burst: 20}, false},
+		{"600/m:100", Limit{Rate: 10, Burst: 100}, false},
+		{"3600/h", Limit{Rate: 1, Burst: 3600}, false},
+		{"20", Limit{}, true},
+		{"20/d", Limit{}, true},
+		{"0/s", Limit{}, true},
+		{"20/s:none", Limit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseLimit(tt.spec)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseLimit() = %+v, %v, want %+v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	got, err := ParseRoutes("POST /api/customers=5/s, GET /api/customers/{id}=50/s:100,")
+	if err != nil {
+		t.Fatalf("ParseRoutes() error = %v", err)
+	}
+	want := map[string]Limit{
+		"POST /api/customers":     {Rate: 5, Burst: 5},
+		"GET /api/customers/{id}": {Rate: 50, Burst: 100},
+	}
+	if len(got) != len(want) {
+		t.Errorf("ParseRoutes() = %v, want %v", got, want)
+	}
+	for route, l := range want {
+		if got[route] != l {
+			t.Errorf("ParseRoutes()[%q] = %+v, want %+v", route, got[route], l)
+		}
+	}
+
+	for _, spec := range []string{"/api/customers=5/s", "post /api/customers=5/s", "POST api=5/s", "POST /api/customers=fast"} {
+		if _, err := ParseRoutes(spec); err == nil {
+			t.Errorf("ParseRoutes(%q) error = nil", spec)
+		}
+	}
+}
+
+func TestBucket(t *testing.T) {
+	now := time.Now()
+	b := newBucket(Limit{Rate: 2, Burst: 3}, now)
+	for i := range 3 {
+		if ok, remaining, _, _ := b.take(now); !ok || remaining != 2-i {
+			t.Fatalf("take %d = %v with %d left, want a token with %d left", i, ok, remaining, 2-i)
+		}
+	}
+	ok, _, reset, wait := b.take(now)
+	if ok || wait != 500*time.Millisecond || reset != 1500*time.Millisecond {
+		t.Errorf("take of an empty bucket = %v, retry in %v, full in %v", ok, wait, reset)
+	}
+	// Tokens come back at the rate, up to the burst
+	if ok, remaining, _, _ := b.take(now.Add(time.Second)); !ok || remaining != 1 {
+		t.Errorf("take after a second = %v with %d left, want a token with 1 left", ok, remaining)
+	}
+	if _, remaining, _, _ := b.take(now.Add(time.Hour)); remaining != 2 {
+		t.Errorf("take after an hour left %d tokens, want 2", remaining)
+	}
+}
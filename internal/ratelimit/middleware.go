@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/config"
+	"github.com/EdmundHusserl/CRM/internal/logging"
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	LimitHeader     string = "RateLimit-Limit"
+	RemainingHeader string = "RateLimit-Remaining"
+	ResetHeader     string = "RateLimit-Reset"
+)
+
+// Interval between the removals of the buckets of idle clients
+const pruneInterval = time.Minute
+
+// Limiter rejects the requests of clients exceeding their rate limit or
+// daily quota with 429. Clients are the users authenticated by their API
+// key or, for anonymous requests, their IP address.
+type Limiter struct {
+	Logger  *logrus.Logger
+	Default Limit
+	// Routes override Default, see ParseRoutes
+	Routes     map[string]Limit
+	DailyQuota int
+	Quotas     repository.QuotaRepository
+	TrustProxy bool
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+	pruned  time.Time
+	now     func() time.Time
+}
+
+// Bucket of a client, for a route with its own limit or for the others
+type bucketKey struct {
+	client string
+	route  string
+}
+
+// Returns a Limiter enforcing cfg, the daily quotas being counted in
+// quotas.
+func NewLimiter(l *logrus.Logger, cfg config.RateLimit, quotas repository.QuotaRepository) *Limiter {
+	limit, err := ParseLimit(cfg.Limit)
+	if err != nil {
+		l.WithField("error", err.Error()).Fatal(fmt.Sprintf("error parsing rate limit %q", cfg.Limit))
+	}
+	routes, err := ParseRoutes(cfg.Routes)
+	if err != nil {
+		l.WithField("error", err.Error()).Fatal(fmt.Sprintf("error parsing route rate limits %q", cfg.Routes))
+	}
+	return &Limiter{
+		Logger:     l,
+		Default:    limit,
+		Routes:     routes,
+		DailyQuota: cfg.DailyQuota,
+		Quotas:     quotas,
+		TrustProxy: cfg.TrustProxy,
+		buckets:    map[bucketKey]*bucket{},
+		now:        time.Now,
+	}
+}
+
+// Middleware enforces the limits on every request, reporting the one
+// closest to be reached in the RateLimit-* headers. It runs after
+// auth.Middleware.
+func (rl *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := rl.client(r)
+		route, limit := "", rl.Default
+		if t := routeOf(r); t != "" {
+			if l, ok := rl.Routes[t]; ok {
+				route, limit = t, l
+			}
+		}
+
+		remaining := math.MaxInt
+		if limit.Rate > 0 {
+			ok, left, reset, wait := rl.take(bucketKey{client, route}, limit)
+			setHeaders(w, limit.Burst, left, reset)
+			if !ok {
+				rl.reject(w, r, client, wait, problem.RateLimited, fmt.Sprintf("Rate limit exceeded, retry in %s seconds", ceilSeconds(wait)))
+				return
+			}
+			remaining = left
+		}
+
+		if rl.DailyQuota > 0 {
+			now := rl.now().UTC()
+			used, err := rl.Quotas.ConsumeQuota(r.Context(), client, now)
+			if err != nil {
+				// Clients are not blamed for the unavailability of quotas
+				logging.FromContext(r.Context(), rl.Logger).WithFields(logrus.Fields{
+					"error_message": err.Error(),
+					"client":        client,
+				}).Warn("Quota not counted")
+			} else {
+				tomorrow := now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+				if left := max(rl.DailyQuota-used, 0); left < remaining {
+					setHeaders(w, rl.DailyQuota, left, tomorrow)
+				}
+				if used > rl.DailyQuota {
+					rl.reject(w, r, client, tomorrow, problem.QuotaExceeded, fmt.Sprintf("Daily quota of %d requests exceeded", rl.DailyQuota))
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Takes a token from the bucket of key, see bucket.take
+func (rl *Limiter) take(key bucketKey, l Limit) (bool, int, time.Duration, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	if now.Sub(rl.pruned) >= pruneInterval {
+		// Full buckets are as good as new ones
+		for k, b := range rl.buckets {
+			if b.refill(now); b.tokens >= float64(b.limit.Burst) {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.pruned = now
+	}
+	b, ok := rl.buckets[key]
+	if !ok || b.limit != l {
+		b = newBucket(l, now)
+		rl.buckets[key] = b
+	}
+	return b.take(now)
+}
+
+// Returns the key of the client sending r
+func (rl *Limiter) client(r *http.Request) string {
+	if u, _ := auth.UserFromContext(r.Context()); u.ID != uuid.Nil {
+		return "user:" + u.ID.String()
+	}
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if rl.TrustProxy {
+		// The last address is the one the proxy saw
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if last := strings.TrimSpace(forwarded[len(forwarded)-1]); last != "" {
+			ip = last
+		}
+	}
+	return "ip:" + ip
+}
+
+// Writes the 429 response of the requests to retry after wait
+func (rl *Limiter) reject(w http.ResponseWriter, r *http.Request, client string, wait time.Duration, code problem.Code, msg string) {
+	w.Header().Set("Retry-After", ceilSeconds(wait))
+	problem.New(http.StatusTooManyRequests, code, msg).Write(w, r)
+
+	logging.FromContext(r.Context(), rl.Logger).WithFields(logrus.Fields{
+		"error_message": msg,
+		"client":        client,
+		"status":        http.StatusTooManyRequests,
+	}).Info("Request throttled")
+}
+
+// Returns the method and path template of the route matching r, if any
+func routeOf(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	t, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return r.Method + " " + t
+}
+
+func setHeaders(w http.ResponseWriter, limit, remaining int, reset time.Duration) {
+	w.Header().Set(LimitHeader, strconv.Itoa(limit))
+	w.Header().Set(RemainingHeader, strconv.Itoa(remaining))
+	w.Header().Set(ResetHeader, ceilSeconds(reset))
+}
+
+// Formats d as a whole number of seconds, rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
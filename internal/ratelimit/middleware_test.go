@@ -0,0 +1,148 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// Fails to count every request
+type unavailableQuotas struct{}
+
+func (unavailableQuotas) ConsumeQuota(ctx context.Context, client string, day time.Time) (int, error) {
+	return 0, errors.New("connection refused")
+}
+
+// Returns a router serving the customer routes behind rl, whose clock is
+// at noon
+func newTestRouter(rl *Limiter) *mux.Router {
+	rl.Logger = logrus.New()
+	rl.Logger.SetOutput(io.Discard)
+	rl.buckets = map[bucketKey]*bucket{}
+	noon := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	rl.now = func() time.Time { return noon }
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router := mux.NewRouter()
+	router.Use(rl.Middleware)
+	router.HandleFunc("/api/customers", ok).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/api/customers/{id}", ok).Methods(http.MethodGet)
+	return router
+}
+
+// Sends a request of the client at addr, or of user when set
+func send(h http.Handler, method, target, addr string, user uuid.UUID) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r.RemoteAddr = addr
+	if user != uuid.Nil {
+		r = r.WithContext(auth.WithUser(r.Context(), auth.User{ID: user}))
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	h := newTestRouter(&Limiter{
+		Default: Limit{Rate: 1, Burst: 2},
+		Routes:  map[string]Limit{"POST /api/customers": {Rate: 0.5, Burst: 1}},
+	})
+
+	for i, want := range []string{"1", "0"} {
+		rec := send(h, http.MethodGet, "/api/customers", "10.0.0.1:5000", uuid.Nil)
+		if rec.Code != http.StatusOK || rec.Header().Get(RemainingHeader) != want || rec.Header().Get(LimitHeader) != "2" {
+			t.Errorf("request %d = %d with %s/%s left, want %d with %s/2 left", i, rec.Code,
+				rec.Header().Get(RemainingHeader), rec.Header().Get(LimitHeader), http.StatusOK, want)
+		}
+	}
+	rec := send(h, http.MethodGet, "/api/customers/"+uuid.NewString(), "10.0.0.1:5001", uuid.Nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("request over the limit = %d, Retry-After %q, want %d, 1", rec.Code, rec.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Content-Type") != problem.ContentType {
+		t.Errorf("429 Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+
+	// Routes with their own limit and other clients have their own buckets
+	if rec := send(h, http.MethodPost, "/api/customers", "10.0.0.1:5000", uuid.Nil); rec.Code != http.StatusOK || rec.Header().Get(LimitHeader) != "1" {
+		t.Errorf("request of a limited route = %d, limit %s, want %d, 1", rec.Code, rec.Header().Get(LimitHeader), http.StatusOK)
+	}
+	if rec := send(h, http.MethodPost, "/api/customers", "10.0.0.1:5000", uuid.Nil); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("request over the route limit = %d, Retry-After %q, want %d, 2", rec.Code, rec.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+	if rec := send(h, http.MethodGet, "/api/customers", "10.0.0.2:5000", uuid.Nil); rec.Code != http.StatusOK {
+		t.Errorf("request of another address = %d, want %d", rec.Code, http.StatusOK)
+	}
+	user := uuid.New()
+	for range 2 {
+		send(h, http.MethodGet, "/api/customers", "10.0.0.1:5000", user)
+	}
+	if rec := send(h, http.MethodGet, "/api/customers", "10.0.0.3:5000", user); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("request of a user from another address = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestTrustProxy(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		forwarded  string
+		want       string
+	}{
+		{"direct", false, "203.0.113.7", "ip:10.0.0.1"},
+		{"proxied", true, "198.51.100.1, 203.0.113.7", "ip:203.0.113.7"},
+		{"not forwarded", true, "", "ip:10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/customers", nil)
+			r.RemoteAddr = "10.0.0.1:5000"
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rl := &Limiter{TrustProxy: tt.trustProxy}
+			if got := rl.client(r); got != tt.want {
+				t.Errorf("client() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDailyQuota(t *testing.T) {
+	quotas := providers.NewInMemoryQuotaRepository()
+	h := newTestRouter(&Limiter{DailyQuota: 2, Quotas: quotas})
+	for i, want := range []string{"1", "0"} {
+		rec := send(h, http.MethodGet, "/api/customers", "10.0.0.1:5000", uuid.Nil)
+		if rec.Code != http.StatusOK || rec.Header().Get(RemainingHeader) != want || rec.Header().Get(ResetHeader) != "43200" {
+			t.Errorf("request %d = %d with %s left, reset in %ss", i, rec.Code, rec.Header().Get(RemainingHeader), rec.Header().Get(ResetHeader))
+		}
+	}
+	rec := send(h, http.MethodGet, "/api/customers", "10.0.0.1:5000", uuid.Nil)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "43200" {
+		t.Errorf("request over the quota = %d, Retry-After %q, want %d until midnight", rec.Code, rec.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+
+	// Quotas restart every day
+	if n, _ := quotas.ConsumeQuota(context.Background(), "ip:10.0.0.1", time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)); n != 1 {
+		t.Errorf("ConsumeQuota() the next day = %d, want 1", n)
+	}
+
+	// Requests go through while quotas cannot be counted
+	h = newTestRouter(&Limiter{DailyQuota: 1, Quotas: unavailableQuotas{}})
+	for range 2 {
+		if rec := send(h, http.MethodGet, "/api/customers", "10.0.0.1:5000", uuid.Nil); rec.Code != http.StatusOK {
+			t.Errorf("request with unavailable quotas = %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Operation is the kind of mutation an audit entry records.
+type Operation string
+
+const (
+	OperationCreate  Operation = "create"
+	OperationUpdate  Operation = "update"
+	OperationDelete  Operation = "delete"
+	OperationAssign  Operation = "assign"
+	OperationRestore Operation = "restore"
+	OperationPurge   Operation = "purge"
+)
+
+// FieldChange holds the values of a field before and after a mutation.
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEntry is an immutable record of a customer mutation.
+type AuditEntry struct {
+	ID         int64                  `json:"id"`
+	TenantID   TenantID               `json:"tenant_id"`
+	CustomerID uuid.UUID              `json:"customer_id"`
+	ActorID    uuid.UUID              `json:"actor_id"`
+	ActorName  string                 `json:"actor_name"`
+	Operation  Operation              `json:"operation"`
+	RequestID  string                 `json:"request_id"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Before     *Customer              `json:"before"`
+	After      *Customer              `json:"after"`
+	Diff       map[string]FieldChange `json:"diff"`
+}
+
+// AuditFilter narrows the entries returned by AuditEntries. Zero values
+// mean "no restriction".
+type AuditFilter struct {
+	CustomerID uuid.UUID
+	ActorID    uuid.UUID
+	Operation  Operation
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// Matches reports whether e satisfies every restriction set on f but Limit.
+func (f AuditFilter) Matches(e AuditEntry) bool {
+	switch {
+	case f.CustomerID != uuid.Nil && e.CustomerID != f.CustomerID,
+		f.ActorID != uuid.Nil && e.ActorID != f.ActorID,
+		f.Operation != "" && e.Operation != f.Operation,
+		!f.Since.IsZero() && e.OccurredAt.Before(f.Since),
+		!f.Until.IsZero() && !e.OccurredAt.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+type AuditRepository interface {
+	// AuditEntries returns the entries matching f, oldest first.
+	AuditEntries(ctx context.Context, f AuditFilter) ([]AuditEntry, error)
+}
+
+// Diff lists the JSON fields whose value differs between before and after.
+// Either side may be nil.
+func Diff(before, after *Customer) map[string]FieldChange {
+	b, a := fields(before), fields(after)
+	diff := map[string]FieldChange{}
+	for k, v := range b {
+		if !reflect.DeepEqual(v, a[k]) {
+			diff[k] = FieldChange{Before: v, After: a[k]}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			diff[k] = FieldChange{After: v}
+		}
+	}
+	return diff
+}
+
+func fields(c *Customer) map[string]any {
+	m := map[string]any{}
+	if c == nil {
+		return m
+	}
+	b, _ := json.Marshal(c)
+	json.Unmarshal(b, &m)
+	return m
+}
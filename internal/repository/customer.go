@@ -1,13 +1,23 @@
 package repository
 
 import (
-	"fmt"
-	"net/mail"
-	"regexp"
+	"bytes"
+	"context"
+	"errors"
+	"time"
 
+	"github.com/EdmundHusserl/CRM/internal/validate"
 	"github.com/google/uuid"
 )
 
+// ErrConflict is returned when creating or updating a customer whose id
+// or email is taken.
+var ErrConflict = errors.New("conflict")
+
+// ErrNotFound is returned when the customer a call targets does not exist
+// in the state it requires.
+var ErrNotFound = errors.New("not found")
+
 // Define a new Enum which is descriptive of client roles.
 type CustomerRole int
 
@@ -19,37 +29,74 @@ const (
 
 type Customer struct {
 	ID          uuid.UUID    `json:"id"`
-	Name        string       `json:"name"`
-	Role        CustomerRole `json:"role"`
-	Email       string       `json:"email"`
-	PhoneNumber string       `json:"phone_number"`
+	TenantID    TenantID     `json:"tenant_id"`
+	Name        string       `json:"name" validate:"required,max=255"`
+	Role        CustomerRole `json:"role" validate:"oneof=0 1 2"`
+	Email       string       `json:"email" validate:"required,email,max=255"`
+	PhoneNumber string       `json:"phone_number" validate:"required,phone,max=50"`
 	Contacted   bool         `json:"contacted"`
+	OwnerID     uuid.UUID    `json:"owner_id"`
+	DeletedAt   *time.Time   `json:"deleted_at,omitempty"`
 }
 
-type CustomerRepository interface {
-	CloseDBConnection() error
-	Create(c Customer) error
-	Delete(id uuid.UUID) error
-	Get(id uuid.UUID) (*Customer, error)
-	GetAll() ([]Customer, error)
-	Update(c Customer) error
+// CustomerFilter narrows the records returned by list queries. Zero values
+// mean "no restriction".
+type CustomerFilter struct {
+	OwnerID uuid.UUID
+	// Deleted lists the customers in the trash instead of the live ones.
+	Deleted bool
+	// After lists the customers whose id follows it, customers being
+	// ordered by id.
+	After uuid.UUID
+	// Limit caps the number of customers listed.
+	Limit int
 }
 
-func (c Customer) ValidateEmail() (*string, error) {
-	a, err := mail.ParseAddress(c.Email)
-	if err != nil {
-		return nil, err
+// Matches reports whether c satisfies every restriction set on f but Limit.
+func (f CustomerFilter) Matches(c Customer) bool {
+	if f.OwnerID != uuid.Nil && c.OwnerID != f.OwnerID {
+		return false
 	}
-
-	return &a.Address, nil
+	if f.After != uuid.Nil && bytes.Compare(c.ID[:], f.After[:]) <= 0 {
+		return false
+	}
+	if (c.DeletedAt != nil) != f.Deleted {
+		return false
+	}
+	return true
 }
 
-func (c Customer) ValidatePhone() error {
-	phoneRegex := regexp.MustCompile(`^\+?[0-9\s\-\(\)]+$`)
-
-	if ok := phoneRegex.MatchString(c.PhoneNumber); !ok {
-		return fmt.Errorf("invalid phone number format: %s", c.PhoneNumber)
-	}
+// CustomerRepository stores the customers of every tenant. Its calls are
+// scoped to the tenant of their context, see WithTenant, but for
+// CountByRole and Purge which span every tenant.
+type CustomerRepository interface {
+	AuditRepository
+	// Assign makes ownerID the owner of customer id.
+	Assign(ctx context.Context, id, ownerID uuid.UUID) error
+	CloseDBConnection() error
+	// CountByRole counts the live customers of every role and tenant.
+	CountByRole(ctx context.Context) (map[CustomerRole]int, error)
+	Create(ctx context.Context, c Customer) error
+	// Delete moves a customer to the trash, hiding it from Get and GetAll
+	// until it is restored or purged.
+	Delete(ctx context.Context, id uuid.UUID) error
+	Get(ctx context.Context, id uuid.UUID) (*Customer, error)
+	GetAll(ctx context.Context, f CustomerFilter) ([]Customer, error)
+	// Ping checks that the repository can serve requests.
+	Ping(ctx context.Context) error
+	// Purge permanently removes the customers of every tenant trashed
+	// before deletedBefore and returns how many were removed.
+	Purge(ctx context.Context, deletedBefore time.Time) (int, error)
+	// Reassign transfers every live customer owned by from to to and
+	// returns how many were transferred. Trashed customers keep their owner.
+	Reassign(ctx context.Context, from, to uuid.UUID) (int, error)
+	// Restore moves a customer out of the trash.
+	Restore(ctx context.Context, id uuid.UUID) error
+	// Update changes every field but the owner, see Assign.
+	Update(ctx context.Context, c Customer) error
+}
 
-	return nil
+// Validate returns the validate.Errors of every invalid field of c.
+func (c Customer) Validate() error {
+	return validate.Struct(c)
 }
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord is the first request sent with an idempotency key and,
+// once it completed, the response replayed to its retries.
+type IdempotencyRecord struct {
+	// Client is the tenant and user owning the key
+	Client string
+	Key    string
+	// Fingerprint identifies the request, retries sending another one are
+	// rejected
+	Fingerprint string
+	// Status is zero while the first request is in progress
+	Status      int
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+type IdempotencyRepository interface {
+	// ReserveIdempotencyKey stores rec, unless the key of its client is
+	// held by a record not expired by now, which is returned instead.
+	ReserveIdempotencyKey(ctx context.Context, rec IdempotencyRecord, now time.Time) (*IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the response of a reserved key.
+	CompleteIdempotencyKey(ctx context.Context, rec IdempotencyRecord) error
+	// ReleaseIdempotencyKey removes a reserved key, for its request to be
+	// sent again.
+	ReleaseIdempotencyKey(ctx context.Context, client, key string) error
+	// PurgeIdempotencyKeys removes the keys expired by now.
+	PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int, error)
+}
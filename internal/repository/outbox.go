@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMessage is an event recorded in the same transaction as the change
+// it describes, waiting to be relayed.
+type OutboxMessage struct {
+	ID int64 `json:"id"`
+	// AggregateID is the customer the event is about. Messages sharing it
+	// are relayed in order.
+	AggregateID uuid.UUID       `json:"aggregate_id"`
+	EventID     uuid.UUID       `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type OutboxRepository interface {
+	// RelayOutbox hands up to limit pending messages, oldest first, to send
+	// and returns how many it published. The messages of the customers
+	// whose oldest pending message failed before come after the others.
+	// send reports the outcome of each message it attempted: messages
+	// mapped to nil are marked as published, the others remain pending. A
+	// single caller relays at a time across every instance sharing the
+	// outbox.
+	RelayOutbox(ctx context.Context, limit int, send func([]OutboxMessage) map[int64]error) (int, error)
+}
@@ -0,0 +1,48 @@
+package providers
+
+import (
+	"context"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/EdmundHusserl/CRM/internal/requestid"
+)
+
+// Builds the audit entry of a mutation performed on behalf of the user and
+// request found in ctx. Either customer may be nil.
+func newAuditEntry(ctx context.Context, op repository.Operation, before, after *repository.Customer) repository.AuditEntry {
+	e := repository.AuditEntry{
+		Operation:  op,
+		RequestID:  requestid.FromContext(ctx),
+		OccurredAt: time.Now().UTC(),
+		Before:     before,
+		After:      after,
+		Diff:       repository.Diff(before, after),
+	}
+	if u, ok := auth.UserFromContext(ctx); ok {
+		e.ActorID = u.ID
+		e.ActorName = u.Name
+	}
+	if after != nil {
+		e.CustomerID = after.ID
+		e.TenantID = after.TenantID
+	} else if before != nil {
+		e.CustomerID = before.ID
+		e.TenantID = before.TenantID
+	}
+	return e
+}
+
+// Publishes the lifecycle events of committed mutations
+func publish(ctx context.Context, p events.Publisher, entries ...repository.AuditEntry) {
+	if p == nil {
+		return
+	}
+	for _, a := range entries {
+		if e, ok := events.FromAuditEntry(a); ok {
+			p.Publish(ctx, e)
+		}
+	}
+}
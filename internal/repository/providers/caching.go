@@ -0,0 +1,249 @@
+package providers
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/config"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheStats counts the lookups served by a CachingRepository.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// Customers are cached per tenant, so that a tenant never reads the
+// customers of another from the cache
+type cacheKey struct {
+	tenant repository.TenantID
+	id     uuid.UUID
+}
+
+func (k cacheKey) String() string {
+	return string(k.tenant) + "/" + k.id.String()
+}
+
+// Returns the key of customer id for the tenant of ctx
+func keyOf(ctx context.Context, id uuid.UUID) cacheKey {
+	return cacheKey{tenant: repository.TenantFromContext(ctx), id: id}
+}
+
+type cacheEntry struct {
+	key       cacheKey
+	customer  repository.Customer
+	expiresAt time.Time
+}
+
+// CachingRepository keeps the customers read with Get in a size-bounded
+// LRU cache, entries expiring after TTL. Mutations made through it evict
+// the customers they change; the ones made elsewhere are evicted when
+// their events are published to it.
+type CachingRepository struct {
+	repository.CustomerRepository
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	// Incremented on every eviction so that loads started before it are
+	// not cached
+	generation uint64
+	loads      singleflight.Group
+
+	hits, misses, evictions atomic.Uint64
+}
+
+func NewCachingRepository(repo repository.CustomerRepository, c config.Cache) *CachingRepository {
+	return &CachingRepository{
+		CustomerRepository: repo,
+		size:               c.Size,
+		ttl:                c.TTL,
+		entries:            map[cacheKey]*list.Element{},
+		lru:                list.New(),
+	}
+}
+
+// Unwrap returns the cached repository.
+func (r *CachingRepository) Unwrap() repository.CustomerRepository {
+	return r.CustomerRepository
+}
+
+// Stats returns the cache counters since its creation.
+func (r *CachingRepository) Stats() CacheStats {
+	r.mu.Lock()
+	size := r.lru.Len()
+	r.mu.Unlock()
+	return CacheStats{
+		Hits:      r.hits.Load(),
+		Misses:    r.misses.Load(),
+		Evictions: r.evictions.Load(),
+		Size:      size,
+	}
+}
+
+var (
+	cacheHitsDesc      = prometheus.NewDesc("crm_cache_hits_total", "Customers read from the cache.", nil, nil)
+	cacheMissesDesc    = prometheus.NewDesc("crm_cache_misses_total", "Customers missing from the cache when read.", nil, nil)
+	cacheEvictionsDesc = prometheus.NewDesc("crm_cache_evictions_total", "Customers evicted to make room in the cache.", nil, nil)
+	cacheSizeDesc      = prometheus.NewDesc("crm_cache_size", "Customers in the cache.", nil, nil)
+)
+
+func (r *CachingRepository) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheEvictionsDesc
+	ch <- cacheSizeDesc
+}
+
+// Collect exports Stats as Prometheus metrics.
+func (r *CachingRepository) Collect(ch chan<- prometheus.Metric) {
+	stats := r.Stats()
+	ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(cacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(cacheSizeDesc, prometheus.GaugeValue, float64(stats.Size))
+}
+
+// Get returns the cached customer, loading it once for concurrent callers
+// on a miss. The load outlives the callers giving up on it, so that it
+// does not fail the others. Errors are not cached.
+func (r *CachingRepository) Get(ctx context.Context, id uuid.UUID) (*repository.Customer, error) {
+	key := keyOf(ctx, id)
+	if c, ok := r.lookup(key); ok {
+		r.hits.Add(1)
+		return &c, nil
+	}
+	r.misses.Add(1)
+
+	load := r.loads.DoChan(key.String(), func() (any, error) {
+		r.mu.Lock()
+		generation := r.generation
+		r.mu.Unlock()
+
+		c, err := r.CustomerRepository.Get(context.WithoutCancel(ctx), id)
+		if err != nil {
+			return nil, err
+		}
+		r.store(key, *c, generation)
+		return *c, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-load:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		c := res.Val.(repository.Customer)
+		return &c, nil
+	}
+}
+
+func (r *CachingRepository) Create(ctx context.Context, c repository.Customer) error {
+	defer r.evict(keyOf(ctx, c.ID))
+	return r.CustomerRepository.Create(ctx, c)
+}
+
+func (r *CachingRepository) Update(ctx context.Context, c repository.Customer) error {
+	defer r.evict(keyOf(ctx, c.ID))
+	return r.CustomerRepository.Update(ctx, c)
+}
+
+func (r *CachingRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer r.evict(keyOf(ctx, id))
+	return r.CustomerRepository.Delete(ctx, id)
+}
+
+func (r *CachingRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	defer r.evict(keyOf(ctx, id))
+	return r.CustomerRepository.Restore(ctx, id)
+}
+
+func (r *CachingRepository) Assign(ctx context.Context, id, ownerID uuid.UUID) error {
+	defer r.evict(keyOf(ctx, id))
+	return r.CustomerRepository.Assign(ctx, id, ownerID)
+}
+
+func (r *CachingRepository) Reassign(ctx context.Context, from, to uuid.UUID) (int, error) {
+	defer r.evict()
+	return r.CustomerRepository.Reassign(ctx, from, to)
+}
+
+// Publish evicts the customer e is about.
+func (r *CachingRepository) Publish(ctx context.Context, e events.Event) {
+	r.evict(cacheKey{tenant: e.TenantID, id: e.CustomerID})
+}
+
+// Returns the live cache entry of key, marking it as recently used
+func (r *CachingRepository) lookup(key cacheKey) (repository.Customer, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	el, ok := r.entries[key]
+	if !ok {
+		return repository.Customer{}, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		r.remove(el)
+		return repository.Customer{}, false
+	}
+	r.lru.MoveToFront(el)
+	return entry.customer, true
+}
+
+// Caches c under key unless an eviction happened since generation
+func (r *CachingRepository) store(key cacheKey, c repository.Customer, generation uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if generation != r.generation {
+		return
+	}
+	entry := &cacheEntry{key: key, customer: c, expiresAt: time.Now().Add(r.ttl)}
+	if el, ok := r.entries[key]; ok {
+		el.Value = entry
+		r.lru.MoveToFront(el)
+		return
+	}
+	r.entries[key] = r.lru.PushFront(entry)
+	for r.lru.Len() > r.size {
+		r.remove(r.lru.Back())
+		r.evictions.Add(1)
+	}
+}
+
+// Evicts the given customers, or every customer when none is given
+func (r *CachingRepository) evict(keys ...cacheKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.generation++
+	if len(keys) == 0 {
+		clear(r.entries)
+		r.lru.Init()
+	}
+	for _, key := range keys {
+		if el, ok := r.entries[key]; ok {
+			r.remove(el)
+		}
+		r.loads.Forget(key.String())
+	}
+}
+
+func (r *CachingRepository) remove(el *list.Element) {
+	delete(r.entries, el.Value.(*cacheEntry).key)
+	r.lru.Remove(el)
+}
@@ -0,0 +1,228 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/config"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Counts the Get calls reaching the repository, holding them until
+// release is closed or their context is done
+type countingRepository struct {
+	repository.CustomerRepository
+	gets    atomic.Int32
+	release chan struct{}
+}
+
+func (r *countingRepository) Get(ctx context.Context, id uuid.UUID) (*repository.Customer, error) {
+	r.gets.Add(1)
+	if r.release != nil {
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return r.CustomerRepository.Get(ctx, id)
+}
+
+func newCachingTestRepository(size int, ttl time.Duration) (*CachingRepository, *countingRepository, []uuid.UUID) {
+	inner := &InMemoryCustomerRepository{}
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	for i, id := range ids {
+		inner.Create(context.Background(), repository.Customer{ID: id, Name: "Customer", Email: id.String()[:8] + "@corp.com", Role: repository.CustomerRole(i)})
+	}
+	counting := &countingRepository{CustomerRepository: inner}
+	return NewCachingRepository(counting, config.Cache{Size: size, TTL: ttl}), counting, ids
+}
+
+func TestCachingRepository(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		size     int
+		ttl      time.Duration
+		run      func(r *CachingRepository, ids []uuid.UUID)
+		wantGets int32
+		wantHits uint64
+	}{
+		{
+			name: "Repeated_reads_hit",
+			size: 10,
+			ttl:  time.Minute,
+			run: func(r *CachingRepository, ids []uuid.UUID) {
+				r.Get(ctx, ids[0])
+				r.Get(ctx, ids[0])
+				r.Get(ctx, ids[0])
+			},
+			wantGets: 1,
+			wantHits: 2,
+		},
+		{
+			name: "Expired_entries_reload",
+			size: 10,
+			ttl:  time.Nanosecond,
+			run: func(r *CachingRepository, ids []uuid.UUID) {
+				r.Get(ctx, ids[0])
+				time.Sleep(time.Millisecond)
+				r.Get(ctx, ids[0])
+			},
+			wantGets: 2,
+		},
+		{
+			name: "Least_recently_used_evicted",
+			size: 2,
+			ttl:  time.Minute,
+			run: func(r *CachingRepository, ids []uuid.UUID) {
+				r.Get(ctx, ids[0])
+				r.Get(ctx, ids[1])
+				r.Get(ctx, ids[0])
+				r.Get(ctx, ids[2])
+				r.Get(ctx, ids[0])
+				r.Get(ctx, ids[1])
+			},
+			wantGets: 4,
+			wantHits: 2,
+		},
+		{
+			name: "Update_evicts",
+			size: 10,
+			ttl:  time.Minute,
+			run: func(r *CachingRepository, ids []uuid.UUID) {
+				c, _ := r.Get(ctx, ids[0])
+				c.Name = "Renamed"
+				r.Update(ctx, *c)
+				if c, _ := r.Get(ctx, ids[0]); c.Name != "Renamed" {
+					t.Errorf("Get() after Update() = %q, want Renamed", c.Name)
+				}
+			},
+			wantGets: 2,
+		},
+		{
+			name: "Delete_evicts",
+			size: 10,
+			ttl:  time.Minute,
+			run: func(r *CachingRepository, ids []uuid.UUID) {
+				r.Get(ctx, ids[0])
+				r.Delete(ctx, ids[0])
+				if _, err := r.Get(ctx, ids[0]); err == nil {
+					t.Error("Get() after Delete() succeeded, want not found")
+				}
+			},
+			wantGets: 2,
+		},
+		{
+			name: "Published_event_evicts",
+			size: 10,
+			ttl:  time.Minute,
+			run: func(r *CachingRepository, ids []uuid.UUID) {
+				r.Get(ctx, ids[0])
+				r.Get(ctx, ids[1])
+				r.Publish(ctx, events.Event{Type: events.CustomerUpdated, TenantID: repository.DefaultTenant, CustomerID: ids[0]})
+				r.Get(ctx, ids[0])
+				r.Get(ctx, ids[1])
+			},
+			wantGets: 3,
+			wantHits: 1,
+		},
+		{
+			name: "Tenants_do_not_share_entries",
+			size: 10,
+			ttl:  time.Minute,
+			run: func(r *CachingRepository, ids []uuid.UUID) {
+				r.Get(ctx, ids[0])
+				if c, err := r.Get(repository.WithTenant(ctx, "emea"), ids[0]); err == nil {
+					t.Errorf("Get() from another tenant = %v, want an error", c)
+				}
+				r.Get(ctx, ids[0])
+			},
+			wantGets: 2,
+			wantHits: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, counting, ids := newCachingTestRepository(tt.size, tt.ttl)
+			tt.run(r, ids)
+			if got := counting.gets.Load(); got != tt.wantGets {
+				t.Errorf("repository Get() called %d times, want %d", got, tt.wantGets)
+			}
+			if got := r.Stats().Hits; got != tt.wantHits {
+				t.Errorf("Stats().Hits = %d, want %d", got, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestCachingRepositoryCollapsesMisses(t *testing.T) {
+	r, counting, ids := newCachingTestRepository(10, time.Minute)
+	counting.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Get(context.Background(), ids[0]); err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+		}()
+	}
+	for r.Stats().Misses < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	// Lets the last callers join the pending load
+	time.Sleep(10 * time.Millisecond)
+	close(counting.release)
+	wg.Wait()
+
+	if got := counting.gets.Load(); got != 1 {
+		t.Errorf("repository Get() called %d times, want 1", got)
+	}
+}
+
+func TestCachingRepositoryLoadOutlivesCaller(t *testing.T) {
+	r, counting, ids := newCachingTestRepository(10, time.Minute)
+	counting.release = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := r.Get(ctx, ids[0])
+		first <- err
+	}()
+	for counting.gets.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan error)
+	go func() {
+		_, err := r.Get(context.Background(), ids[0])
+		second <- err
+	}()
+	for r.Stats().Misses < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// Lets the second caller join the pending load
+	time.Sleep(10 * time.Millisecond)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("Get() of the canceled caller error = %v, want %v", err, context.Canceled)
+	}
+	close(counting.release)
+	if err := <-second; err != nil {
+		t.Errorf("Get() of the other caller error = %v", err)
+	}
+	if got := counting.gets.Load(); got != 1 {
+		t.Errorf("repository Get() called %d times, want 1", got)
+	}
+}
@@ -1,88 +1,261 @@
 package providers
 
 import (
+	"bytes"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/EdmundHusserl/CRM/internal/events"
 	"github.com/EdmundHusserl/CRM/internal/repository"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
 type InMemoryCustomerRepository struct {
-	Customers []repository.Customer
+	// Tenants partitions the customers by tenant
+	Tenants map[repository.TenantID][]repository.Customer
+	Audit   []repository.AuditEntry
+	Events  events.Publisher
+	mu      sync.RWMutex
 }
 
+// Returns a repository holding data, the customers without a tenant
+// belonging to the default one.
 func NewInMemoryCustomerRepository(data []repository.Customer) *InMemoryCustomerRepository {
-	return &InMemoryCustomerRepository{Customers: data}
+	r := &InMemoryCustomerRepository{Tenants: map[repository.TenantID][]repository.Customer{}}
+	for _, c := range data {
+		if c.TenantID == "" {
+			c.TenantID = repository.DefaultTenant
+		}
+		r.Tenants[c.TenantID] = append(r.Tenants[c.TenantID], c)
+	}
+	return r
 }
 
 func (r *InMemoryCustomerRepository) CloseDBConnection() error {
 	return nil
 }
 
-func (r *InMemoryCustomerRepository) Create(c repository.Customer) error {
-	for _, customer := range r.Customers {
-		if c.ID == customer.ID {
-			return fmt.Errorf("conflict: user %s does exist", c.ID)
+func (r *InMemoryCustomerRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Appends an audit entry and publishes the mutation, r.mu must be held for
+// writing
+func (r *InMemoryCustomerRepository) audit(ctx context.Context, op repository.Operation, before, after *repository.Customer) {
+	e := newAuditEntry(ctx, op, before, after)
+	e.ID = int64(len(r.Audit) + 1)
+	r.Audit = append(r.Audit, e)
+	publish(ctx, r.Events, e)
+}
+
+func (r *InMemoryCustomerRepository) Create(ctx context.Context, c repository.Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c.TenantID = repository.TenantFromContext(ctx)
+	// Customers are only trashed by Delete
+	c.DeletedAt = nil
+	// Ids are unique across tenants, emails within their tenant
+	for _, customers := range r.Tenants {
+		for _, customer := range customers {
+			if c.ID == customer.ID {
+				return fmt.Errorf("%w: user %s does exist", repository.ErrConflict, c.ID)
+			}
 		}
+	}
+	for _, customer := range r.Tenants[c.TenantID] {
 		if c.Email == customer.Email {
-			return fmt.Errorf("conflict: email %s does exist", c.Email)
+			return fmt.Errorf("%w: email %s does exist", repository.ErrConflict, c.Email)
 		}
 	}
 
-	r.Customers = append(r.Customers, c)
+	if r.Tenants == nil {
+		r.Tenants = map[repository.TenantID][]repository.Customer{}
+	}
+	r.Tenants[c.TenantID] = append(r.Tenants[c.TenantID], c)
+	r.audit(ctx, repository.OperationCreate, nil, &c)
 	return nil
 }
 
-func (r *InMemoryCustomerRepository) Get(id uuid.UUID) (*repository.Customer, error) {
-	for _, c := range r.Customers {
-		if c.ID == id {
+func (r *InMemoryCustomerRepository) Get(ctx context.Context, id uuid.UUID) (*repository.Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.Tenants[repository.TenantFromContext(ctx)] {
+		if c.ID == id && c.DeletedAt == nil {
 			return &c, nil
 		}
 	}
 	return nil, fmt.Errorf("user not found: %v", id)
 }
 
-func (r *InMemoryCustomerRepository) Delete(id uuid.UUID) error {
-	var index int
-	found := false
-	for i, c := range r.Customers {
-		if c.ID == id {
-			found = true
-			index = i
-			break
+func (r *InMemoryCustomerRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	customers := r.Tenants[repository.TenantFromContext(ctx)]
+	for i, customer := range customers {
+		if customer.ID == id && customer.DeletedAt == nil {
+			now := time.Now().UTC()
+			customers[i].DeletedAt = &now
+			after := customers[i]
+			r.audit(ctx, repository.OperationDelete, &customer, &after)
+			return nil
 		}
 	}
-	if !found {
-		return fmt.Errorf("user not found: %v", id)
+	return fmt.Errorf("user not found: %v", id)
+}
+
+func (r *InMemoryCustomerRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	customers := r.Tenants[repository.TenantFromContext(ctx)]
+	for i, customer := range customers {
+		if customer.ID == id && customer.DeletedAt != nil {
+			customers[i].DeletedAt = nil
+			after := customers[i]
+			r.audit(ctx, repository.OperationRestore, &customer, &after)
+			return nil
+		}
 	}
-	customers := make([]repository.Customer, len(r.Customers)-1)
-	customers = append(r.Customers[:index], r.Customers[index+1:]...)
-	r.Customers = customers
-	return nil
+	return fmt.Errorf("%w: user %s in trash", repository.ErrNotFound, id)
 }
 
-func (r *InMemoryCustomerRepository) GetAll() ([]repository.Customer, error) {
-	return r.Customers, nil
+func (r *InMemoryCustomerRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for tenant, partition := range r.Tenants {
+		customers := make([]repository.Customer, 0, len(partition))
+		for _, c := range partition {
+			if c.DeletedAt != nil && c.DeletedAt.Before(deletedBefore) {
+				r.audit(ctx, repository.OperationPurge, &c, nil)
+				continue
+			}
+			customers = append(customers, c)
+		}
+		count += len(partition) - len(customers)
+		r.Tenants[tenant] = customers
+	}
+	return count, nil
 }
 
-func (r *InMemoryCustomerRepository) Update(c repository.Customer) error {
-	for i, customer := range r.Customers {
-		if customer.ID == c.ID {
-			r.Customers[i].Name = c.Name
-			r.Customers[i].Role = c.Role
-			r.Customers[i].Email = c.Email
-			r.Customers[i].PhoneNumber = c.PhoneNumber
-			r.Customers[i].Contacted = c.Contacted
+func (r *InMemoryCustomerRepository) GetAll(ctx context.Context, f repository.CustomerFilter) ([]repository.Customer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	customers := []repository.Customer{}
+	for _, c := range r.Tenants[repository.TenantFromContext(ctx)] {
+		if f.Matches(c) {
+			customers = append(customers, c)
+		}
+	}
+	slices.SortFunc(customers, func(a, b repository.Customer) int { return bytes.Compare(a.ID[:], b.ID[:]) })
+	if f.Limit > 0 && len(customers) > f.Limit {
+		customers = customers[:f.Limit]
+	}
+	return customers, nil
+}
+
+func (r *InMemoryCustomerRepository) CountByRole(ctx context.Context) (map[repository.CustomerRole]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := map[repository.CustomerRole]int{}
+	for _, customers := range r.Tenants {
+		for _, c := range customers {
+			if c.DeletedAt == nil {
+				counts[c.Role]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (r *InMemoryCustomerRepository) Update(ctx context.Context, c repository.Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	customers := r.Tenants[repository.TenantFromContext(ctx)]
+	for _, customer := range customers {
+		if customer.ID != c.ID && customer.Email == c.Email {
+			return fmt.Errorf("%w: email %s does exist", repository.ErrConflict, c.Email)
+		}
+	}
+	for i, customer := range customers {
+		if customer.ID == c.ID && customer.DeletedAt == nil {
+			customers[i].Name = c.Name
+			customers[i].Role = c.Role
+			customers[i].Email = c.Email
+			customers[i].PhoneNumber = c.PhoneNumber
+			customers[i].Contacted = c.Contacted
+			after := customers[i]
+			r.audit(ctx, repository.OperationUpdate, &customer, &after)
 			return nil
 		}
 	}
 	return fmt.Errorf("could not update user with ID %v", c.ID)
 }
 
+func (r *InMemoryCustomerRepository) Assign(ctx context.Context, id, ownerID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	customers := r.Tenants[repository.TenantFromContext(ctx)]
+	for i, customer := range customers {
+		if customer.ID == id && customer.DeletedAt == nil {
+			customers[i].OwnerID = ownerID
+			after := customers[i]
+			r.audit(ctx, repository.OperationAssign, &customer, &after)
+			return nil
+		}
+	}
+	return fmt.Errorf("user not found: %v", id)
+}
+
+func (r *InMemoryCustomerRepository) Reassign(ctx context.Context, from, to uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	customers := r.Tenants[repository.TenantFromContext(ctx)]
+	for i, customer := range customers {
+		if customer.OwnerID == from && customer.DeletedAt == nil {
+			customers[i].OwnerID = to
+			after := customers[i]
+			r.audit(ctx, repository.OperationAssign, &customer, &after)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *InMemoryCustomerRepository) AuditEntries(ctx context.Context, f repository.AuditFilter) ([]repository.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant := repository.TenantFromContext(ctx)
+	entries := []repository.AuditEntry{}
+	for _, e := range r.Audit {
+		if f.Limit > 0 && len(entries) == f.Limit {
+			break
+		}
+		if e.TenantID == tenant && f.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
 func LoadFromCSVFile(l *logrus.Logger, path string) ([]repository.Customer, error) {
 	// Open the CSV file
 	file, err := os.Open(path)
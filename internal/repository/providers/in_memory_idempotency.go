@@ -0,0 +1,65 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+)
+
+type idempotencyKey struct {
+	client string
+	key    string
+}
+
+type InMemoryIdempotencyRepository struct {
+	Records map[idempotencyKey]repository.IdempotencyRecord
+	mu      sync.Mutex
+}
+
+func NewInMemoryIdempotencyRepository() *InMemoryIdempotencyRepository {
+	return &InMemoryIdempotencyRepository{Records: map[idempotencyKey]repository.IdempotencyRecord{}}
+}
+
+func (r *InMemoryIdempotencyRepository) ReserveIdempotencyKey(ctx context.Context, rec repository.IdempotencyRecord, now time.Time) (*repository.IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k := idempotencyKey{rec.Client, rec.Key}
+	if existing, ok := r.Records[k]; ok && existing.ExpiresAt.After(now) {
+		return &existing, nil
+	}
+	r.Records[k] = rec
+	return nil, nil
+}
+
+func (r *InMemoryIdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, rec repository.IdempotencyRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Records[idempotencyKey{rec.Client, rec.Key}] = rec
+	return nil
+}
+
+func (r *InMemoryIdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, client, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.Records, idempotencyKey{client, key})
+	return nil
+}
+
+func (r *InMemoryIdempotencyRepository) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for k, rec := range r.Records {
+		if !rec.ExpiresAt.After(now) {
+			delete(r.Records, k)
+			n++
+		}
+	}
+	return n, nil
+}
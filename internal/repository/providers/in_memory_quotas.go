@@ -0,0 +1,42 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type quotaKey struct {
+	client string
+	day    time.Time
+}
+
+// InMemoryQuotaRepository counts requests for the lifetime of the process
+type InMemoryQuotaRepository struct {
+	Usage map[quotaKey]int
+	today time.Time
+	mu    sync.Mutex
+}
+
+func NewInMemoryQuotaRepository() *InMemoryQuotaRepository {
+	return &InMemoryQuotaRepository{Usage: map[quotaKey]int{}}
+}
+
+func (r *InMemoryQuotaRepository) ConsumeQuota(ctx context.Context, client string, day time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	day = day.UTC().Truncate(24 * time.Hour)
+	if day.After(r.today) {
+		// Past days are over
+		for k := range r.Usage {
+			if k.day.Before(day) {
+				delete(r.Usage, k)
+			}
+		}
+		r.today = day
+	}
+	k := quotaKey{client, day}
+	r.Usage[k]++
+	return r.Usage[k], nil
+}
@@ -1,9 +1,13 @@
 package providers
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/EdmundHusserl/CRM/internal/auth"
 	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/EdmundHusserl/CRM/internal/requestid"
 	"github.com/google/uuid"
 )
 
@@ -19,7 +23,7 @@ func TestCreateCustomer(t *testing.T) {
 	}{
 		{
 			"Success",
-			&InMemoryCustomerRepository{Customers: []repository.Customer{}},
+			NewInMemoryCustomerRepository([]repository.Customer{}),
 			repository.Customer{
 				ID:          cUUID,
 				Name:        "Jorge",
@@ -31,9 +35,22 @@ func TestCreateCustomer(t *testing.T) {
 			1,
 			false,
 		},
+		{
+			"Ignores the deletion time",
+			NewInMemoryCustomerRepository([]repository.Customer{}),
+			repository.Customer{
+				ID:          cUUID,
+				Name:        "Jorge",
+				Email:       "jorge@corp.com",
+				PhoneNumber: "514 888 8888",
+				DeletedAt:   &time.Time{},
+			},
+			1,
+			false,
+		},
 		{
 			"Fails due to ID conflict",
-			&InMemoryCustomerRepository{Customers: []repository.Customer{
+			NewInMemoryCustomerRepository([]repository.Customer{
 				{
 					ID:          cUUID,
 					Name:        "Jorge",
@@ -42,7 +59,7 @@ func TestCreateCustomer(t *testing.T) {
 					PhoneNumber: "514 888 8888",
 					Contacted:   true,
 				},
-			}},
+			}),
 			repository.Customer{
 				ID:          cUUID,
 				Name:        "Whatever Dude",
@@ -56,7 +73,7 @@ func TestCreateCustomer(t *testing.T) {
 		},
 		{
 			"Fails due to e-mail conflict",
-			&InMemoryCustomerRepository{Customers: []repository.Customer{
+			NewInMemoryCustomerRepository([]repository.Customer{
 				{
 					ID:          cUUID,
 					Name:        "Jorge",
@@ -65,7 +82,7 @@ func TestCreateCustomer(t *testing.T) {
 					PhoneNumber: "514 888 8888",
 					Contacted:   true,
 				},
-			}},
+			}),
 			repository.Customer{
 				ID:          uuid.New(),
 				Name:        "Whatever Dude",
@@ -80,15 +97,20 @@ func TestCreateCustomer(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.repo.Create(tt.data)
+			err := tt.repo.Create(context.Background(), tt.data)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			customerCount := len(tt.repo.Customers)
+			customerCount := len(tt.repo.Tenants[repository.DefaultTenant])
 			if customerCount != tt.count {
 				t.Errorf("Customer count=%v not equal to expected=%v", customerCount, tt.count)
 			}
+			if !tt.wantErr {
+				if _, err := tt.repo.Get(context.Background(), tt.data.ID); err != nil {
+					t.Errorf("Get() of the created customer error = %v", err)
+				}
+			}
 		})
 	}
 }
@@ -105,7 +127,7 @@ func TestGetDeleteCustomer(t *testing.T) {
 	}{
 		{
 			"Success",
-			&InMemoryCustomerRepository{Customers: []repository.Customer{
+			NewInMemoryCustomerRepository([]repository.Customer{
 				{
 					ID:          cUUID,
 					Name:        "Jorge",
@@ -114,14 +136,14 @@ func TestGetDeleteCustomer(t *testing.T) {
 					PhoneNumber: "514 888 8888",
 					Contacted:   true,
 				},
-			}},
+			}),
 			cUUID,
 			map[string]int{"GET": 1, "DELETE": 0},
 			false,
 		},
 		{
 			"Fails_as_not_found",
-			&InMemoryCustomerRepository{Customers: []repository.Customer{
+			NewInMemoryCustomerRepository([]repository.Customer{
 				{
 					ID:          cUUID,
 					Name:        "Jorge",
@@ -130,7 +152,7 @@ func TestGetDeleteCustomer(t *testing.T) {
 					PhoneNumber: "514 888 8888",
 					Contacted:   true,
 				},
-			}},
+			}),
 			uuid.New(),
 			map[string]int{"GET": 1, "DELETE": 1},
 			true,
@@ -139,25 +161,26 @@ func TestGetDeleteCustomer(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := tt.repo.Get(tt.data)
+			_, err := tt.repo.Get(context.Background(), tt.data)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			customerCount := len(tt.repo.Customers)
+			customerCount := len(tt.repo.Tenants[repository.DefaultTenant])
 			countAfterGet, _ := tt.count["GET"]
 			if customerCount != countAfterGet {
 				t.Errorf("Customer count=%v not equal to expected=%v", customerCount, countAfterGet)
 			}
 
-			err = tt.repo.Delete(tt.data)
+			err = tt.repo.Delete(context.Background(), tt.data)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Delete() error =%v, wantErr %v", err, tt.wantErr)
 			}
 
-			customerCount = len(tt.repo.Customers)
+			live, _ := tt.repo.GetAll(context.Background(), repository.CustomerFilter{})
+			customerCount = len(live)
 			countAfterDelete, _ := tt.count["DELETE"]
 			if customerCount != countAfterDelete {
 				t.Errorf("Customer count=%v not equal to expected=%v", customerCount, countAfterDelete)
@@ -168,16 +191,18 @@ func TestGetDeleteCustomer(t *testing.T) {
 
 func TestGetAllCustomers(t *testing.T) {
 	cUUID := uuid.New()
+	ownerUUID := uuid.New()
 
 	tests := []struct {
 		name    string
 		repo    *InMemoryCustomerRepository
+		filter  repository.CustomerFilter
 		count   int
 		wantErr bool
 	}{
 		{
 			"Success",
-			&InMemoryCustomerRepository{Customers: []repository.Customer{
+			NewInMemoryCustomerRepository([]repository.Customer{
 				{
 					ID:          cUUID,
 					Name:        "Jorge",
@@ -186,7 +211,33 @@ func TestGetAllCustomers(t *testing.T) {
 					PhoneNumber: "514 888 8888",
 					Contacted:   true,
 				},
-			}},
+			}),
+			repository.CustomerFilter{},
+			1,
+			false,
+		},
+		{
+			"Filters_by_owner",
+			NewInMemoryCustomerRepository([]repository.Customer{
+				{
+					ID:          cUUID,
+					Name:        "Jorge",
+					Role:        2,
+					Email:       "jorge@corp.com",
+					PhoneNumber: "514 888 8888",
+					Contacted:   true,
+					OwnerID:     ownerUUID,
+				},
+				{
+					ID:          uuid.New(),
+					Name:        "Whatever Dude",
+					Role:        1,
+					Email:       "whatdud@corp.com",
+					PhoneNumber: "514 999 8888",
+					Contacted:   false,
+				},
+			}),
+			repository.CustomerFilter{OwnerID: ownerUUID},
 			1,
 			false,
 		},
@@ -194,16 +245,15 @@ func TestGetAllCustomers(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			customers, err := tt.repo.GetAll()
+			customers, err := tt.repo.GetAll(context.Background(), tt.filter)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
-			customerCount := len(tt.repo.Customers)
 			countAfterRetrieval := len(customers)
-			if customerCount != countAfterRetrieval {
-				t.Errorf("Customer count=%v not equal to expected=%v", customerCount, countAfterRetrieval)
+			if countAfterRetrieval != tt.count {
+				t.Errorf("Customer count=%v not equal to expected=%v", countAfterRetrieval, tt.count)
 			}
 		})
 	}
@@ -220,7 +270,7 @@ func TestUpdateCustomer(t *testing.T) {
 	}{
 		{
 			"Success",
-			&InMemoryCustomerRepository{Customers: []repository.Customer{
+			NewInMemoryCustomerRepository([]repository.Customer{
 				{
 					ID:          cUUID,
 					Name:        "Jorge",
@@ -229,7 +279,7 @@ func TestUpdateCustomer(t *testing.T) {
 					PhoneNumber: "514 888 8888",
 					Contacted:   true,
 				},
-			}},
+			}),
 			repository.Customer{
 				ID:          cUUID,
 				Name:        "Jorge",
@@ -242,7 +292,7 @@ func TestUpdateCustomer(t *testing.T) {
 		},
 		{
 			"Fails_as_not_found",
-			&InMemoryCustomerRepository{Customers: []repository.Customer{
+			NewInMemoryCustomerRepository([]repository.Customer{
 				{
 					ID:          cUUID,
 					Name:        "Jorge",
@@ -251,7 +301,7 @@ func TestUpdateCustomer(t *testing.T) {
 					PhoneNumber: "514 888 8888",
 					Contacted:   true,
 				},
-			}},
+			}),
 			repository.Customer{
 				ID:          uuid.New(),
 				Name:        "Jorge",
@@ -266,10 +316,186 @@ func TestUpdateCustomer(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.repo.Update(tt.data)
+			err := tt.repo.Update(context.Background(), tt.data)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Update() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestAssignReassignCustomer(t *testing.T) {
+	cUUID := uuid.New()
+	leaving, taking := uuid.New(), uuid.New()
+	deletedAt := time.Now()
+
+	repo := NewInMemoryCustomerRepository([]repository.Customer{
+		{ID: cUUID, Name: "Jorge", Email: "jorge@corp.com", PhoneNumber: "514 888 8888"},
+		{ID: uuid.New(), Name: "Whatever Dude", Email: "whatdud@corp.com", PhoneNumber: "514 999 8888", OwnerID: leaving},
+		{ID: uuid.New(), Name: "Trashed", Email: "trashed@corp.com", PhoneNumber: "514 999 7777", OwnerID: leaving, DeletedAt: &deletedAt},
+	})
+
+	if err := repo.Assign(context.Background(), cUUID, leaving); err != nil {
+		t.Fatalf("Assign() error = %v", err)
+	}
+	if err := repo.Assign(context.Background(), uuid.New(), leaving); err == nil {
+		t.Errorf("Assign() of unknown customer did not fail")
+	}
+
+	n, err := repo.Reassign(context.Background(), leaving, taking)
+	if err != nil {
+		t.Fatalf("Reassign() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Reassigned count=%v not equal to expected=%v", n, 2)
+	}
+
+	owned, _ := repo.GetAll(context.Background(), repository.CustomerFilter{OwnerID: taking})
+	if len(owned) != 2 {
+		t.Errorf("Owned count=%v not equal to expected=%v", len(owned), 2)
+	}
+	trashed, _ := repo.GetAll(context.Background(), repository.CustomerFilter{OwnerID: leaving, Deleted: true})
+	if len(trashed) != 1 {
+		t.Errorf("Trashed customers kept by the leaving owner=%v, want %v", len(trashed), 1)
+	}
+	entries, _ := repo.AuditEntries(context.Background(), repository.AuditFilter{Operation: repository.OperationAssign})
+	if len(entries) != 3 {
+		t.Errorf("Assign audit entries=%v, want %v", len(entries), 3)
+	}
+}
+
+func TestAuditEntries(t *testing.T) {
+	cUUID := uuid.New()
+	actor := auth.User{ID: uuid.New(), Name: "Ana", Role: auth.RoleAdmin}
+	ctx := requestid.WithID(auth.WithUser(context.Background(), actor), "req-1")
+
+	repo := NewInMemoryCustomerRepository([]repository.Customer{})
+	c := repository.Customer{ID: cUUID, Name: "Jorge", Email: "jorge@corp.com", PhoneNumber: "514 888 8888"}
+	if err := repo.Create(ctx, c); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	c.Contacted = true
+	if err := repo.Update(ctx, c); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := repo.Delete(ctx, cUUID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter repository.AuditFilter
+		count  int
+	}{
+		{"All", repository.AuditFilter{}, 3},
+		{"By_customer", repository.AuditFilter{CustomerID: cUUID}, 3},
+		{"By_operation", repository.AuditFilter{Operation: repository.OperationUpdate}, 1},
+		{"By_actor", repository.AuditFilter{ActorID: uuid.New()}, 0},
+		{"Limited", repository.AuditFilter{Limit: 2}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := repo.AuditEntries(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("AuditEntries() error = %v", err)
+			}
+			if len(entries) != tt.count {
+				t.Errorf("Entry count=%v not equal to expected=%v", len(entries), tt.count)
+			}
+		})
+	}
+
+	update := repo.Audit[1]
+	if update.ActorID != actor.ID || update.RequestID != "req-1" {
+		t.Errorf("Entry actor=%v request=%v not recorded", update.ActorID, update.RequestID)
+	}
+	if _, ok := update.Diff["contacted"]; !ok || len(update.Diff) != 1 {
+		t.Errorf("Diff=%v, want only contacted", update.Diff)
+	}
+	if repo.Audit[2].Before == nil || repo.Audit[2].After == nil || repo.Audit[2].After.DeletedAt == nil {
+		t.Errorf("Delete entry does not hold the trashed customer")
+	}
+}
+
+func TestTrashRestorePurgeCustomer(t *testing.T) {
+	cUUID := uuid.New()
+	ctx := context.Background()
+
+	repo := NewInMemoryCustomerRepository([]repository.Customer{
+		{ID: cUUID, Name: "Jorge", Email: "jorge@corp.com", PhoneNumber: "514 888 8888"},
+		{ID: uuid.New(), Name: "Whatever Dude", Email: "whatdud@corp.com", PhoneNumber: "514 999 8888"},
+	})
+
+	if err := repo.Delete(ctx, cUUID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.Get(ctx, cUUID); err == nil {
+		t.Errorf("Get() returned a trashed customer")
+	}
+	trash, _ := repo.GetAll(ctx, repository.CustomerFilter{Deleted: true})
+	if len(trash) != 1 || trash[0].ID != cUUID {
+		t.Errorf("Trash=%v, want only %v", trash, cUUID)
+	}
+
+	if err := repo.Restore(ctx, cUUID); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if err := repo.Restore(ctx, cUUID); err == nil {
+		t.Errorf("Restore() of a live customer did not fail")
+	}
+	if _, err := repo.Get(ctx, cUUID); err != nil {
+		t.Errorf("Get() error = %v after restore", err)
+	}
+
+	repo.Delete(ctx, cUUID)
+	if n, _ := repo.Purge(ctx, time.Now().Add(-time.Hour)); n != 0 {
+		t.Errorf("Purged count=%v before retention elapsed", n)
+	}
+	if n, _ := repo.Purge(ctx, time.Now().Add(time.Second)); n != 1 {
+		t.Errorf("Purged count=%v not equal to expected=%v", n, 1)
+	}
+	if len(repo.Tenants[repository.DefaultTenant]) != 1 {
+		t.Errorf("Customer count=%v not equal to expected=%v", len(repo.Tenants[repository.DefaultTenant]), 1)
+	}
+}
+
+func TestTenantPartitions(t *testing.T) {
+	acme := repository.WithTenant(context.Background(), "acme")
+	globex := repository.WithTenant(context.Background(), "globex")
+	cUUID := uuid.New()
+
+	repo := NewInMemoryCustomerRepository(nil)
+	if err := repo.Create(acme, repository.Customer{ID: cUUID, Name: "Jorge", Email: "jorge@corp.com"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	// Emails are unique within their tenant only, ids everywhere
+	if err := repo.Create(globex, repository.Customer{ID: uuid.New(), Name: "Jorge", Email: "jorge@corp.com"}); err != nil {
+		t.Errorf("Create() error = %v for an email taken in another tenant", err)
+	}
+	if err := repo.Create(globex, repository.Customer{ID: cUUID, Name: "Whatever Dude", Email: "whatdud@corp.com"}); err == nil {
+		t.Errorf("Create() reused an id of another tenant")
+	}
+
+	if c, err := repo.Get(acme, cUUID); err != nil || c.TenantID != "acme" {
+		t.Errorf("Get() = %v, %v, want the customer of acme", c, err)
+	}
+	if _, err := repo.Get(globex, cUUID); err == nil {
+		t.Errorf("Get() returned a customer of another tenant")
+	}
+	if err := repo.Update(globex, repository.Customer{ID: cUUID, Name: "Stolen"}); err == nil {
+		t.Errorf("Update() changed a customer of another tenant")
+	}
+	if err := repo.Delete(globex, cUUID); err == nil {
+		t.Errorf("Delete() removed a customer of another tenant")
+	}
+	if all, _ := repo.GetAll(globex, repository.CustomerFilter{}); len(all) != 1 {
+		t.Errorf("GetAll() count=%v not equal to expected=%v", len(all), 1)
+	}
+	if entries, _ := repo.AuditEntries(globex, repository.AuditFilter{CustomerID: cUUID}); len(entries) != 0 {
+		t.Errorf("AuditEntries() returned %v entries of another tenant", len(entries))
+	}
+	if counts, _ := repo.CountByRole(context.Background()); counts[0] != 2 {
+		t.Errorf("CountByRole()=%v, want both tenants counted", counts)
+	}
+}
@@ -0,0 +1,130 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+)
+
+type InMemoryWebhookRepository struct {
+	Subscriptions []repository.WebhookSubscription
+	Deliveries    []repository.WebhookDelivery
+	mu            sync.Mutex
+}
+
+func NewInMemoryWebhookRepository() *InMemoryWebhookRepository {
+	return &InMemoryWebhookRepository{}
+}
+
+func (r *InMemoryWebhookRepository) CreateSubscription(ctx context.Context, s repository.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, sub := range r.Subscriptions {
+		if sub.ID == s.ID {
+			return fmt.Errorf("conflict: subscription %s does exist", s.ID)
+		}
+	}
+	s.TenantID = repository.TenantFromContext(ctx)
+	r.Subscriptions = append(r.Subscriptions, s)
+	return nil
+}
+
+func (r *InMemoryWebhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := repository.TenantFromContext(ctx)
+	for i, sub := range r.Subscriptions {
+		if sub.ID == id && sub.TenantID == tenant {
+			r.Subscriptions = slices.Delete(r.Subscriptions, i, i+1)
+			return nil
+		}
+	}
+	return fmt.Errorf("subscription not found: %v", id)
+}
+
+func (r *InMemoryWebhookRepository) ListSubscriptions(ctx context.Context) ([]repository.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := repository.TenantFromContext(ctx)
+	subscriptions := []repository.WebhookSubscription{}
+	for _, s := range r.Subscriptions {
+		if s.TenantID == tenant {
+			subscriptions = append(subscriptions, s)
+		}
+	}
+	return subscriptions, nil
+}
+
+func (r *InMemoryWebhookRepository) EnqueueDeliveries(ctx context.Context, ds []repository.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Deliveries = append(r.Deliveries, ds...)
+	return nil
+}
+
+func (r *InMemoryWebhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]repository.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	claimed := []repository.WebhookDelivery{}
+	for i, d := range r.Deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		if d.Status == repository.DeliveryPending && !d.NextAttemptAt.After(now) {
+			r.Deliveries[i].NextAttemptAt = now.Add(lease)
+			claimed = append(claimed, d)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *InMemoryWebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*repository.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := repository.TenantFromContext(ctx)
+	for _, d := range r.Deliveries {
+		if d.ID == id && d.TenantID == tenant {
+			return &d, nil
+		}
+	}
+	return nil, fmt.Errorf("delivery not found: %v", id)
+}
+
+func (r *InMemoryWebhookRepository) ListDeliveries(ctx context.Context, status repository.DeliveryStatus) ([]repository.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := repository.TenantFromContext(ctx)
+	deliveries := []repository.WebhookDelivery{}
+	for _, d := range r.Deliveries {
+		if d.TenantID == tenant && (status == "" || d.Status == status) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+func (r *InMemoryWebhookRepository) UpdateDelivery(ctx context.Context, d repository.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := repository.TenantFromContext(ctx)
+	for i, delivery := range r.Deliveries {
+		if delivery.ID == d.ID && delivery.TenantID == tenant {
+			r.Deliveries[i] = d
+			return nil
+		}
+	}
+	return fmt.Errorf("delivery not found: %v", d.ID)
+}
@@ -1,9 +1,14 @@
 package providers
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"strings"
 
+	"github.com/EdmundHusserl/CRM/internal/config"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/metrics"
 	"github.com/EdmundHusserl/CRM/internal/repository"
 	"github.com/sirupsen/logrus"
 )
@@ -17,8 +22,23 @@ func isValid(provider string) bool {
 	return (provider == psql || provider == in_memory)
 }
 
-// Returns a CustomerRepository implemented interface
-func NewRepository(l *logrus.Logger, provider string) repository.CustomerRepository {
+// Returns a CustomerRepository implemented interface for the provider of
+// db, behind a CachingRepository when cache has a size. The in-memory provider
+// publishes its mutations to p directly while psql records them in its
+// outbox, see NewOutboxRepository.
+func NewRepository(l *logrus.Logger, db config.Database, p events.Publisher, cache config.Cache) repository.CustomerRepository {
+	base, name := newProviderRepository(l, db, p)
+	var repo repository.CustomerRepository = metrics.NewRepository(base, name)
+	if cache.Size > 0 {
+		l.WithField("event", fmt.Sprintf("%d customers for %v", cache.Size, cache.TTL)).Info("Caching enabled")
+		return NewCachingRepository(repo, cache)
+	}
+	return repo
+}
+
+// Returns the repository of provider along with its name
+func newProviderRepository(l *logrus.Logger, db config.Database, p events.Publisher) (repository.CustomerRepository, string) {
+	provider := db.Provider
 	if !isValid(provider) {
 		l.WithField(
 			"event", fmt.Sprintf("defaulting to %s", in_memory),
@@ -26,13 +46,98 @@ func NewRepository(l *logrus.Logger, provider string) repository.CustomerReposit
 	}
 	switch strings.ToLower(provider) {
 	case "psql":
-		return NewPostgresCustomerRepository(l)
+		return NewPostgresCustomerRepository(l, db), psql
 	default:
 		var customers []repository.Customer
 		c, _ := LoadFromCSVFile(l, "./migrations/data.csv")
 		if c != nil {
 			customers = c
 		}
-		return &InMemoryCustomerRepository{Customers: customers}
+		r := NewInMemoryCustomerRepository(customers)
+		r.Events = p
+		return r, in_memory
+	}
+}
+
+// Returns the name of the provider of repo
+func ProviderName(repo repository.CustomerRepository) string {
+	if _, ok := unwrap(repo).(*PostgresCustomerRepository); ok {
+		return psql
+	}
+	return in_memory
+}
+
+// Returns the provider repository behind the decorators wrapping repo
+func unwrap(repo repository.CustomerRepository) repository.CustomerRepository {
+	for {
+		w, ok := repo.(interface {
+			Unwrap() repository.CustomerRepository
+		})
+		if !ok {
+			return repo
+		}
+		repo = w.Unwrap()
+	}
+}
+
+// Returns a WebhookRepository stored alongside the customers of repo
+func NewWebhookRepository(repo repository.CustomerRepository) repository.WebhookRepository {
+	if r, ok := unwrap(repo).(*PostgresCustomerRepository); ok {
+		return &PostgresWebhookRepository{db: r.db, logger: r.logger}
+	}
+	return NewInMemoryWebhookRepository()
+}
+
+// Returns a QuotaRepository stored alongside the customers of repo
+func NewQuotaRepository(repo repository.CustomerRepository) repository.QuotaRepository {
+	if r, ok := unwrap(repo).(*PostgresCustomerRepository); ok {
+		return &PostgresQuotaRepository{db: r.db}
+	}
+	return NewInMemoryQuotaRepository()
+}
+
+// Returns an IdempotencyRepository stored alongside the customers of repo
+func NewIdempotencyRepository(repo repository.CustomerRepository) repository.IdempotencyRepository {
+	if r, ok := unwrap(repo).(*PostgresCustomerRepository); ok {
+		return &PostgresIdempotencyRepository{db: r.db}
+	}
+	return NewInMemoryIdempotencyRepository()
+}
+
+// Last migration the repositories depend on, see migrations/
+const RequiredSchemaVersion = 12
+
+// Returns the number of the last migration applied to the database of
+// repo, or RequiredSchemaVersion when repo has no schema
+func SchemaVersion(ctx context.Context, repo repository.CustomerRepository) (int, error) {
+	if r, ok := unwrap(repo).(*PostgresCustomerRepository); ok {
+		return r.SchemaVersion(ctx)
+	}
+	return RequiredSchemaVersion, nil
+}
+
+// Returns the connection pool of repo, or nil when it has none
+func ConnectionPool(repo repository.CustomerRepository) *sql.DB {
+	if r, ok := unwrap(repo).(*PostgresCustomerRepository); ok {
+		return r.db
+	}
+	return nil
+}
+
+// Returns the read replicas of repo to check in the background, or nil
+// when it has none
+func Replicas(repo repository.CustomerRepository) *ReplicaSet {
+	if r, ok := unwrap(repo).(*PostgresCustomerRepository); ok && len(r.replicas.replicas) > 0 {
+		return r.replicas
+	}
+	return nil
+}
+
+// Returns the OutboxRepository holding the events of repo, or nil when
+// repo publishes its events directly
+func NewOutboxRepository(repo repository.CustomerRepository) repository.OutboxRepository {
+	if r, ok := unwrap(repo).(*PostgresCustomerRepository); ok {
+		return &PostgresOutboxRepository{db: r.db}
 	}
+	return nil
 }
@@ -1,122 +1,534 @@
 package providers
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"strings"
+	"time"
 
+	"github.com/EdmundHusserl/CRM/internal/config"
+	"github.com/EdmundHusserl/CRM/internal/logging"
 	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/XSAM/otelsql"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type PostgresCustomerRepository struct {
-	db *sql.DB
+	// db is the primary, receiving every write
+	db       *sql.DB
+	replicas *ReplicaSet
+	connStr  string
+	logger   *logrus.Logger
 }
 
-func getEnvOrDefault(envVarName, defaultTo string) string {
-	envVar := os.Getenv(envVarName)
-	if len(envVar) == 0 {
-		return defaultTo
+// Opens the database described by cfg and waits for it to answer for up
+// to its ConnectTimeout
+func NewPostgresCustomerRepository(l *logrus.Logger, cfg config.Database) *PostgresCustomerRepository {
+	params, err := connectionParams(cfg)
+	if err != nil {
+		l.WithField("error", err.Error()).Fatal("error opening psql instance")
 	}
-	return envVar
-}
-
-// Produces connection string
-func getConnectionString() string {
-	dbHost := getEnvOrDefault("DB_HOST", "localhost")
-	port := getEnvOrDefault("DB_PORT", "5432")
-	dbUser := getEnvOrDefault("DB_USER", "postgres")
-	password := os.Getenv("DB_PASSWORD")
-	dbName := getEnvOrDefault("DB_NAME", "customers")
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, port, dbUser, password, dbName)
-
-	return connStr
-}
+	connStr := connectionString(params, false)
 
-func NewPostgresCustomerRepository(l *logrus.Logger) *PostgresCustomerRepository {
-	connStr := getConnectionString()
+	l.WithField("event", fmt.Sprintf("attempting psql connection with %s", connectionString(params, true))).Info("db connection")
 
-	l.WithField("event", fmt.Sprintf("attempting psql connection with %s", connStr)).Info("db connection")
-
-	db, err := sql.Open("postgres", connStr)
+	db, err := openPostgres(connStr)
 	if err != nil {
 		l.WithField(
 			"error",
 			err.Error(),
 		).Fatal("error opening psql instance")
 	}
+	configurePool(db, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
+	if err := waitForDatabase(ctx, l, db.PingContext); err != nil {
+		l.WithField("error", err.Error()).Fatal("error connecting to psql instance")
+	}
 
-	return &PostgresCustomerRepository{db: db}
+	replicas, err := newReplicaSet(l, db, cfg)
+	if err != nil {
+		l.WithField("error", err.Error()).Fatal("error opening psql replicas")
+	}
+	if len(cfg.Replicas) > 0 {
+		l.WithField("event", fmt.Sprintf("%d replicas", len(cfg.Replicas))).Info("db connection")
+		replicas.Check(ctx)
+	}
+
+	return &PostgresCustomerRepository{db: db, replicas: replicas, connStr: connStr, logger: l}
+}
+
+// Opens a database whose statements are traced as children of the span of
+// their context
+func openPostgres(connStr string) (*sql.DB, error) {
+	return otelsql.Open("postgres", connStr,
+		otelsql.WithAttributes(attribute.String("db.system", "postgresql")),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}),
+	)
+}
+
+const customerColumns = "id, tenant_id, name, role, email, phone_number, contacted, owner_id, deleted_at"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Scans a row selected with customerColumns
+func scanCustomer(row rowScanner) (*repository.Customer, error) {
+	var (
+		c         repository.Customer
+		owner     uuid.NullUUID
+		deletedAt sql.NullTime
+	)
+	if err := row.Scan(&c.ID, &c.TenantID, &c.Name, &c.Role, &c.Email, &c.PhoneNumber, &c.Contacted, &owner, &deletedAt); err != nil {
+		return nil, err
+	}
+	c.OwnerID = owner.UUID
+	if deletedAt.Valid {
+		c.DeletedAt = &deletedAt.Time
+	}
+	return &c, nil
+}
+
+// Accumulates the conditions of a WHERE clause and their arguments
+type whereClause struct {
+	conditions []string
+	args       []any
+}
+
+// Adds a condition, a single %d verb is replaced by the placeholder of arg
+func (w *whereClause) add(condition string, arg any) {
+	w.args = append(w.args, arg)
+	w.conditions = append(w.conditions, fmt.Sprintf(condition, len(w.args)))
+}
+
+// Adds a condition taking no argument
+func (w *whereClause) addStatic(condition string) {
+	w.conditions = append(w.conditions, condition)
+}
+
+func (w *whereClause) String() string {
+	if len(w.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conditions, " AND ")
+}
+
+// Maps uuid.Nil to SQL NULL
+func nullableUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
 }
 
 func (r *PostgresCustomerRepository) CloseDBConnection() error {
-	return r.db.Close()
+	return errors.Join(r.replicas.Close(), r.db.Close())
 }
 
-func (r *PostgresCustomerRepository) Create(c repository.Customer) error {
-	_, err := r.db.Exec(
-		"INSERT INTO customers (id, name, role, email, phone_number, contacted) VALUES ($1, $2, $3, $4, $5, $6)",
-		c.ID, c.Name, c.Role, c.Email, c.PhoneNumber, c.Contacted)
-	return err
+func (r *PostgresCustomerRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
-func (r *PostgresCustomerRepository) Get(id uuid.UUID) (*repository.Customer, error) {
-	c := &repository.Customer{}
-	err := r.db.QueryRow(
-		"SELECT id, name, role, email, phone_number, contacted FROM customers WHERE id=$1", id).Scan(&c.ID, &c.Name, &c.Role, &c.Email, &c.PhoneNumber, &c.Contacted)
+// SchemaVersion returns the number of the last migration applied.
+func (r *PostgresCustomerRepository) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := r.db.QueryRowContext(ctx, "SELECT coalesce(max(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
 
-	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
+// Scope of the calls spanning every tenant, see the policies of migrations
+// 008 and 011
+const allTenants = "*"
+
+type acrossTenantsKey struct{}
+
+// Returns a copy of ctx whose statements see the rows of every tenant
+func acrossTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, acrossTenantsKey{}, true)
+}
+
+// Returns the tenant the row-level security policies restrict the
+// statements of ctx to
+func tenantScope(ctx context.Context) string {
+	if across, _ := ctx.Value(acrossTenantsKey{}).(bool); across {
+		return allTenants
 	}
-	return c, err
+	return string(repository.TenantFromContext(ctx))
+}
+
+// Runs fn in a transaction on the primary committed when fn succeeds
+func (r *PostgresCustomerRepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return inTenantTx(ctx, r.logger, r.db, nil, fn)
+}
+
+// Runs fn in a read-only transaction on the database serving the reads of
+// ctx
+func (r *PostgresCustomerRepository) read(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return inTenantTx(ctx, r.logger, r.replicas.Reader(ctx), &sql.TxOptions{ReadOnly: true}, fn)
+}
+
+// Runs fn in a transaction on db scoped to the tenant of ctx, committed
+// when fn succeeds
+func inTenantTx(ctx context.Context, l *logrus.Logger, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		logging.FromContext(ctx, l).WithField("error", err.Error()).Warn("DB operational error")
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('crm.tenant', $1, true)", tenantScope(ctx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logging.FromContext(ctx, l).WithField("error", err.Error()).Warn("DB operational error")
+		tx.Rollback()
+		return err
+	}
+	return nil
+}
+
+// Records a mutation performed within the current transaction
+type auditFunc func(op repository.Operation, before, after *repository.Customer) error
+
+// Runs fn in a transaction. The mutations fn records are audited and
+// their events written to the outbox within the transaction, which is
+// marked so that the change listener ignores it.
+func (r *PostgresCustomerRepository) mutate(ctx context.Context, fn func(tx *sql.Tx, audit auditFunc) error) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL crm.origin = 'api'"); err != nil {
+			return err
+		}
+		return fn(tx, func(op repository.Operation, before, after *repository.Customer) error {
+			e := newAuditEntry(ctx, op, before, after)
+			if err := insertAudit(ctx, tx, e); err != nil {
+				return err
+			}
+			return insertOutbox(ctx, tx, e)
+		})
+	})
+}
+
+// Records the audit entry of a mutation within its transaction
+func insertAudit(ctx context.Context, tx *sql.Tx, e repository.AuditEntry) error {
+	before, err := json.Marshal(e.Before)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(e.After)
+	if err != nil {
+		return err
+	}
+	diff, err := json.Marshal(e.Diff)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO customer_audit (tenant_id, customer_id, actor_id, actor_name, operation, request_id, occurred_at, before, after, diff) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		e.TenantID, e.CustomerID, nullableUUID(e.ActorID), e.ActorName, e.Operation, e.RequestID, e.OccurredAt, before, after, diff)
+	return err
 }
 
-func (r *PostgresCustomerRepository) GetAll() ([]repository.Customer, error) {
-	rows, err := r.db.Query("SELECT id, name, role, email, phone_number, contacted FROM customers")
+// Runs a statement returning customerColumns rows within tx
+func queryCustomers(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]repository.Customer, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	var customers []repository.Customer
 	for rows.Next() {
-		var c repository.Customer
-		if err := rows.Scan(&c.ID, &c.Name, &c.Role, &c.Email, &c.PhoneNumber, &c.Contacted); err != nil {
+		c, err := scanCustomer(rows)
+		if err != nil {
 			return nil, err
 		}
-		customers = append(customers, c)
+		customers = append(customers, *c)
+	}
+	return customers, rows.Err()
+}
+
+// Selects a live customer of the tenant of ctx and locks its row until the
+// end of tx
+func selectForUpdate(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*repository.Customer, error) {
+	row := tx.QueryRowContext(ctx,
+		"SELECT "+customerColumns+" FROM customers WHERE id=$1 AND tenant_id=$2 AND deleted_at IS NULL FOR UPDATE",
+		id, repository.TenantFromContext(ctx))
+	c, err := scanCustomer(row)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("user not found")
 	}
-	return customers, nil
+	return c, err
 }
 
-func (r *PostgresCustomerRepository) Delete(id uuid.UUID) error {
-	_, err := r.db.Exec("DELETE FROM customers WHERE id=$1", id)
+// Wraps the unique violations reported by err into repository.ErrConflict
+func conflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return fmt.Errorf("%w: %s", repository.ErrConflict, pqErr.Message)
+	}
 	return err
 }
 
-func (r *PostgresCustomerRepository) Update(c repository.Customer) error {
-	tx, err := r.db.Begin()
-	if err != nil {
-		fmt.Printf("DB operational error: %v\n", err)
+func (r *PostgresCustomerRepository) Create(ctx context.Context, c repository.Customer) error {
+	c.TenantID = repository.TenantFromContext(ctx)
+	// Customers are only trashed by Delete
+	c.DeletedAt = nil
+	return r.mutate(ctx, func(tx *sql.Tx, audit auditFunc) error {
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO customers (id, tenant_id, name, role, email, phone_number, contacted, owner_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+			c.ID, c.TenantID, c.Name, c.Role, c.Email, c.PhoneNumber, c.Contacted, nullableUUID(c.OwnerID))
+		if err != nil {
+			return conflict(err)
+		}
+		return audit(repository.OperationCreate, nil, &c)
+	})
+}
+
+func (r *PostgresCustomerRepository) Get(ctx context.Context, id uuid.UUID) (*repository.Customer, error) {
+	var c *repository.Customer
+	err := r.read(ctx, func(tx *sql.Tx) (err error) {
+		c, err = scanCustomer(tx.QueryRowContext(
+			ctx,
+			"SELECT "+customerColumns+" FROM customers WHERE id=$1 AND tenant_id=$2 AND deleted_at IS NULL",
+			id, repository.TenantFromContext(ctx)))
 		return err
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, errors.New("user not found")
 	}
-	query := "UPDATE customers SET name=$2, role=$3, email=$4, phone_number=$5, contacted=$6 WHERE id=$1"
-	_, err = tx.Exec(query, c.ID, c.Name, c.Role, c.Email, c.PhoneNumber, c.Contacted)
-	if err != nil {
-		fmt.Printf("DB operational error: %v\n", err)
-		tx.Rollback()
-		return err
+	return c, err
+}
+
+func (r *PostgresCustomerRepository) GetAll(ctx context.Context, f repository.CustomerFilter) ([]repository.Customer, error) {
+	var where whereClause
+	where.add("tenant_id=$%d", repository.TenantFromContext(ctx))
+	if f.OwnerID != uuid.Nil {
+		where.add("owner_id=$%d", f.OwnerID)
 	}
-	err = tx.Commit()
+	if f.Deleted {
+		where.addStatic("deleted_at IS NOT NULL")
+	} else {
+		where.addStatic("deleted_at IS NULL")
+	}
+	if f.After != uuid.Nil {
+		where.add("id>$%d", f.After)
+	}
+	query := "SELECT " + customerColumns + " FROM customers" + where.String() + " ORDER BY id"
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+
+	var customers []repository.Customer
+	err := r.read(ctx, func(tx *sql.Tx) (err error) {
+		customers, err = queryCustomers(ctx, tx, query, where.args...)
+		return err
+	})
+	return customers, err
+}
+
+func (r *PostgresCustomerRepository) CountByRole(ctx context.Context) (map[repository.CustomerRole]int, error) {
+	counts := map[repository.CustomerRole]int{}
+	err := r.read(acrossTenants(ctx), func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT role, count(*) FROM customers WHERE deleted_at IS NULL GROUP BY role")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				role  repository.CustomerRole
+				count int
+			)
+			if err := rows.Scan(&role, &count); err != nil {
+				return err
+			}
+			counts[role] = count
+		}
+		return rows.Err()
+	})
+	return counts, err
+}
+
+func (r *PostgresCustomerRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.mutate(ctx, func(tx *sql.Tx, audit auditFunc) error {
+		before, err := selectForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		after, err := scanCustomer(tx.QueryRowContext(
+			ctx,
+			"UPDATE customers SET deleted_at=now() WHERE id=$1 RETURNING "+customerColumns, id))
+		if err != nil {
+			return err
+		}
+		return audit(repository.OperationDelete, before, after)
+	})
+}
+
+func (r *PostgresCustomerRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	return r.mutate(ctx, func(tx *sql.Tx, audit auditFunc) error {
+		before, err := scanCustomer(tx.QueryRowContext(
+			ctx,
+			"SELECT "+customerColumns+" FROM customers WHERE id=$1 AND tenant_id=$2 AND deleted_at IS NOT NULL FOR UPDATE",
+			id, repository.TenantFromContext(ctx)))
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: user %s in trash", repository.ErrNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+		after, err := scanCustomer(tx.QueryRowContext(
+			ctx,
+			"UPDATE customers SET deleted_at=NULL WHERE id=$1 RETURNING "+customerColumns, id))
+		if err != nil {
+			return conflict(err)
+		}
+		return audit(repository.OperationRestore, before, after)
+	})
+}
+
+func (r *PostgresCustomerRepository) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	count := 0
+	err := r.mutate(acrossTenants(ctx), func(tx *sql.Tx, audit auditFunc) error {
+		purged, err := queryCustomers(ctx, tx,
+			"DELETE FROM customers WHERE deleted_at < $1 RETURNING "+customerColumns, deletedBefore)
+		if err != nil {
+			return err
+		}
+		for _, before := range purged {
+			if err := audit(repository.OperationPurge, &before, nil); err != nil {
+				return err
+			}
+		}
+		count = len(purged)
+		return nil
+	})
+	return count, err
+}
+
+func (r *PostgresCustomerRepository) Update(ctx context.Context, c repository.Customer) error {
+	err := r.mutate(ctx, func(tx *sql.Tx, audit auditFunc) error {
+		before, err := selectForUpdate(ctx, tx, c.ID)
+		if err != nil {
+			return err
+		}
+		query := "UPDATE customers SET name=$2, role=$3, email=$4, phone_number=$5, contacted=$6 WHERE id=$1 RETURNING " + customerColumns
+		after, err := scanCustomer(tx.QueryRowContext(ctx, query, c.ID, c.Name, c.Role, c.Email, c.PhoneNumber, c.Contacted))
+		if err != nil {
+			return conflict(err)
+		}
+		return audit(repository.OperationUpdate, before, after)
+	})
 	if err != nil {
-		fmt.Printf("DB operational error: %v\n", err)
-		tx.Rollback()
 		return err
 	}
-	fmt.Println("Transaction commited successfully")
+	logging.FromContext(ctx, r.logger).WithField("event", fmt.Sprintf("ID: %v", c.ID)).Debug("Transaction committed")
 	return nil
 }
+
+func (r *PostgresCustomerRepository) Assign(ctx context.Context, id, ownerID uuid.UUID) error {
+	return r.mutate(ctx, func(tx *sql.Tx, audit auditFunc) error {
+		before, err := selectForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		after, err := scanCustomer(tx.QueryRowContext(
+			ctx,
+			"UPDATE customers SET owner_id=$2 WHERE id=$1 RETURNING "+customerColumns,
+			id, nullableUUID(ownerID)))
+		if err != nil {
+			return err
+		}
+		return audit(repository.OperationAssign, before, after)
+	})
+}
+
+func (r *PostgresCustomerRepository) Reassign(ctx context.Context, from, to uuid.UUID) (int, error) {
+	count := 0
+	err := r.mutate(ctx, func(tx *sql.Tx, audit auditFunc) error {
+		reassigned, err := queryCustomers(ctx, tx,
+			"UPDATE customers SET owner_id=$2 WHERE owner_id=$1 AND tenant_id=$3 AND deleted_at IS NULL RETURNING "+customerColumns,
+			from, nullableUUID(to), repository.TenantFromContext(ctx))
+		if err != nil {
+			return err
+		}
+
+		for _, after := range reassigned {
+			before := after
+			before.OwnerID = from
+			if err := audit(repository.OperationAssign, &before, &after); err != nil {
+				return err
+			}
+		}
+		count = len(reassigned)
+		return nil
+	})
+	return count, err
+}
+
+func (r *PostgresCustomerRepository) AuditEntries(ctx context.Context, f repository.AuditFilter) ([]repository.AuditEntry, error) {
+	var where whereClause
+	where.add("tenant_id=$%d", repository.TenantFromContext(ctx))
+	if f.CustomerID != uuid.Nil {
+		where.add("customer_id=$%d", f.CustomerID)
+	}
+	if f.ActorID != uuid.Nil {
+		where.add("actor_id=$%d", f.ActorID)
+	}
+	if f.Operation != "" {
+		where.add("operation=$%d", f.Operation)
+	}
+	if !f.Since.IsZero() {
+		where.add("occurred_at>=$%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		where.add("occurred_at<$%d", f.Until)
+	}
+
+	query := "SELECT id, tenant_id, customer_id, actor_id, actor_name, operation, request_id, occurred_at, before, after, diff FROM customer_audit" + where.String()
+	query += " ORDER BY id"
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", f.Limit)
+	}
+
+	entries := []repository.AuditEntry{}
+	err := r.read(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, where.args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var (
+				e                   repository.AuditEntry
+				actor               uuid.NullUUID
+				before, after, diff []byte
+			)
+			if err := rows.Scan(&e.ID, &e.TenantID, &e.CustomerID, &actor, &e.ActorName, &e.Operation, &e.RequestID, &e.OccurredAt, &before, &after, &diff); err != nil {
+				return err
+			}
+			e.ActorID = actor.UUID
+			if err := errors.Join(
+				json.Unmarshal(before, &e.Before),
+				json.Unmarshal(after, &e.After),
+				json.Unmarshal(diff, &e.Diff),
+			); err != nil {
+				return err
+			}
+			entries = append(entries, e)
+		}
+		return rows.Err()
+	})
+	return entries, err
+}
@@ -0,0 +1,134 @@
+package providers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Bounds of the delay between startup connection attempts
+	minConnectBackoff = 250 * time.Millisecond
+	maxConnectBackoff = 5 * time.Second
+	redacted          = "<redacted>"
+)
+
+// Returns the parameters of the connection described by cfg, the parts of
+// its URL overriding the other settings
+func connectionParams(cfg config.Database) (map[string]string, error) {
+	params := map[string]string{
+		"host":    cfg.Host,
+		"port":    fmt.Sprint(cfg.Port),
+		"user":    cfg.User,
+		"dbname":  cfg.Name,
+		"sslmode": cfg.SSLMode,
+	}
+	for key, value := range map[string]string{
+		"password":    string(cfg.Password),
+		"sslrootcert": cfg.SSLRootCert,
+		"sslcert":     cfg.SSLCert,
+		"sslkey":      cfg.SSLKey,
+	} {
+		if value != "" {
+			params[key] = value
+		}
+	}
+	if cfg.URL == "" {
+		return params, nil
+	}
+	if err := applyURL(params, string(cfg.URL)); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// Overrides params with the parts of the connection URL raw
+func applyURL(params map[string]string, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		// The error would print the password
+		return errors.New("invalid database URL")
+	}
+	if u.User != nil {
+		params["user"] = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			params["password"] = password
+		}
+	}
+	if host := u.Hostname(); host != "" {
+		params["host"] = host
+	}
+	if port := u.Port(); port != "" {
+		params["port"] = port
+	}
+	if name := strings.TrimPrefix(u.Path, "/"); name != "" {
+		params["dbname"] = name
+	}
+	for key, values := range u.Query() {
+		params[key] = values[len(values)-1]
+	}
+	return nil
+}
+
+// Returns the key/value connection string of params, with its password
+// replaced when redact is set
+func connectionString(params map[string]string, redact bool) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := params[key]
+		if redact && key == "password" {
+			value = redacted
+		}
+		value = strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+		pairs = append(pairs, fmt.Sprintf("%s='%s'", key, value))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// Applies the pool settings of cfg to db
+func configurePool(db *sql.DB, cfg config.Database) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// Pings the database until it answers, backing off between attempts,
+// for as long as ctx allows
+func waitForDatabase(ctx context.Context, l *logrus.Logger, ping func(context.Context) error) error {
+	backoff := minConnectBackoff
+	for attempt := 1; ; attempt++ {
+		// A single attempt must not use up the whole timeout
+		attemptCtx, cancel := context.WithTimeout(ctx, maxConnectBackoff)
+		err := ping(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		l.WithFields(logrus.Fields{
+			"error":   err.Error(),
+			"attempt": attempt,
+		}).Warn(fmt.Sprintf("Database unavailable, retrying in %v", backoff))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database unavailable after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxConnectBackoff)
+	}
+}
@@ -0,0 +1,64 @@
+package providers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+)
+
+type PostgresIdempotencyRepository struct {
+	db *sql.DB
+}
+
+func (r *PostgresIdempotencyRepository) ReserveIdempotencyKey(ctx context.Context, rec repository.IdempotencyRecord, now time.Time) (*repository.IdempotencyRecord, error) {
+	// Expired keys are taken over
+	err := r.db.QueryRowContext(
+		ctx,
+		`INSERT INTO idempotency_keys (client, key, fingerprint, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (client, key) DO UPDATE
+		SET fingerprint=EXCLUDED.fingerprint, status=0, content_type='', body=NULL, expires_at=EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= $5
+		RETURNING client`,
+		rec.Client, rec.Key, rec.Fingerprint, rec.ExpiresAt, now).Scan(&rec.Client)
+	if err == nil {
+		return nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var existing repository.IdempotencyRecord
+	err = r.db.QueryRowContext(
+		ctx,
+		"SELECT client, key, fingerprint, status, content_type, body, expires_at FROM idempotency_keys WHERE client=$1 AND key=$2",
+		rec.Client, rec.Key).Scan(&existing.Client, &existing.Key, &existing.Fingerprint, &existing.Status, &existing.ContentType, &existing.Body, &existing.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("idempotency key released concurrently")
+	}
+	return &existing, err
+}
+
+func (r *PostgresIdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, rec repository.IdempotencyRecord) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		"UPDATE idempotency_keys SET status=$3, content_type=$4, body=$5 WHERE client=$1 AND key=$2",
+		rec.Client, rec.Key, rec.Status, rec.ContentType, rec.Body)
+	return err
+}
+
+func (r *PostgresIdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, client, key string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE client=$1 AND key=$2", client, key)
+	return err
+}
+
+func (r *PostgresIdempotencyRepository) PurgeIdempotencyKeys(ctx context.Context, now time.Time) (int, error) {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at<=$1", now)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// Channel the customers trigger notifies, see migrations/006.sql
+const changesChannel string = "customer_changes"
+
+// Notification sent by the customers trigger
+type rowChange struct {
+	Operation  string               `json:"operation"`
+	OccurredAt time.Time            `json:"occurred_at"`
+	Before     *repository.Customer `json:"before"`
+	After      *repository.Customer `json:"after"`
+}
+
+// ChangeListener publishes the changes made to the customers table
+// outside of the API, by backfills or psql sessions for instance. Changes
+// made while the listener is disconnected are not caught up on.
+type ChangeListener struct {
+	Logger       *logrus.Logger
+	Publisher    events.Publisher
+	MinReconnect time.Duration
+	MaxReconnect time.Duration
+	connStr      string
+}
+
+// Returns a ChangeListener publishing the direct changes to the database
+// of repo to p, or nil when repo cannot be changed directly
+func NewChangeListener(l *logrus.Logger, repo repository.CustomerRepository, p events.Publisher) *ChangeListener {
+	r, ok := unwrap(repo).(*PostgresCustomerRepository)
+	if !ok {
+		return nil
+	}
+	return &ChangeListener{
+		Logger:       l,
+		Publisher:    p,
+		MinReconnect: time.Second,
+		MaxReconnect: time.Minute,
+		connStr:      r.connStr,
+	}
+}
+
+// Run listens for changes until ctx is done, reconnecting whenever the
+// connection is lost.
+func (c *ChangeListener) Run(ctx context.Context) {
+	listener := pq.NewListener(c.connStr, c.MinReconnect, c.MaxReconnect, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventConnected:
+			c.Logger.WithField("event", changesChannel).Info("Listening for database changes")
+		case pq.ListenerEventDisconnected, pq.ListenerEventConnectionAttemptFailed:
+			c.Logger.WithField("error", fmt.Sprint(err)).Warn("Database change listener disconnected")
+		case pq.ListenerEventReconnected:
+			c.Logger.WithField("event", changesChannel).Warn("Database change listener reconnected, changes may have been missed")
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(changesChannel); err != nil {
+		c.Logger.WithField("error", err.Error()).Warn("Failed to listen for database changes")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			if n == nil {
+				// Sent after reconnecting
+				continue
+			}
+			e, ok, err := changeEvent([]byte(n.Extra))
+			if err != nil {
+				c.Logger.WithField("error", err.Error()).Warn("Invalid database change notification")
+				continue
+			}
+			if ok {
+				c.Publisher.Publish(ctx, e)
+			}
+		case <-time.After(90 * time.Second):
+			// Detects dead connections while idle
+			go listener.Ping()
+		}
+	}
+}
+
+// Maps a notification onto the lifecycle event it represents, ok is false
+// for changes to customers already in the trash.
+func changeEvent(payload []byte) (e events.Event, ok bool, err error) {
+	var change rowChange
+	if err := json.Unmarshal(payload, &change); err != nil {
+		return events.Event{}, false, err
+	}
+	before, after := change.Before, change.After
+
+	e = events.Event{
+		ID:         uuid.New(),
+		OccurredAt: change.OccurredAt,
+		Customer:   after,
+		Changes:    repository.Diff(before, after),
+	}
+	switch {
+	case change.Operation == "INSERT" && after != nil:
+		e.Type = events.CustomerCreated
+		e.CustomerID = after.ID
+	case change.Operation == "UPDATE" && before != nil && after != nil:
+		e.CustomerID = after.ID
+		switch {
+		case before.DeletedAt == nil && after.DeletedAt != nil:
+			e.Type = events.CustomerDeleted
+		case before.DeletedAt != nil && after.DeletedAt != nil:
+			return events.Event{}, false, nil
+		default:
+			e.Type = events.CustomerUpdated
+		}
+	case change.Operation == "DELETE" && before != nil:
+		if before.DeletedAt != nil {
+			return events.Event{}, false, nil
+		}
+		e.Type = events.CustomerDeleted
+		e.CustomerID = before.ID
+	default:
+		return events.Event{}, false, fmt.Errorf("unexpected %q change", change.Operation)
+	}
+	if after != nil {
+		e.TenantID = after.TenantID
+	} else {
+		e.TenantID = before.TenantID
+	}
+	return e, true, nil
+}
@@ -0,0 +1,93 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/EdmundHusserl/CRM/internal/events"
+)
+
+func TestChangeEvent(t *testing.T) {
+	const (
+		live    = `{"id":"5d1b0c9e-6f8a-4d8e-9a57-1b2f7f0c8e11","tenant_id":"emea","name":"Wladston","role":1,"email":"wladston@corp.com","phone_number":"514-555-6666","contacted":false,"owner_id":null,"deleted_at":null}`
+		renamed = `{"id":"5d1b0c9e-6f8a-4d8e-9a57-1b2f7f0c8e11","tenant_id":"emea","name":"Wlad","role":1,"email":"wladston@corp.com","phone_number":"514-555-6666","contacted":false,"owner_id":null,"deleted_at":null}`
+		trashed = `{"id":"5d1b0c9e-6f8a-4d8e-9a57-1b2f7f0c8e11","tenant_id":"emea","name":"Wladston","role":1,"email":"wladston@corp.com","phone_number":null,"contacted":false,"owner_id":null,"deleted_at":"2026-10-19T08:30:00.123456+00:00"}`
+	)
+
+	tests := []struct {
+		name        string
+		payload     string
+		want        events.Type
+		wantChanges []string
+		wantOK      bool
+		wantErr     bool
+	}{
+		{
+			name:    "Insert",
+			payload: `{"operation":"INSERT","occurred_at":"2026-10-19T08:30:00.123456+00:00","before":null,"after":` + live + `}`,
+			want:    events.CustomerCreated,
+			wantOK:  true,
+		},
+		{
+			name:        "Update",
+			payload:     `{"operation":"UPDATE","occurred_at":"2026-10-19T08:30:00+00:00","before":` + live + `,"after":` + renamed + `}`,
+			want:        events.CustomerUpdated,
+			wantChanges: []string{"name"},
+			wantOK:      true,
+		},
+		{
+			name:    "Soft_delete",
+			payload: `{"operation":"UPDATE","occurred_at":"2026-10-19T08:30:00+00:00","before":` + live + `,"after":` + trashed + `}`,
+			want:    events.CustomerDeleted,
+			wantOK:  true,
+		},
+		{
+			name:    "Restore",
+			payload: `{"operation":"UPDATE","occurred_at":"2026-10-19T08:30:00+00:00","before":` + trashed + `,"after":` + live + `}`,
+			want:    events.CustomerUpdated,
+			wantOK:  true,
+		},
+		{
+			name:    "Hard_delete",
+			payload: `{"operation":"DELETE","occurred_at":"2026-10-19T08:30:00+00:00","before":` + live + `,"after":null}`,
+			want:    events.CustomerDeleted,
+			wantOK:  true,
+		},
+		{
+			name:    "Purge_of_trashed_customer",
+			payload: `{"operation":"DELETE","occurred_at":"2026-10-19T08:30:00+00:00","before":` + trashed + `,"after":null}`,
+		},
+		{
+			name:    "Invalid_payload",
+			payload: `{"operation":"TRUNCATE"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, ok, err := changeEvent([]byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("changeEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("changeEvent() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if e.Type != tt.want || e.CustomerID.String() != "5d1b0c9e-6f8a-4d8e-9a57-1b2f7f0c8e11" {
+				t.Errorf("changeEvent() = %s of %v, want %s", e.Type, e.CustomerID, tt.want)
+			}
+			if e.TenantID != "emea" {
+				t.Errorf("changeEvent() tenant = %q, want %q", e.TenantID, "emea")
+			}
+			if tt.wantChanges != nil && len(e.Changes) != len(tt.wantChanges) {
+				t.Errorf("changeEvent() changes = %v, want %v", e.Changes, tt.wantChanges)
+			}
+			for _, field := range tt.wantChanges {
+				if _, found := e.Changes[field]; !found {
+					t.Errorf("changeEvent() changes = %v, want %v", e.Changes, tt.wantChanges)
+				}
+			}
+		})
+	}
+}
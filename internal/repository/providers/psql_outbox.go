@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/lib/pq"
+)
+
+// Advisory lock key serializing outbox relays across instances
+const outboxRelayLock int64 = 0x63726d6f7574 // "crmout"
+
+type PostgresOutboxRepository struct {
+	db *sql.DB
+}
+
+// Records the lifecycle event of a mutation within its transaction
+func insertOutbox(ctx context.Context, tx *sql.Tx, a repository.AuditEntry) error {
+	e, ok := events.FromAuditEntry(a)
+	if !ok {
+		return nil
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO outbox (aggregate_id, event_id, event_type, payload, created_at) VALUES ($1, $2, $3, $4, $5)",
+		e.CustomerID, e.ID, e.Type, payload, e.OccurredAt)
+	return err
+}
+
+func (r *PostgresOutboxRepository) RelayOutbox(ctx context.Context, limit int, send func([]repository.OutboxMessage) map[int64]error) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", outboxRelayLock).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		// Another instance is relaying
+		return 0, nil
+	}
+
+	// The messages of the customers held back by a failure come last, so
+	// that they cannot fill the batch
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT id, aggregate_id, event_id, event_type, payload, attempts, created_at FROM outbox o
+		WHERE published_at IS NULL
+		ORDER BY EXISTS (
+			SELECT 1 FROM outbox f
+			WHERE f.aggregate_id = o.aggregate_id AND f.published_at IS NULL AND f.attempts > 0 AND f.id <= o.id
+		), id LIMIT $1`,
+		limit)
+	if err != nil {
+		return 0, err
+	}
+	var messages []repository.OutboxMessage
+	for rows.Next() {
+		var (
+			m       repository.OutboxMessage
+			payload []byte
+		)
+		if err := rows.Scan(&m.ID, &m.AggregateID, &m.EventID, &m.EventType, &payload, &m.Attempts, &m.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		m.Payload = payload
+		messages = append(messages, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	var published []int64
+	for id, err := range send(messages) {
+		if err == nil {
+			published = append(published, id)
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE outbox SET attempts=attempts+1, last_error=$2 WHERE id=$1", id, err.Error()); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE outbox SET published_at=now() WHERE id = ANY($1)", pq.Array(published)); err != nil {
+		return 0, err
+	}
+	return len(published), tx.Commit()
+}
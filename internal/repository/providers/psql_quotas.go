@@ -0,0 +1,22 @@
+package providers
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type PostgresQuotaRepository struct {
+	db *sql.DB
+}
+
+func (r *PostgresQuotaRepository) ConsumeQuota(ctx context.Context, client string, day time.Time) (int, error) {
+	var used int
+	err := r.db.QueryRowContext(
+		ctx,
+		`INSERT INTO api_quotas (client, day, used) VALUES ($1, $2, 1)
+		ON CONFLICT (client, day) DO UPDATE SET used = api_quotas.used + 1
+		RETURNING used`,
+		client, day.UTC().Format(time.DateOnly)).Scan(&used)
+	return used, err
+}
@@ -0,0 +1,150 @@
+package providers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/config"
+	"github.com/EdmundHusserl/CRM/internal/consistency"
+	"github.com/sirupsen/logrus"
+)
+
+// Lag of a replica, zero when it has replayed everything it received
+const replicaLagQuery = `SELECT CASE
+    WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+    ELSE coalesce(extract(epoch FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+type replica struct {
+	// Connection string with its password redacted, for logs
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// ReplicaSet routes reads to the healthy replicas of a primary in turn,
+// falling back to the primary when none is.
+type ReplicaSet struct {
+	Logger   *logrus.Logger
+	Interval time.Duration
+	MaxLag   time.Duration
+
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+	// Returns the lag of a replica, replaced in tests
+	lag func(ctx context.Context, db *sql.DB) (time.Duration, error)
+}
+
+// Opens the replicas of cfg, none being healthy until checked
+func newReplicaSet(l *logrus.Logger, primary *sql.DB, cfg config.Database) (*ReplicaSet, error) {
+	s := &ReplicaSet{
+		Logger:   l,
+		Interval: cfg.ReplicaCheckInterval,
+		MaxLag:   cfg.MaxReplicaLag,
+		primary:  primary,
+		lag:      replicaLag,
+	}
+	for _, u := range cfg.Replicas {
+		params, err := connectionParams(cfg)
+		if err == nil {
+			err = applyURL(params, u)
+		}
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		db, err := openPostgres(connectionString(params, false))
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		configurePool(db, cfg)
+		s.replicas = append(s.replicas, &replica{name: connectionString(params, true), db: db})
+	}
+	return s, nil
+}
+
+func replicaLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	var seconds float64
+	if err := db.QueryRowContext(ctx, replicaLagQuery).Scan(&seconds); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Reader returns the database the reads of ctx go to: the primary for
+// read-your-writes contexts or when no replica is healthy.
+func (s *ReplicaSet) Reader(ctx context.Context) *sql.DB {
+	if consistency.ReadYourWrites(ctx) {
+		return s.primary
+	}
+	n := uint64(len(s.replicas))
+	start := s.next.Add(1)
+	for i := range n {
+		if r := s.replicas[(start+i)%n]; r.healthy.Load() {
+			return r.db
+		}
+	}
+	return s.primary
+}
+
+// Check evicts the replicas down or lagging more than MaxLag and brings
+// back the others.
+func (s *ReplicaSet) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range s.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, s.Interval)
+			defer cancel()
+
+			lag, err := s.lag(checkCtx, r.db)
+			if err == nil && lag > s.MaxLag {
+				err = fmt.Errorf("lagging %v behind the primary", lag.Round(time.Millisecond))
+			}
+			healthy := err == nil
+			if r.healthy.Swap(healthy) == healthy {
+				return
+			}
+			if healthy {
+				s.Logger.WithField("event", r.name).Info("Replica serving reads")
+			} else {
+				s.Logger.WithFields(logrus.Fields{
+					"event": r.name,
+					"error": err.Error(),
+				}).Warn("Replica evicted")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Run checks the replicas every Interval until ctx is done.
+func (s *ReplicaSet) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Check(ctx)
+		}
+	}
+}
+
+// Close closes the connections to the replicas.
+func (s *ReplicaSet) Close() error {
+	var errs []error
+	for _, r := range s.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
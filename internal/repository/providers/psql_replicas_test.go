@@ -0,0 +1,106 @@
+package providers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/consistency"
+	"github.com/sirupsen/logrus"
+)
+
+// Returns a replica set of n unchecked replicas, none being connected to a
+// database
+func newTestReplicaSet(t *testing.T, n int) *ReplicaSet {
+	t.Helper()
+	open := func() *sql.DB {
+		// Connections are only opened on use
+		db, err := sql.Open("postgres", "host=replica.invalid")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	s := &ReplicaSet{
+		Logger:   l,
+		Interval: time.Second,
+		MaxLag:   10 * time.Second,
+		primary:  open(),
+	}
+	for range n {
+		s.replicas = append(s.replicas, &replica{db: open()})
+	}
+	return s
+}
+
+func TestReplicaSetReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		healthy []bool
+		ctx     context.Context
+		// Indexes of the replicas expected over successive reads, -1 for
+		// the primary
+		want []int
+	}{
+		{"no replicas", nil, context.Background(), []int{-1, -1}},
+		{"round-robin", []bool{true, true, true}, context.Background(), []int{1, 2, 0, 1}},
+		{"unhealthy skipped", []bool{true, false, true}, context.Background(), []int{2, 2, 0, 2}},
+		{"all unhealthy", []bool{false, false}, context.Background(), []int{-1, -1}},
+		{"read your writes", []bool{true, true}, consistency.WithReadYourWrites(context.Background()), []int{-1, -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestReplicaSet(t, len(tt.healthy))
+			for i, healthy := range tt.healthy {
+				s.replicas[i].healthy.Store(healthy)
+			}
+			for read, want := range tt.want {
+				wantDB := s.primary
+				if want >= 0 {
+					wantDB = s.replicas[want].db
+				}
+				if got := s.Reader(tt.ctx); got != wantDB {
+					t.Errorf("read %d went to the wrong database, want %d", read, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReplicaSetCheck(t *testing.T) {
+	s := newTestReplicaSet(t, 3)
+	down := errors.New("connection refused")
+	lags := map[int]time.Duration{0: time.Second, 1: time.Minute}
+	errs := map[int]error{2: down}
+	s.lag = func(_ context.Context, db *sql.DB) (time.Duration, error) {
+		for i, r := range s.replicas {
+			if r.db == db {
+				return lags[i], errs[i]
+			}
+		}
+		return 0, nil
+	}
+
+	s.Check(context.Background())
+	for i, want := range []bool{true, false, false} {
+		if got := s.replicas[i].healthy.Load(); got != want {
+			t.Errorf("replica %d healthy = %v, want %v", i, got, want)
+		}
+	}
+
+	// Caught up and back up replicas serve reads again
+	lags[1] = 0
+	delete(errs, 2)
+	s.Check(context.Background())
+	for i := range s.replicas {
+		if !s.replicas[i].healthy.Load() {
+			t.Errorf("replica %d still evicted", i)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package providers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// PostgresWebhookRepository runs every statement in a transaction scoped
+// to the tenant of its context, see inTenantTx.
+type PostgresWebhookRepository struct {
+	db     *sql.DB
+	logger *logrus.Logger
+}
+
+func (r *PostgresWebhookRepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return inTenantTx(ctx, r.logger, r.db, nil, fn)
+}
+
+const deliveryColumns = "id, tenant_id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_error, created_at, delivered_at"
+
+// Scans a row selected with deliveryColumns
+func scanDelivery(row rowScanner) (*repository.WebhookDelivery, error) {
+	var (
+		d           repository.WebhookDelivery
+		payload     []byte
+		deliveredAt sql.NullTime
+	)
+	if err := row.Scan(&d.ID, &d.TenantID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &deliveredAt); err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return &d, nil
+}
+
+func (r *PostgresWebhookRepository) CreateSubscription(ctx context.Context, s repository.WebhookSubscription) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(
+			ctx,
+			"INSERT INTO webhook_subscriptions (id, tenant_id, url, events, secret, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+			s.ID, repository.TenantFromContext(ctx), s.URL, pq.Array(s.Events), s.Secret, s.CreatedAt)
+		return err
+	})
+}
+
+func (r *PostgresWebhookRepository) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id=$1 AND tenant_id=$2", id, repository.TenantFromContext(ctx))
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return errors.New("subscription not found")
+		}
+		return nil
+	})
+}
+
+func (r *PostgresWebhookRepository) ListSubscriptions(ctx context.Context) ([]repository.WebhookSubscription, error) {
+	subscriptions := []repository.WebhookSubscription{}
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			"SELECT id, tenant_id, url, events, secret, created_at FROM webhook_subscriptions WHERE tenant_id=$1 ORDER BY created_at",
+			repository.TenantFromContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var s repository.WebhookSubscription
+			if err := rows.Scan(&s.ID, &s.TenantID, &s.URL, pq.Array(&s.Events), &s.Secret, &s.CreatedAt); err != nil {
+				return err
+			}
+			subscriptions = append(subscriptions, s)
+		}
+		return rows.Err()
+	})
+	return subscriptions, err
+}
+
+func (r *PostgresWebhookRepository) EnqueueDeliveries(ctx context.Context, ds []repository.WebhookDelivery) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		for _, d := range ds {
+			_, err := tx.ExecContext(
+				ctx,
+				"INSERT INTO webhook_deliveries ("+deliveryColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+				d.ID, d.TenantID, d.SubscriptionID, d.EventID, d.EventType, []byte(d.Payload), d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.CreatedAt, d.DeliveredAt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimDeliveries claims the due deliveries of every tenant.
+func (r *PostgresWebhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]repository.WebhookDelivery, error) {
+	claimed := []repository.WebhookDelivery{}
+	err := r.withTx(acrossTenants(ctx), func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(
+			ctx,
+			`UPDATE webhook_deliveries SET next_attempt_at=$2 WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status='pending' AND next_attempt_at<=$1
+				ORDER BY next_attempt_at LIMIT $3 FOR UPDATE SKIP LOCKED
+			) RETURNING `+deliveryColumns,
+			now, now.Add(lease), limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			d, err := scanDelivery(rows)
+			if err != nil {
+				return err
+			}
+			// Report the schedule the delivery was due on, not its lease
+			d.NextAttemptAt = now
+			claimed = append(claimed, *d)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (r *PostgresWebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (*repository.WebhookDelivery, error) {
+	var d *repository.WebhookDelivery
+	err := r.withTx(ctx, func(tx *sql.Tx) (err error) {
+		d, err = scanDelivery(tx.QueryRowContext(ctx,
+			"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id=$1 AND tenant_id=$2",
+			id, repository.TenantFromContext(ctx)))
+		return err
+	})
+	if err == sql.ErrNoRows {
+		return nil, errors.New("delivery not found")
+	}
+	return d, err
+}
+
+func (r *PostgresWebhookRepository) ListDeliveries(ctx context.Context, status repository.DeliveryStatus) ([]repository.WebhookDelivery, error) {
+	var where whereClause
+	where.add("tenant_id=$%d", repository.TenantFromContext(ctx))
+	if status != "" {
+		where.add("status=$%d", status)
+	}
+	deliveries := []repository.WebhookDelivery{}
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries"+where.String()+" ORDER BY created_at", where.args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			d, err := scanDelivery(rows)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, *d)
+		}
+		return rows.Err()
+	})
+	return deliveries, err
+}
+
+func (r *PostgresWebhookRepository) UpdateDelivery(ctx context.Context, d repository.WebhookDelivery) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(
+			ctx,
+			"UPDATE webhook_deliveries SET status=$2, attempts=$3, next_attempt_at=$4, last_error=$5, delivered_at=$6 WHERE id=$1 AND tenant_id=$7",
+			d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.DeliveredAt, repository.TenantFromContext(ctx))
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return errors.New("delivery not found")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// QuotaRepository counts the requests of clients per UTC day, for the
+// daily quotas to survive restarts and be shared between instances.
+type QuotaRepository interface {
+	// ConsumeQuota records a request of client on day and returns the
+	// number of requests it made that day, this one included.
+	ConsumeQuota(ctx context.Context, client string, day time.Time) (int, error)
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// TenantID identifies the business unit owning customers, which are never
+// visible to the other tenants.
+type TenantID string
+
+// DefaultTenant is the tenant of single-tenant deployments and of the
+// calls made without one.
+const DefaultTenant TenantID = "default"
+
+var tenantPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ParseTenantID checks that s can name a tenant, in a header, a subdomain
+// or a token.
+func ParseTenantID(s string) (TenantID, error) {
+	if !tenantPattern.MatchString(s) {
+		return "", fmt.Errorf("invalid tenant %q, use up to 63 lowercase letters, digits and dashes", s)
+	}
+	return TenantID(s), nil
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx scoping repository calls to id.
+func WithTenant(ctx context.Context, id TenantID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant stored by WithTenant, or
+// DefaultTenant.
+func TenantFromContext(ctx context.Context) TenantID {
+	if id, ok := ctx.Value(tenantKey{}).(TenantID); ok {
+		return id
+	}
+	return DefaultTenant
+}
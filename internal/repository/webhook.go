@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSubscription asks for the events of the given types to be POSTed
+// to URL, signed with Secret.
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	TenantID  TenantID  `json:"tenant_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeliveryStatus is the state of a webhook delivery.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// Dead deliveries exhausted their attempts and wait for a manual
+	// redelivery.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is one event to be sent to one subscription.
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	TenantID       TenantID        `json:"tenant_id"`
+	SubscriptionID uuid.UUID       `json:"subscription_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// WebhookRepository stores the subscriptions and deliveries of every
+// tenant. Its calls are scoped to the tenant of their context, but for
+// ClaimDeliveries and UpdateDelivery which serve the delivery workers.
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, s WebhookSubscription) error
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+	ListSubscriptions(ctx context.Context) ([]WebhookSubscription, error)
+
+	EnqueueDeliveries(ctx context.Context, ds []WebhookDelivery) error
+	// ClaimDeliveries returns up to limit pending deliveries due at now and
+	// postpones them by lease so concurrent workers do not pick them too.
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error)
+	GetDelivery(ctx context.Context, id uuid.UUID) (*WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, status DeliveryStatus) ([]WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, d WebhookDelivery) error
+}
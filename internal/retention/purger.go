@@ -0,0 +1,56 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Purger periodically removes the customers kept in the trash for longer
+// than the retention period.
+type Purger struct {
+	Logger    *logrus.Logger
+	Repo      repository.CustomerRepository
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+func NewPurger(l *logrus.Logger, repo repository.CustomerRepository, retention, interval time.Duration) *Purger {
+	return &Purger{Logger: l, Repo: repo, Retention: retention, Interval: interval}
+}
+
+// Run purges the trash every Interval until ctx is done.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.PurgeOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeOnce removes the customers trashed before now minus Retention.
+func (p *Purger) PurgeOnce(ctx context.Context) (int, error) {
+	ctx = auth.WithUser(ctx, auth.User{Name: "retention", Role: auth.RoleAdmin})
+	cutoff := time.Now().Add(-p.Retention)
+	n, err := p.Repo.Purge(ctx, cutoff)
+	if err != nil {
+		p.Logger.WithField("error", err.Error()).Warn("Trash purge failure")
+		return n, err
+	}
+	if n > 0 {
+		p.Logger.WithField(
+			"event", fmt.Sprintf("%d customers deleted before %s", n, cutoff.Format(time.RFC3339)),
+		).Info("Trash purged")
+	}
+	return n, nil
+}
@@ -5,18 +5,45 @@ import (
 
 	_ "github.com/EdmundHusserl/CRM/docs"
 	"github.com/EdmundHusserl/CRM/internal/handlers"
+	"github.com/EdmundHusserl/CRM/internal/metrics"
+	"github.com/EdmundHusserl/CRM/internal/problem"
 	"github.com/gorilla/mux"
 	_ "github.com/swaggo/files"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func NewRouter(h handlers.CustomerHandler) *mux.Router {
+// NewRouter registers every public route, /metrics excepted. The requests
+// of every route, including the ones registered later, are counted. The
+// given middlewares wrap the /api routes only, in order.
+func NewRouter(h handlers.CustomerHandler, wh handlers.WebhookHandler, hh handlers.HealthHandler, middlewares ...mux.MiddlewareFunc) *mux.Router {
 	router := mux.NewRouter()
+	router.Use(metrics.Middleware)
+	router.NotFoundHandler = http.HandlerFunc(problem.NotFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(problem.MethodNotAllowedHandler)
 	router.PathPrefix("/docs").Handler(httpSwagger.WrapHandler)
-	router.HandleFunc("/api/customers/{id}", h.Delete).Methods(http.MethodDelete)
-	router.HandleFunc("/api/customers/{id}", h.Get).Methods(http.MethodGet)
-	router.HandleFunc("/api/customers", h.Update).Methods(http.MethodPatch)
-	router.HandleFunc("/api/customers", h.Create).Methods(http.MethodPost)
-	router.HandleFunc("/api/customers", h.GetAll).Methods(http.MethodGet)
+	router.HandleFunc("/healthz", hh.Healthz).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", hh.Readyz).Methods(http.MethodGet)
+	router.HandleFunc("/version", hh.Version).Methods(http.MethodGet)
+
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(middlewares...)
+	api.HandleFunc("/customers/import", h.Import).Methods(http.MethodPost)
+	api.HandleFunc("/customers/reassign", h.Reassign).Methods(http.MethodPost)
+	api.HandleFunc("/customers/trash", h.Trash).Methods(http.MethodGet)
+	api.HandleFunc("/customers/stream", h.Stream).Methods(http.MethodGet)
+	api.HandleFunc("/customers/{id}/assign", h.Assign).Methods(http.MethodPost)
+	api.HandleFunc("/customers/{id}/history", h.History).Methods(http.MethodGet)
+	api.HandleFunc("/customers/{id}/restore", h.Restore).Methods(http.MethodPost)
+	api.HandleFunc("/customers/{id}", h.Delete).Methods(http.MethodDelete)
+	api.HandleFunc("/customers/{id}", h.Get).Methods(http.MethodGet)
+	api.HandleFunc("/customers", h.Update).Methods(http.MethodPatch)
+	api.HandleFunc("/customers", h.Create).Methods(http.MethodPost)
+	api.HandleFunc("/customers", h.GetAll).Methods(http.MethodGet)
+	api.HandleFunc("/audit", h.AuditLog).Methods(http.MethodGet)
+	api.HandleFunc("/webhooks/deliveries", wh.Deliveries).Methods(http.MethodGet)
+	api.HandleFunc("/webhooks/deliveries/{id}/redeliver", wh.Redeliver).Methods(http.MethodPost)
+	api.HandleFunc("/webhooks/{id}", wh.Delete).Methods(http.MethodDelete)
+	api.HandleFunc("/webhooks", wh.Create).Methods(http.MethodPost)
+	api.HandleFunc("/webhooks", wh.GetAll).Methods(http.MethodGet)
 	return router
 }
@@ -0,0 +1,55 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/EdmundHusserl/CRM/internal/assignment"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/handlers"
+	"github.com/EdmundHusserl/CRM/internal/metrics"
+	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/EdmundHusserl/CRM/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMetricsCoverEveryRoute(t *testing.T) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	repo := providers.NewInMemoryCustomerRepository(nil)
+	dispatcher := webhooks.NewDispatcher(l, providers.NewWebhookRepository(repo))
+	router := NewRouter(
+		handlers.NewCustomerHandler(l, repo, assignment.NewStrategy(l, ""), events.NewBus(16)),
+		handlers.NewWebhookHandler(l, dispatcher.Store, dispatcher),
+		handlers.NewHealthHandler(l, repo, "in-memory"),
+	)
+	router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+
+	for _, path := range []string{"/healthz", "/version", "/api/customers/" + uuid.NewString(), "/metrics"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	tests := []struct {
+		route  string
+		status string
+	}{
+		{"/healthz", "200"},
+		{"/version", "200"},
+		{"/api/customers/{id}", "404"},
+		{"/metrics", "200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			want := `crm_http_requests_total{method="GET",route="` + tt.route + `",status="` + tt.status + `"} 1`
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("metrics lack %s", want)
+			}
+		})
+	}
+}
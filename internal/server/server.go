@@ -1,46 +1,274 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/EdmundHusserl/CRM/internal/admin"
+	"github.com/EdmundHusserl/CRM/internal/assignment"
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/certs"
+	"github.com/EdmundHusserl/CRM/internal/config"
+	"github.com/EdmundHusserl/CRM/internal/consistency"
+	"github.com/EdmundHusserl/CRM/internal/events"
 	"github.com/EdmundHusserl/CRM/internal/handlers"
+	"github.com/EdmundHusserl/CRM/internal/idempotency"
+	"github.com/EdmundHusserl/CRM/internal/logging"
+	"github.com/EdmundHusserl/CRM/internal/metrics"
+	"github.com/EdmundHusserl/CRM/internal/outbox"
+	"github.com/EdmundHusserl/CRM/internal/ratelimit"
 	"github.com/EdmundHusserl/CRM/internal/repository"
 	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/EdmundHusserl/CRM/internal/requestid"
+	"github.com/EdmundHusserl/CRM/internal/retention"
 	"github.com/EdmundHusserl/CRM/internal/router"
+	"github.com/EdmundHusserl/CRM/internal/tenant"
+	"github.com/EdmundHusserl/CRM/internal/tracing"
+	"github.com/EdmundHusserl/CRM/internal/webhooks"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+)
+
+const (
+	// How often the trash is checked for customers past their retention
+	purgeInterval = time.Hour
+	// How often expired idempotency keys are removed
+	idempotencyPurgeInterval = 10 * time.Minute
+	// Number of events stream clients can catch up on after reconnecting
+	eventReplaySize = 1024
 )
 
 type Server struct {
 	Addr   string
+	HTTP   *http.Server
+	Events *events.Bus
 	DB     repository.CustomerRepository
 	Logger *logrus.Logger
 	Router *mux.Router
+	// Admin serves the diagnostics routes, nil when disabled
+	Admin       *http.Server
+	Maintenance *admin.Maintenance
+	// Certs serves HTTPS, nil for plain HTTP
+	Certs  *certs.Reloader
+	Purger *retention.Purger
+	// Idempotency replays the responses to retried requests
+	Idempotency *idempotency.Keys
+	Dispatcher  *webhooks.Dispatcher
+	// Relay is nil when the repository publishes its events directly
+	Relay *outbox.Relay
+	// Changes is nil when the repository cannot be changed directly
+	Changes *providers.ChangeListener
+	// Replicas is nil when the repository has no read replicas
+	Replicas *providers.ReplicaSet
+	// ShutdownTracing flushes the spans not exported yet
+	ShutdownTracing func(context.Context) error
+	ShutdownTimeout time.Duration
 }
 
-func NewServer(repositoryProvider string, port int) Server {
+func NewServer(cfg config.Config) Server {
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
 	logger.SetOutput(os.Stdout)
 	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(tracing.LogHook{})
+	logger.AddHook(logging.Redactor{Policy: cfg.Logging.Redaction})
+	shutdownTracing := tracing.Setup(context.Background(), logger)
+
+	bus := events.NewBus(eventReplaySize)
+	repo := providers.NewRepository(logger, cfg.Database, bus, cfg.Cache)
+	if c, ok := repo.(*providers.CachingRepository); ok {
+		// Evicts the customers changed directly in the database
+		bus.Handle(c)
+		metrics.Register(logger, c)
+	}
+	if db := providers.ConnectionPool(repo); db != nil {
+		metrics.Register(logger, collectors.NewDBStatsCollector(db, "customers"))
+	}
+	metrics.Register(logger, metrics.NewCustomerCollector(repo))
+	dispatcher := webhooks.NewDispatcher(logger, providers.NewWebhookRepository(repo))
+	bus.Handle(dispatcher)
 
-	repo := providers.NewRepository(logger, repositoryProvider)
-	handler := handlers.NewCustomerHandler(logger, repo)
-	router := router.NewRouter(handler)
+	var relay *outbox.Relay
+	sinks := outbox.NewSinks(logger, cfg.Outbox.Sinks)
+	if store := providers.NewOutboxRepository(repo); store != nil {
+		relay = outbox.NewRelay(logger, store, append([]outbox.Sink{outbox.BusSink{Bus: bus}}, sinks...)...)
+	} else {
+		for _, sink := range sinks {
+			bus.Handle(outbox.Publisher{Logger: logger, Sink: sink})
+		}
+	}
+
+	changes := providers.NewChangeListener(logger, repo, bus)
+
+	handler := handlers.NewCustomerHandler(logger, repo, assignment.NewStrategy(logger, cfg.Assignment.RulesFile), bus)
+	webhookHandler := handlers.NewWebhookHandler(logger, dispatcher.Store, dispatcher)
+	healthHandler := handlers.NewHealthHandler(logger, repo, providers.ProviderName(repo))
+	resolver := auth.NewResolver(logger, cfg.Auth.Mode, cfg.Auth.UsersFile)
+	var reloader *certs.Reloader
+	if cfg.TLS.CertFile != "" {
+		var err error
+		if reloader, err = certs.NewReloader(logger, cfg.TLS); err != nil {
+			logger.WithField("error", err.Error()).Fatal("error loading TLS certificates")
+		}
+		if cfg.TLS.ClientAuth != "none" {
+			resolver = auth.NewCertificateResolver(logger, cfg.TLS.ClientsFile, resolver)
+		}
+	}
+	tenants := tenant.NewResolver(logger, cfg.Tenancy.Mode, cfg.Tenancy.Domain)
+	limiter := ratelimit.NewLimiter(logger, cfg.RateLimit, providers.NewQuotaRepository(repo))
+	keys := idempotency.NewKeys(logger, providers.NewIdempotencyRepository(repo), cfg.Idempotency.TTL, idempotencyPurgeInterval)
+	maintenance := &admin.Maintenance{}
+	router := router.NewRouter(handler, webhookHandler, healthHandler, otelmux.Middleware(tracing.ServiceName), requestid.Middleware, logging.Middleware(logger), maintenance.Middleware, consistency.Middleware, auth.Middleware(logger, resolver), tenant.Middleware(logger, tenants), limiter.Middleware, keys.Middleware)
+
+	addr := fmt.Sprintf(":%v", cfg.Server.Port)
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		ErrorLog:          log.New(logger.WriterLevel(logrus.WarnLevel), "", 0),
+	}
+	if reloader != nil {
+		httpServer.TLSConfig = reloader.TLSConfig()
+	}
+	// Shutdown waits for streams to end
+	httpServer.RegisterOnShutdown(bus.CloseSubscriptions)
+
+	var adminServer *http.Server
+	if cfg.Admin.Addr != "" {
+		adminServer = &http.Server{
+			Addr:              cfg.Admin.Addr,
+			Handler:           admin.NewRouter(logger, providers.ConnectionPool(repo), maintenance),
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+			ErrorLog:          log.New(logger.WriterLevel(logrus.WarnLevel), "", 0),
+		}
+	} else {
+		// Without an admin listener metrics stay on the public port
+		router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+	}
 
 	return Server{
-		Addr:   fmt.Sprintf(":%v", port),
-		DB:     repo,
-		Logger: logger,
-		Router: router,
+		Addr:        addr,
+		HTTP:        httpServer,
+		Events:      bus,
+		DB:          repo,
+		Logger:      logger,
+		Router:      router,
+		Admin:       adminServer,
+		Maintenance: maintenance,
+		Certs:       reloader,
+		Purger:      retention.NewPurger(logger, repo, cfg.Trash.Retention, purgeInterval),
+		Idempotency: keys,
+		Dispatcher:  dispatcher,
+		Relay:       relay,
+		Changes:     changes,
+		Replicas:    providers.Replicas(repo),
+
+		ShutdownTracing: shutdownTracing,
+		ShutdownTimeout: cfg.Server.ShutdownTimeout,
 	}
 }
 
-func (s *Server) Listen() error {
+// Listen serves requests and runs the background workers until ctx is
+// done or the server fails, then shuts down within ShutdownTimeout: new
+// connections are refused, in-flight requests drained, the workers
+// complete their current batch, queued events are handled, pending spans
+// are flushed and the repository is closed.
+func (s *Server) Listen(ctx context.Context) error {
 	s.Logger.WithField(
 		"event", fmt.Sprintf("Listening of port %v", s.Addr[1:]),
 	).Info("Start server")
-	return http.ListenAndServe(s.Addr, s.Router)
+
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var wg sync.WaitGroup
+	run := func(worker func(context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker(workers)
+		}()
+	}
+	run(s.Purger.Run)
+	run(s.Idempotency.Run)
+	run(s.Dispatcher.Run)
+	if s.Relay != nil {
+		run(s.Relay.Run)
+	}
+	if s.Changes != nil {
+		run(s.Changes.Run)
+	}
+	if s.Replicas != nil {
+		run(s.Replicas.Run)
+	}
+	if s.Certs != nil {
+		run(s.Certs.Run)
+	}
+
+	served := make(chan error, 2)
+	go func() {
+		if s.Certs != nil {
+			served <- s.HTTP.ListenAndServeTLS("", "")
+		} else {
+			served <- s.HTTP.ListenAndServe()
+		}
+	}()
+	if s.Admin != nil {
+		s.Logger.WithField("event", fmt.Sprintf("Listening on %v", s.Admin.Addr)).Info("Start admin server")
+		go func() { served <- s.Admin.ListenAndServe() }()
+	}
+
+	var err error
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+	}
+	s.Logger.WithField("event", fmt.Sprintf("draining for up to %v", s.ShutdownTimeout)).Info("Shutting down")
+
+	shutdown, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	if err := s.HTTP.Shutdown(shutdown); err != nil {
+		s.Logger.WithField("error", err.Error()).Warn("In-flight requests interrupted")
+	}
+	if s.Admin != nil {
+		// Profiles being recorded are cut short
+		s.Admin.Close()
+	}
+
+	stopWorkers()
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdown.Done():
+		s.Logger.WithField("error", shutdown.Err().Error()).Warn("Background workers interrupted")
+	}
+
+	// The events of the last requests and batches reach their handlers
+	// before the repository is closed
+	if err := s.Events.Close(shutdown); err != nil {
+		s.Logger.WithField("error", err.Error()).Warn("Event handlers interrupted")
+	}
+	if err := s.ShutdownTracing(shutdown); err != nil {
+		s.Logger.WithField("error", err.Error()).Warn("Failed to flush spans")
+	}
+	if err := s.DB.CloseDBConnection(); err != nil {
+		s.Logger.WithField("error", err.Error()).Warn("Failed to close the repository")
+	}
+	s.Logger.WithField("event", "repository closed").Info("Server stopped")
+	return err
 }
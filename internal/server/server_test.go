@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/config"
+)
+
+func TestListenShutsDown(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.Port = 0
+	cfg.Admin.Addr = "127.0.0.1:0"
+	cfg.Server.ShutdownTimeout = 5 * time.Second
+	s := NewServer(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Listen(ctx) }()
+
+	sub, _ := s.Events.Subscribe(0)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Listen() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen() did not return after its context was done")
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("Change stream subscriptions left open after shutdown")
+	}
+}
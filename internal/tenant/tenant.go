@@ -0,0 +1,145 @@
+// Package tenant resolves the tenant of every request, scoping the
+// repository calls it makes with repository.WithTenant.
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/logging"
+	"github.com/EdmundHusserl/CRM/internal/problem"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ModeNone      string = "none"
+	ModeHeader    string = "header"
+	ModeSubdomain string = "subdomain"
+	ModeClaim     string = "claim"
+
+	Header string = "X-Tenant-ID"
+)
+
+var (
+	ErrNoTenant = errors.New("no tenant")
+	// ErrForbidden rejects users acting for another tenant than theirs
+	ErrForbidden = errors.New("forbidden tenant")
+)
+
+// Resolver identifies the tenant an incoming request acts for.
+type Resolver interface {
+	Resolve(r *http.Request) (repository.TenantID, error)
+}
+
+// DefaultResolver puts every request in repository.DefaultTenant, as
+// single-tenant deployments do.
+type DefaultResolver struct{}
+
+func (DefaultResolver) Resolve(r *http.Request) (repository.TenantID, error) {
+	return repository.DefaultTenant, nil
+}
+
+// HeaderResolver trusts the Header set by a routing proxy.
+type HeaderResolver struct{}
+
+func (HeaderResolver) Resolve(r *http.Request) (repository.TenantID, error) {
+	v := r.Header.Get(Header)
+	if v == "" {
+		return "", fmt.Errorf("%w: missing %s header", ErrNoTenant, Header)
+	}
+	id, err := repository.ParseTenantID(v)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNoTenant, err.Error())
+	}
+	return id, nil
+}
+
+// SubdomainResolver reads the tenant from the first label of hosts under
+// Domain, acme.crm.example.com being the host of acme under crm.example.com.
+type SubdomainResolver struct {
+	Domain string
+}
+
+func (s SubdomainResolver) Resolve(r *http.Request) (repository.TenantID, error) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	label, ok := strings.CutSuffix(host, "."+strings.ToLower(s.Domain))
+	if !ok || label == "" || strings.Contains(label, ".") {
+		return "", fmt.Errorf("%w: host %q is not a subdomain of %s", ErrNoTenant, r.Host, s.Domain)
+	}
+	id, err := repository.ParseTenantID(label)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNoTenant, err.Error())
+	}
+	return id, nil
+}
+
+// ClaimResolver puts requests in the tenant of their user, as given by
+// its token. It runs after auth.Middleware.
+type ClaimResolver struct{}
+
+func (ClaimResolver) Resolve(r *http.Request) (repository.TenantID, error) {
+	u, _ := auth.UserFromContext(r.Context())
+	if u.Tenant == "" {
+		return "", fmt.Errorf("%w: user belongs to no tenant", ErrForbidden)
+	}
+	return u.Tenant, nil
+}
+
+// Returns a Resolver for the given mode. Subdomain mode reads tenants
+// under domain.
+func NewResolver(l *logrus.Logger, mode, domain string) Resolver {
+	switch strings.ToLower(mode) {
+	case ModeHeader:
+		return HeaderResolver{}
+	case ModeSubdomain:
+		return SubdomainResolver{Domain: domain}
+	case ModeClaim:
+		return ClaimResolver{}
+	default:
+		if mode != ModeNone {
+			l.WithField(
+				"event", fmt.Sprintf("defaulting to %s", ModeNone),
+			).Info("Unknown tenancy mode")
+		}
+		return DefaultResolver{}
+	}
+}
+
+// Middleware scopes every request to the tenant resolved for it. Requests
+// without a valid tenant are rejected with 400, and those of users bound
+// to another tenant with 403. It runs after auth.Middleware.
+func Middleware(l *logrus.Logger, resolver Resolver) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := resolver.Resolve(r)
+			if u, _ := auth.UserFromContext(r.Context()); err == nil && u.Tenant != "" && u.Tenant != id {
+				err = fmt.Errorf("%w: user belongs to tenant %s", ErrForbidden, u.Tenant)
+			}
+			if err != nil {
+				e := problem.New(http.StatusBadRequest, problem.InvalidTenant, err.Error())
+				if errors.Is(err, ErrForbidden) {
+					e = problem.New(http.StatusForbidden, problem.Forbidden, err.Error())
+				}
+				e.Write(w, r)
+
+				logging.FromContext(r.Context(), l).WithFields(logrus.Fields{
+					"error_message": err.Error(),
+					"status":        e.Status,
+				}).Info("Tenant resolution failure")
+				return
+			}
+			logging.AddFields(r.Context(), logrus.Fields{"tenant": id})
+			next.ServeHTTP(w, r.WithContext(repository.WithTenant(r.Context(), id)))
+		})
+	}
+}
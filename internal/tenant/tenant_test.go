@@ -0,0 +1,182 @@
+package tenant
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/EdmundHusserl/CRM/internal/assignment"
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/handlers"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/EdmundHusserl/CRM/internal/router"
+	"github.com/EdmundHusserl/CRM/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+func TestResolvers(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolver Resolver
+		host     string
+		header   string
+		user     auth.User
+		want     repository.TenantID
+		wantErr  bool
+	}{
+		{"default", DefaultResolver{}, "crm.example.com", "", auth.User{}, repository.DefaultTenant, false},
+		{"header", HeaderResolver{}, "crm.example.com", "acme", auth.User{}, "acme", false},
+		{"missing header", HeaderResolver{}, "crm.example.com", "", auth.User{}, "", true},
+		{"invalid header", HeaderResolver{}, "crm.example.com", "Acme Corp", auth.User{}, "", true},
+		{"subdomain", SubdomainResolver{Domain: "crm.example.com"}, "acme.crm.example.com:3000", "", auth.User{}, "acme", false},
+		{"parent domain", SubdomainResolver{Domain: "crm.example.com"}, "crm.example.com", "", auth.User{}, "", true},
+		{"nested subdomain", SubdomainResolver{Domain: "crm.example.com"}, "eu.acme.crm.example.com", "", auth.User{}, "", true},
+		{"other domain", SubdomainResolver{Domain: "crm.example.com"}, "acme.example.org", "", auth.User{}, "", true},
+		{"claim", ClaimResolver{}, "crm.example.com", "globex", auth.User{Tenant: "acme"}, "acme", false},
+		{"no claim", ClaimResolver{}, "crm.example.com", "", auth.User{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/customers", nil)
+			r.Host = tt.host
+			if tt.header != "" {
+				r.Header.Set(Header, tt.header)
+			}
+			r = r.WithContext(auth.WithUser(r.Context(), tt.user))
+
+			got, err := tt.resolver.Resolve(r)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Resolve() = %q, %v, want %q, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		user       auth.User
+		wantStatus int
+		want       repository.TenantID
+	}{
+		{"resolved", "acme", auth.User{}, http.StatusOK, "acme"},
+		{"unresolved", "", auth.User{}, http.StatusBadRequest, ""},
+		{"user of the tenant", "acme", auth.User{Tenant: "acme"}, http.StatusOK, "acme"},
+		{"user of another tenant", "globex", auth.User{Tenant: "acme"}, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got repository.TenantID
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = repository.TenantFromContext(r.Context())
+			})
+			l := logrus.New()
+			l.SetOutput(io.Discard)
+
+			r := httptest.NewRequest(http.MethodGet, "/api/customers", nil)
+			if tt.header != "" {
+				r.Header.Set(Header, tt.header)
+			}
+			r = r.WithContext(auth.WithUser(r.Context(), tt.user))
+			rec := httptest.NewRecorder()
+			Middleware(l, HeaderResolver{})(next).ServeHTTP(rec, r)
+
+			if rec.Code != tt.wantStatus || got != tt.want {
+				t.Errorf("Middleware() = %d for %q, want %d for %q", rec.Code, got, tt.wantStatus, tt.want)
+			}
+		})
+	}
+}
+
+// Serves the real routes over an in-memory repository, tenants being
+// resolved from their header
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	repo := providers.NewInMemoryCustomerRepository(nil)
+	bus := events.NewBus(16)
+	repo.Events = bus
+	dispatcher := webhooks.NewDispatcher(l, providers.NewInMemoryWebhookRepository())
+	return router.NewRouter(
+		handlers.NewCustomerHandler(l, repo, assignment.NewStrategy(l, ""), bus),
+		handlers.NewWebhookHandler(l, dispatcher.Store, dispatcher),
+		handlers.NewHealthHandler(l, repo, "in-memory"),
+		auth.Middleware(l, auth.AnonymousResolver{}),
+		Middleware(l, HeaderResolver{}),
+	)
+}
+
+// Sends a request of tenant to h
+func serve(h http.Handler, tenant repository.TenantID, method, target string, body any) *httptest.ResponseRecorder {
+	var b bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&b).Encode(body)
+	}
+	r := httptest.NewRequest(method, target, &b)
+	r.Header.Set(Header, string(tenant))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestCrossTenantRequests(t *testing.T) {
+	h := newTestRouter(t)
+
+	rec := serve(h, "acme", http.MethodPost, "/api/customers", repository.Customer{Name: "Jorge", Email: "jorge@corp.com", PhoneNumber: "514 888 8888", TenantID: "globex"})
+	var created handlers.CustomerCreatedResponse
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Create() = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	var c repository.Customer
+	json.NewDecoder(serve(h, "acme", http.MethodGet, "/api/customers/"+created.ID.String(), nil).Body).Decode(&c)
+	if c.TenantID != "acme" {
+		t.Fatalf("Get() = tenant %q, want acme", c.TenantID)
+	}
+	// The same email may be used by another tenant
+	if rec := serve(h, "globex", http.MethodPost, "/api/customers", repository.Customer{Name: "Jorge", Email: "jorge@corp.com", PhoneNumber: "514 888 8888"}); rec.Code != http.StatusCreated {
+		t.Errorf("Create() = %d for an email of another tenant, want %d", rec.Code, http.StatusCreated)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       any
+		wantStatus int
+	}{
+		{"get", http.MethodGet, "/api/customers/" + c.ID.String(), nil, http.StatusNotFound},
+		{"history", http.MethodGet, "/api/customers/" + c.ID.String() + "/history", nil, http.StatusNotFound},
+		{"assign", http.MethodPost, "/api/customers/" + c.ID.String() + "/assign", map[string]uuid.UUID{"owner_id": uuid.New()}, http.StatusNotFound},
+		{"update", http.MethodPatch, "/api/customers", repository.Customer{ID: c.ID, Name: "Stolen", Email: "stolen@corp.com", PhoneNumber: "+1 555 0100"}, http.StatusNotFound},
+		{"delete", http.MethodDelete, "/api/customers/" + c.ID.String(), nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(h, "globex", tt.method, tt.target, tt.body); rec.Code != tt.wantStatus {
+				t.Errorf("%s %s = %d from another tenant, want %d", tt.method, tt.target, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	var all []repository.Customer
+	json.NewDecoder(serve(h, "globex", http.MethodGet, "/api/customers", nil).Body).Decode(&all)
+	for _, got := range all {
+		if got.ID == c.ID {
+			t.Errorf("GetAll() listed a customer of another tenant")
+		}
+	}
+	rec = serve(h, "acme", http.MethodGet, "/api/customers/"+c.ID.String(), nil)
+	json.NewDecoder(rec.Body).Decode(&c)
+	if rec.Code != http.StatusOK || c.Name != "Jorge" {
+		t.Errorf("Get() = %d %q, want the untouched customer", rec.Code, c.Name)
+	}
+}
@@ -0,0 +1,117 @@
+// Package tracing sets up OpenTelemetry tracing for the server.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Name of the service in the exported spans unless OTEL_SERVICE_NAME is set
+	ServiceName string = "crm"
+	tracerName  string = "github.com/EdmundHusserl/CRM"
+)
+
+// Tracer returns the tracer the server records its spans with.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start opens a span named after a stage of the current request.
+func Start(ctx context.Context, stage string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, stage, trace.WithAttributes(attrs...))
+}
+
+// End closes span, recording err if it failed.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// NewExporter returns the span exporter called name: "otlp" sends spans
+// to the collector set by the OTEL_EXPORTER_OTLP_* variables, "stdout"
+// writes them to w. "none" and "" return a nil exporter.
+func NewExporter(ctx context.Context, name string, w io.Writer) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "otlp":
+		return otlptracehttp.New(ctx)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(w))
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q", name)
+	}
+}
+
+// NewProvider returns a tracer provider batching spans to exp.
+func NewProvider(ctx context.Context, exp sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", ServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res)), nil
+}
+
+// Setup installs the W3C trace context propagator and, when
+// OTEL_TRACES_EXPORTER selects an exporter, a global tracer provider.
+// The returned function flushes the pending spans.
+func Setup(ctx context.Context, l *logrus.Logger) func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	name := os.Getenv("OTEL_TRACES_EXPORTER")
+	exp, err := NewExporter(ctx, name, os.Stdout)
+	if err != nil {
+		l.WithField("error", err.Error()).Fatal("error creating traces exporter")
+	}
+	if exp == nil {
+		return func(context.Context) error { return nil }
+	}
+	provider, err := NewProvider(ctx, exp)
+	if err != nil {
+		l.WithField("error", err.Error()).Fatal("error creating tracer provider")
+	}
+	otel.SetTracerProvider(provider)
+	l.WithField("event", fmt.Sprintf("exporting spans to %s", name)).Info("Tracing enabled")
+	return provider.Shutdown
+}
+
+// LogHook adds the trace and span IDs of the context of log entries to
+// their fields.
+type LogHook struct{}
+
+func (LogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (LogHook) Fire(e *logrus.Entry) error {
+	if e.Context == nil {
+		return nil
+	}
+	sc := trace.SpanContextFromContext(e.Context)
+	if !sc.IsValid() {
+		return nil
+	}
+	e.Data["trace_id"] = sc.TraceID().String()
+	e.Data["span_id"] = sc.SpanID().String()
+	return nil
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewExporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "", want: false},
+		{name: "none", want: false},
+		{name: "stdout", want: true},
+		{name: "zipkin", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run("Exporter_"+tt.name, func(t *testing.T) {
+			exp, err := NewExporter(context.Background(), tt.name, &bytes.Buffer{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewExporter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (exp != nil) != tt.want {
+				t.Errorf("NewExporter() = %v, want an exporter: %v", exp, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropagationAndLogs(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a50ef35b09d1a2f5"
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(LogHook{})
+
+	router := mux.NewRouter()
+	router.Use(otelmux.Middleware(ServiceName,
+		otelmux.WithTracerProvider(provider),
+		otelmux.WithPropagators(propagation.TraceContext{}),
+	))
+	router.HandleFunc("/customers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := provider.Tracer(tracerName).Start(r.Context(), "decode")
+		End(span, nil)
+		logger.WithContext(r.Context()).Info("Handled")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/customers/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	for _, s := range spans {
+		if got := s.SpanContext().TraceID().String(); got != traceID {
+			t.Errorf("span %q trace ID = %s, want %s", s.Name(), got, traceID)
+		}
+	}
+	if spans[1].Name() != "/customers/{id}" {
+		t.Errorf("route span name = %q, want the route template", spans[1].Name())
+	}
+	if !strings.Contains(logs.String(), `"trace_id":"`+traceID+`"`) {
+		t.Errorf("log %s does not carry the trace ID", logs.String())
+	}
+}
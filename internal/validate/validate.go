@@ -0,0 +1,129 @@
+// Package validate checks structs against the rules of their validate tags,
+// such as `validate:"required,max=255"`, reporting every invalid field
+// rather than the first one.
+//
+// The rules are:
+//   - required: not the zero value
+//   - max=n: at most n characters for strings, at most n for numbers
+//   - oneof=a b c: one of the listed values
+//   - email: a bare address such as jorge@corp.com
+//   - phone: digits, spaces, dashes and parentheses, optionally after a +
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is a field breaking a rule.
+type FieldError struct {
+	// Pointer is the JSON pointer of the field, such as /email
+	Pointer string
+	Rule    string
+	Reason  string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pointer, e.Reason)
+}
+
+// Errors lists every invalid field of a struct.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, ", ")
+}
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9\s\-\(\)]+$`)
+
+// A rule returns why v breaks it given its parameter, or ""
+type rule func(v reflect.Value, param string) string
+
+var rules = map[string]rule{
+	"required": func(v reflect.Value, _ string) string {
+		if v.IsZero() {
+			return "is required"
+		}
+		return ""
+	},
+	"max": func(v reflect.Value, param string) string {
+		n, _ := strconv.Atoi(param)
+		switch v.Kind() {
+		case reflect.String:
+			if utf8.RuneCountInString(v.String()) > n {
+				return fmt.Sprintf("must be at most %d characters long", n)
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.Int() > int64(n) {
+				return fmt.Sprintf("must be at most %d", n)
+			}
+		}
+		return ""
+	},
+	"oneof": func(v reflect.Value, param string) string {
+		allowed := strings.Fields(param)
+		if !slices.Contains(allowed, fmt.Sprint(v.Interface())) {
+			return fmt.Sprintf("must be one of %s", strings.Join(allowed, ", "))
+		}
+		return ""
+	},
+	"email": func(v reflect.Value, _ string) string {
+		if a, err := mail.ParseAddress(v.String()); err != nil || a.Address != v.String() {
+			return fmt.Sprintf("%q is not an e-mail address", v.String())
+		}
+		return ""
+	},
+	"phone": func(v reflect.Value, _ string) string {
+		if !phonePattern.MatchString(v.String()) {
+			return fmt.Sprintf("%q is not a phone number", v.String())
+		}
+		return ""
+	},
+}
+
+// Struct checks the fields of v, a struct or a pointer to one, and returns
+// the Errors of those breaking their rules, or nil. Fields are named
+// after their json tag.
+func Struct(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rt := rv.Type()
+
+	var errs Errors
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		tag := f.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		for _, r := range strings.Split(tag, ",") {
+			ruleName, param, _ := strings.Cut(r, "=")
+			check, ok := rules[ruleName]
+			if !ok {
+				panic(fmt.Sprintf("unknown validation rule %q of %s.%s", ruleName, rt.Name(), f.Name))
+			}
+			if reason := check(rv.Field(i), param); reason != "" {
+				errs = append(errs, FieldError{Pointer: "/" + name, Rule: ruleName, Reason: reason})
+				// The next rules of the field would repeat the reason
+				break
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,69 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type contact struct {
+	Name  string `json:"name" validate:"required,max=5"`
+	Kind  int    `json:"kind" validate:"oneof=0 1"`
+	Email string `json:"email,omitempty" validate:"required,email"`
+	Phone string `validate:"phone"`
+	Notes string `json:"notes"`
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want Errors
+	}{
+		{"valid", contact{Name: "Jorge", Email: "jorge@corp.com", Phone: "+1 (555) 010-0100"}, nil},
+		{"pointer", &contact{Name: "Jorge", Email: "jorge@corp.com", Phone: "555"}, nil},
+		{
+			"every field invalid",
+			contact{Name: "Jorge Luis", Kind: 2, Email: "Jorge <jorge@corp.com>", Phone: "call me"},
+			Errors{
+				{Pointer: "/name", Rule: "max", Reason: "must be at most 5 characters long"},
+				{Pointer: "/kind", Rule: "oneof", Reason: "must be one of 0, 1"},
+				{Pointer: "/email", Rule: "email", Reason: `"Jorge <jorge@corp.com>" is not an e-mail address`},
+				{Pointer: "/Phone", Rule: "phone", Reason: `"call me" is not a phone number`},
+			},
+		},
+		{
+			"first failing rule only",
+			contact{Phone: "555"},
+			Errors{
+				{Pointer: "/name", Rule: "required", Reason: "is required"},
+				{Pointer: "/email", Rule: "required", Reason: "is required"},
+			},
+		},
+		{"multibyte characters", contact{Name: "Jörgé", Email: "jorge@corp.com", Phone: "555"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(tt.v)
+			var got Errors
+			if err != nil && !errors.As(err, &got) {
+				t.Fatalf("Struct() = %v, want Errors", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Struct() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownRule(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), `"min"`) {
+			t.Errorf("Struct() panicked with %v, want the unknown rule", r)
+		}
+	}()
+	Struct(struct {
+		Name string `validate:"min=1"`
+	}{})
+}
@@ -0,0 +1,46 @@
+// Package version describes the build of the running binary.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at link time, e.g.
+//
+//	go build -ldflags "-X github.com/EdmundHusserl/CRM/internal/version.GitSHA=$(git rev-parse HEAD)"
+//
+// Both default to the VCS information stamped by the go command.
+var (
+	GitSHA    string
+	BuildTime string
+)
+
+type Info struct {
+	GitSHA    string `json:"git_sha"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information of the running binary, "unknown" for
+// what could not be found.
+func Get() Info {
+	info := Info{GitSHA: GitSHA, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range build.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.GitSHA == "":
+				info.GitSHA = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	if info.GitSHA == "" {
+		info.GitSHA = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
@@ -0,0 +1,271 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Subscribing to AllEvents delivers every event type.
+const AllEvents string = "*"
+
+var ErrNotRedeliverable = errors.New("delivery is still pending")
+
+// Dispatcher queues a delivery per subscription for every published
+// event and POSTs them, retrying failures with exponential backoff until
+// MaxAttempts is reached. Deliveries exhausting their attempts are marked
+// dead until redelivered.
+type Dispatcher struct {
+	Logger       *logrus.Logger
+	Store        repository.WebhookRepository
+	Client       *http.Client
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+	wake         chan struct{}
+}
+
+func NewDispatcher(l *logrus.Logger, store repository.WebhookRepository) *Dispatcher {
+	return &Dispatcher{
+		Logger:       l,
+		Store:        store,
+		Client:       &http.Client{Timeout: 10 * time.Second, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		MaxAttempts:  8,
+		BaseBackoff:  time.Second,
+		MaxBackoff:   time.Hour,
+		PollInterval: time.Second,
+		BatchSize:    50,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Publish queues e for every subscription of its tenant interested in its
+// type.
+func (d *Dispatcher) Publish(ctx context.Context, e events.Event) {
+	ctx = repository.WithTenant(ctx, e.TenantID)
+	subscriptions, err := d.Store.ListSubscriptions(ctx)
+	if err != nil {
+		d.Logger.WithField("error", err.Error()).Warn("Failed to list webhook subscriptions")
+		return
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		d.Logger.WithField("error", err.Error()).Warn("Failed to encode webhook payload")
+		return
+	}
+
+	now := time.Now().UTC()
+	var deliveries []repository.WebhookDelivery
+	for _, s := range subscriptions {
+		if !slices.Contains(s.Events, string(e.Type)) && !slices.Contains(s.Events, AllEvents) {
+			continue
+		}
+		deliveries = append(deliveries, repository.WebhookDelivery{
+			ID:             uuid.New(),
+			TenantID:       s.TenantID,
+			SubscriptionID: s.ID,
+			EventID:        e.ID,
+			EventType:      string(e.Type),
+			Payload:        payload,
+			Status:         repository.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	if err := d.Store.EnqueueDeliveries(ctx, deliveries); err != nil {
+		d.Logger.WithFields(logrus.Fields{
+			"event": fmt.Sprintf("%s %v", e.Type, e.ID),
+			"error": err.Error(),
+		}).Warn("Failed to queue webhook deliveries")
+		return
+	}
+	d.notify()
+}
+
+// Redeliver schedules a delivered or dead delivery to be sent again with a
+// fresh set of attempts.
+func (d *Dispatcher) Redeliver(ctx context.Context, id uuid.UUID) (*repository.WebhookDelivery, error) {
+	delivery, err := d.Store.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status == repository.DeliveryPending {
+		return nil, ErrNotRedeliverable
+	}
+	delivery.Status = repository.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now().UTC()
+	delivery.LastError = ""
+	delivery.DeliveredAt = nil
+	if err := d.Store.UpdateDelivery(ctx, *delivery); err != nil {
+		return nil, err
+	}
+	d.notify()
+	return delivery, nil
+}
+
+// Run sends due deliveries until ctx is done. The batch being sent when
+// ctx is done is completed.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	work := context.WithoutCancel(ctx)
+	for {
+		for ctx.Err() == nil && d.DeliverDue(work) == d.BatchSize {
+			// Drain the backlog before waiting again
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DeliverDue sends one batch of due deliveries and returns its size.
+func (d *Dispatcher) DeliverDue(ctx context.Context) int {
+	claimed, err := d.Store.ClaimDeliveries(ctx, time.Now().UTC(), 2*d.Client.Timeout, d.BatchSize)
+	if err != nil {
+		d.Logger.WithField("error", err.Error()).Warn("Failed to claim webhook deliveries")
+		return 0
+	}
+	if len(claimed) == 0 {
+		return 0
+	}
+
+	// Deliveries of a batch may belong to any tenant, the subscriptions of
+	// each are listed once, under that tenant
+	subscriptions := map[repository.TenantID]map[uuid.UUID]repository.WebhookSubscription{}
+	for _, delivery := range claimed {
+		tctx := repository.WithTenant(ctx, delivery.TenantID)
+		byID, listed := subscriptions[delivery.TenantID]
+		if !listed {
+			list, err := d.Store.ListSubscriptions(tctx)
+			if err != nil {
+				d.Logger.WithFields(logrus.Fields{
+					"tenant": delivery.TenantID,
+					"error":  err.Error(),
+				}).Warn("Failed to list webhook subscriptions")
+			} else {
+				byID = make(map[uuid.UUID]repository.WebhookSubscription, len(list))
+				for _, s := range list {
+					byID[s.ID] = s
+				}
+			}
+			subscriptions[delivery.TenantID] = byID
+		}
+		if byID == nil {
+			// Sent again once its lease expires
+			continue
+		}
+
+		s, ok := byID[delivery.SubscriptionID]
+		if !ok {
+			delivery.Attempts = d.MaxAttempts
+			d.record(tctx, delivery, errors.New("subscription deleted"))
+			continue
+		}
+		delivery.Attempts++
+		d.record(tctx, delivery, d.send(tctx, s, delivery))
+	}
+	return len(claimed)
+}
+
+// Sends a single delivery, any non 2xx response is an error
+func (d *Dispatcher) send(ctx context.Context, s repository.WebhookSubscription, delivery repository.WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "CRM-Webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(s.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Stores the outcome of an attempt and schedules the next one if needed
+func (d *Dispatcher) record(ctx context.Context, delivery repository.WebhookDelivery, err error) {
+	now := time.Now().UTC()
+	switch {
+	case err == nil:
+		delivery.Status = repository.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = repository.DeliveryDead
+		delivery.LastError = err.Error()
+	default:
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+	}
+
+	fields := logrus.Fields{
+		"event":    fmt.Sprintf("delivery %v of %s %v", delivery.ID, delivery.EventType, delivery.EventID),
+		"attempts": delivery.Attempts,
+		"status":   delivery.Status,
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		d.Logger.WithFields(fields).Warn("Webhook delivery failure")
+	} else {
+		d.Logger.WithFields(fields).Info("Webhook delivered")
+	}
+
+	if err := d.Store.UpdateDelivery(ctx, delivery); err != nil {
+		d.Logger.WithField("error", err.Error()).Warn("Failed to store webhook delivery")
+	}
+}
+
+// Delay before the attempt following the given one: BaseBackoff doubled
+// per attempt, capped to MaxBackoff, with up to 10% of jitter
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.MaxBackoff
+	if attempts < 32 {
+		delay = min(d.BaseBackoff<<(attempts-1), d.MaxBackoff)
+	}
+	return delay + rand.N(delay/10+1)
+}
+
+// Wakes Run up without blocking
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,203 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/repository"
+	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Records the events it receives, failing the first failures requests
+type receiver struct {
+	t        *testing.T
+	secret   string
+	failures int
+	mu       sync.Mutex
+	calls    int
+	received []events.Event
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.calls++
+	if rc.calls <= rc.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	payload, _ := io.ReadAll(r.Body)
+	timestamp, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if !Verify(rc.secret, timestamp, payload, r.Header.Get(SignatureHeader)) {
+		rc.t.Errorf("invalid signature %q", r.Header.Get(SignatureHeader))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var e events.Event
+	json.Unmarshal(payload, &e)
+	if string(e.Type) != r.Header.Get(EventHeader) {
+		rc.t.Errorf("event header %q does not match payload type %q", r.Header.Get(EventHeader), e.Type)
+	}
+	rc.received = append(rc.received, e)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Returns a dispatcher retrying quickly and a customer repository feeding it
+func newTestDispatcher(t *testing.T, rc *receiver, eventTypes ...string) (*Dispatcher, *providers.InMemoryCustomerRepository) {
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	d := NewDispatcher(l, providers.NewInMemoryWebhookRepository())
+	d.MaxAttempts = 3
+	d.BaseBackoff = time.Millisecond
+	d.MaxBackoff = time.Millisecond
+
+	d.Store.CreateSubscription(context.Background(), repository.WebhookSubscription{
+		ID:     uuid.New(),
+		URL:    srv.URL,
+		Events: eventTypes,
+		Secret: rc.secret,
+	})
+	return d, &providers.InMemoryCustomerRepository{Events: d}
+}
+
+// Calls DeliverDue until nothing is pending
+func drain(d *Dispatcher) {
+	for i := 0; i < 100; i++ {
+		d.DeliverDue(context.Background())
+		pending, _ := d.Store.ListDeliveries(context.Background(), repository.DeliveryPending)
+		if len(pending) == 0 {
+			return
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []string
+		failures int
+		received []events.Type
+		dead     int
+	}{
+		{"Every_event", []string{AllEvents}, 0, []events.Type{events.CustomerCreated, events.CustomerUpdated, events.CustomerDeleted}, 0},
+		{"Subscribed_events_only", []string{string(events.CustomerDeleted)}, 0, []events.Type{events.CustomerDeleted}, 0},
+		{"Retries_failures", []string{string(events.CustomerCreated)}, 2, []events.Type{events.CustomerCreated}, 0},
+		{"Dead_letters_after_max_attempts", []string{string(events.CustomerCreated)}, 3, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{t: t, secret: "s3cr3t", failures: tt.failures}
+			d, repo := newTestDispatcher(t, rc, tt.events...)
+
+			ctx := context.Background()
+			c := repository.Customer{ID: uuid.New(), Name: "Jorge", Email: "jorge@corp.com", PhoneNumber: "514 888 8888"}
+			repo.Create(ctx, c)
+			c.Contacted = true
+			repo.Update(ctx, c)
+			repo.Delete(ctx, c.ID)
+			drain(d)
+
+			rc.mu.Lock()
+			defer rc.mu.Unlock()
+			if len(rc.received) != len(tt.received) {
+				t.Fatalf("Received %v events, want %v", len(rc.received), len(tt.received))
+			}
+			for i, e := range rc.received {
+				if e.Type != tt.received[i] || e.CustomerID != c.ID {
+					t.Errorf("Event %d = %s %v, want %s %v", i, e.Type, e.CustomerID, tt.received[i], c.ID)
+				}
+			}
+			dead, _ := d.Store.ListDeliveries(ctx, repository.DeliveryDead)
+			if len(dead) != tt.dead {
+				t.Errorf("Dead deliveries=%v not equal to expected=%v", len(dead), tt.dead)
+			}
+		})
+	}
+}
+
+func TestDispatcherRedeliver(t *testing.T) {
+	rc := &receiver{t: t, secret: "s3cr3t", failures: 3}
+	d, repo := newTestDispatcher(t, rc, AllEvents)
+
+	ctx := context.Background()
+	repo.Create(ctx, repository.Customer{ID: uuid.New(), Name: "Jorge", Email: "jorge@corp.com", PhoneNumber: "514 888 8888"})
+	drain(d)
+
+	dead, _ := d.Store.ListDeliveries(ctx, repository.DeliveryDead)
+	if len(dead) != 1 {
+		t.Fatalf("Dead deliveries=%v not equal to expected=%v", len(dead), 1)
+	}
+	if _, err := d.Redeliver(ctx, dead[0].ID); err != nil {
+		t.Fatalf("Redeliver() error = %v", err)
+	}
+	if _, err := d.Redeliver(ctx, dead[0].ID); err != ErrNotRedeliverable {
+		t.Errorf("Redeliver() of a pending delivery error = %v, want %v", err, ErrNotRedeliverable)
+	}
+	drain(d)
+
+	delivered, _ := d.Store.ListDeliveries(ctx, repository.DeliveryDelivered)
+	if len(delivered) != 1 || delivered[0].Attempts != 1 {
+		t.Errorf("Delivered=%v, want a single delivery after one attempt", delivered)
+	}
+}
+
+func TestDispatcherDeliversPerTenant(t *testing.T) {
+	rc := &receiver{t: t, secret: "s3cr3t"}
+	d, repo := newTestDispatcher(t, rc, AllEvents)
+
+	acmeRC := &receiver{t: t, secret: "acme-s3cr3t"}
+	srv := httptest.NewServer(acmeRC)
+	t.Cleanup(srv.Close)
+	acme := repository.WithTenant(context.Background(), "acme")
+	d.Store.CreateSubscription(acme, repository.WebhookSubscription{
+		ID:     uuid.New(),
+		URL:    srv.URL,
+		Events: []string{AllEvents},
+		Secret: acmeRC.secret,
+	})
+
+	c := repository.Customer{ID: uuid.New(), Name: "Jorge", Email: "jorge@corp.com", PhoneNumber: "514 888 8888"}
+	repo.Create(acme, c)
+	for i := 0; i < 100; i++ {
+		d.DeliverDue(context.Background())
+		pending, _ := d.Store.ListDeliveries(acme, repository.DeliveryPending)
+		if len(pending) == 0 {
+			break
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	delivered, _ := d.Store.ListDeliveries(acme, repository.DeliveryDelivered)
+	if len(delivered) != 1 {
+		dead, _ := d.Store.ListDeliveries(acme, repository.DeliveryDead)
+		t.Fatalf("Delivered=%v, dead=%v, want a single delivery", delivered, dead)
+	}
+	acmeRC.mu.Lock()
+	defer acmeRC.mu.Unlock()
+	if len(acmeRC.received) != 1 || acmeRC.received[0].TenantID != "acme" {
+		t.Errorf("Tenant receiver got %v, want the acme event", acmeRC.received)
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.received) != 0 {
+		t.Errorf("Default tenant receiver got %v, want nothing", rc.received)
+	}
+}
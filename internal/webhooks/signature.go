@@ -0,0 +1,32 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	EventHeader     string = "X-CRM-Event"
+	DeliveryHeader  string = "X-CRM-Delivery"
+	TimestampHeader string = "X-CRM-Timestamp"
+	SignatureHeader string = "X-CRM-Signature"
+)
+
+// Sign returns the signature sent in the X-CRM-Signature header: the
+// hex-encoded HMAC-SHA256 of "<timestamp>.<payload>" keyed by secret,
+// prefixed by "sha256=".
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the valid signature of payload sent
+// at timestamp.
+func Verify(secret string, timestamp int64, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, payload)), []byte(signature))
+}
@@ -0,0 +1,6 @@
+\c customers;
+
+-- Owning user of a customer record, NULL while unassigned
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS owner_id UUID;
+
+CREATE INDEX IF NOT EXISTS customers_owner_id_idx ON customers (owner_id);
@@ -0,0 +1,29 @@
+\c customers;
+
+-- Append-only log of every customer mutation
+CREATE TABLE IF NOT EXISTS customer_audit (
+    id BIGSERIAL PRIMARY KEY,
+    customer_id UUID NOT NULL,
+    actor_id UUID,
+    actor_name VARCHAR(255) NOT NULL DEFAULT '',
+    operation VARCHAR(16) NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    before JSONB,
+    after JSONB,
+    diff JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS customer_audit_customer_id_idx ON customer_audit (customer_id, id);
+CREATE INDEX IF NOT EXISTS customer_audit_occurred_at_idx ON customer_audit (occurred_at);
+
+CREATE OR REPLACE FUNCTION customer_audit_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'customer_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS customer_audit_immutable ON customer_audit;
+CREATE TRIGGER customer_audit_immutable
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON customer_audit
+    FOR EACH STATEMENT EXECUTE FUNCTION customer_audit_immutable();
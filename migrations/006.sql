@@ -0,0 +1,24 @@
+\c customers;
+
+-- Notifies customer_changes of the changes made outside of the API, which
+-- marks its own transactions with crm.origin and publishes them through
+-- the outbox
+CREATE OR REPLACE FUNCTION customer_notify_change() RETURNS trigger AS $$
+BEGIN
+    IF current_setting('crm.origin', true) = 'api' THEN
+        RETURN NULL;
+    END IF;
+    PERFORM pg_notify('customer_changes', json_build_object(
+        'operation', TG_OP,
+        'occurred_at', now(),
+        'before', CASE WHEN TG_OP = 'INSERT' THEN NULL ELSE row_to_json(OLD) END,
+        'after', CASE WHEN TG_OP = 'DELETE' THEN NULL ELSE row_to_json(NEW) END
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS customer_notify_change ON customers;
+CREATE TRIGGER customer_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON customers
+    FOR EACH ROW EXECUTE FUNCTION customer_notify_change();
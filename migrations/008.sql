@@ -0,0 +1,36 @@
+\c customers;
+
+-- Business unit owning each record, existing records belong to the default one
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE customer_audit ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(63) NOT NULL DEFAULT 'default';
+
+-- Emails are unique within their tenant only
+ALTER TABLE customers DROP CONSTRAINT IF EXISTS customers_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS customers_tenant_email_idx ON customers (tenant_id, email);
+CREATE INDEX IF NOT EXISTS customer_audit_tenant_id_idx ON customer_audit (tenant_id, id);
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_tenant_id_idx ON webhook_subscriptions (tenant_id);
+
+-- Rows are visible to the transactions scoped to their tenant with
+-- set_config('crm.tenant', ...), or to every tenant with '*'. Superusers
+-- and roles with BYPASSRLS are not subject to the policies.
+CREATE OR REPLACE FUNCTION crm_tenant_visible(tenant VARCHAR) RETURNS BOOLEAN AS $$
+    SELECT current_setting('crm.tenant', true) IN (tenant, '*');
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE customers ENABLE ROW LEVEL SECURITY;
+ALTER TABLE customers FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON customers;
+CREATE POLICY tenant_isolation ON customers
+    USING (crm_tenant_visible(tenant_id))
+    WITH CHECK (crm_tenant_visible(tenant_id));
+
+ALTER TABLE customer_audit ENABLE ROW LEVEL SECURITY;
+ALTER TABLE customer_audit FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON customer_audit;
+CREATE POLICY tenant_isolation ON customer_audit
+    USING (crm_tenant_visible(tenant_id))
+    WITH CHECK (crm_tenant_visible(tenant_id));
+
+INSERT INTO schema_migrations (version) VALUES (8) ON CONFLICT DO NOTHING;
@@ -0,0 +1,13 @@
+\c customers;
+
+-- Requests made by each client per UTC day, counted against their daily quota
+CREATE TABLE IF NOT EXISTS api_quotas (
+    client VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    used INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (client, day)
+);
+
+CREATE INDEX IF NOT EXISTS api_quotas_day_idx ON api_quotas (day);
+
+INSERT INTO schema_migrations (version) VALUES (9) ON CONFLICT DO NOTHING;
@@ -0,0 +1,19 @@
+\c customers;
+
+-- First response to the requests sent with an Idempotency-Key, replayed to
+-- their retries until the key expires
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    client VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    fingerprint CHAR(64) NOT NULL,
+    -- 0 while the first request is in progress
+    status INTEGER NOT NULL DEFAULT 0,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    body BYTEA,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (client, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+INSERT INTO schema_migrations (version) VALUES (10) ON CONFLICT DO NOTHING;
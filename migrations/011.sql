@@ -0,0 +1,44 @@
+\c customers;
+
+-- Role the API connects as. Superusers and roles with BYPASSRLS ignore the
+-- row-level security policies, so the API must not connect as postgres.
+-- Its password is read from CRM_APP_PASSWORD, crm_app when unset.
+\getenv app_password CRM_APP_PASSWORD
+\if :{?app_password}
+\else
+    \set app_password crm_app
+\endif
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = 'crm_app') THEN
+        CREATE ROLE crm_app;
+    END IF;
+END;
+$$;
+ALTER ROLE crm_app WITH LOGIN NOSUPERUSER NOCREATEDB NOCREATEROLE NOBYPASSRLS PASSWORD :'app_password';
+
+GRANT CONNECT ON DATABASE customers TO crm_app;
+GRANT USAGE ON SCHEMA public TO crm_app;
+GRANT SELECT, INSERT, UPDATE, DELETE ON customers, webhook_subscriptions, webhook_deliveries, outbox, api_quotas, idempotency_keys TO crm_app;
+-- The audit log is append-only
+GRANT SELECT, INSERT ON customer_audit TO crm_app;
+GRANT SELECT ON schema_migrations TO crm_app;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO crm_app;
+
+-- Webhooks are isolated like customers, see migration 008
+ALTER TABLE webhook_subscriptions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_subscriptions FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON webhook_subscriptions;
+CREATE POLICY tenant_isolation ON webhook_subscriptions
+    USING (crm_tenant_visible(tenant_id))
+    WITH CHECK (crm_tenant_visible(tenant_id));
+
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON webhook_deliveries;
+CREATE POLICY tenant_isolation ON webhook_deliveries
+    USING (crm_tenant_visible(tenant_id))
+    WITH CHECK (crm_tenant_visible(tenant_id));
+
+INSERT INTO schema_migrations (version) VALUES (11) ON CONFLICT DO NOTHING;
@@ -0,0 +1,7 @@
+\c customers;
+
+-- Lets the outbox relay find the customers held back by a failed message,
+-- whose messages it relays after the others, see internal/outbox
+CREATE INDEX IF NOT EXISTS outbox_pending_aggregate_idx ON outbox (aggregate_id, id) WHERE published_at IS NULL;
+
+INSERT INTO schema_migrations (version) VALUES (12) ON CONFLICT DO NOTHING;
@@ -1,3 +1,5 @@
 #!/bin/sh
 
-psql -U postgres -h localhost -f /docker-entrypoint-initdb.d/000.sql
\ No newline at end of file
+for migration in /docker-entrypoint-initdb.d/[0-9][0-9][0-9].sql; do
+  psql -U postgres -h localhost -f "$migration"
+done
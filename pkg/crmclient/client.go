@@ -0,0 +1,235 @@
+// Package crmclient is a client of the CRM API, with a method per route.
+// Requests failing with a 429 or 5xx status, or without response, are
+// retried with an exponential backoff, the POST ones carrying an
+// Idempotency-Key for their retries to be applied once. Errors answered by
+// the API are returned as *Error.
+//
+//	c, err := crmclient.New("https://crm.example.com", crmclient.WithToken(token))
+//	for customer, err := range c.Customers(ctx, 100) {
+//		...
+//	}
+package crmclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultRetries = 3
+	defaultBackoff = 200 * time.Millisecond
+	// Waits asked for by Retry-After beyond it are not waited for, such as
+	// the end of a daily quota
+	maxRetryAfter = 30 * time.Second
+)
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	header     http.Header
+	retries    int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with hc, which should not time out
+// the change streams.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithToken authenticates the requests with a bearer token, for servers
+// in the token auth mode.
+func WithToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithTenant sends the requests to a tenant, for servers resolving
+// tenants from headers.
+func WithTenant(tenant string) Option {
+	return WithHeader("X-Tenant-ID", tenant)
+}
+
+// WithHeader sets a header on every request, such as the X-User-ID and
+// X-User-Role set by an authenticating proxy.
+func WithHeader(key, value string) Option {
+	return func(c *Client) { c.header.Set(key, value) }
+}
+
+// WithRetries retries failed requests up to n times, waiting for backoff
+// before the first retry and twice as long before every next one. Zero
+// disables the retries.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = n, backoff }
+}
+
+// New returns a client of the server at baseURL, such as
+// https://crm.example.com.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base URL %q is not an http or https URL", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		header:     http.Header{"User-Agent": {"crmclient"}},
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	header http.Header
+}
+
+// Sends req and decodes its response into out, unless nil. It returns the
+// response headers.
+func (c *Client) do(ctx context.Context, req request, out any) (http.Header, error) {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.Header, fmt.Errorf("decoding response to %s %s: %w", req.method, req.path, err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// Sends req until it succeeds or its retries are exhausted, returning the
+// successful response, whose body must be closed.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, err
+		}
+	}
+	header := c.header.Clone()
+	for k, v := range req.header {
+		header[k] = v
+	}
+	if req.method == http.MethodPost && header.Get("Idempotency-Key") == "" {
+		// Retries of the same call share the key
+		header.Set("Idempotency-Key", uuid.NewString())
+	}
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	u := c.baseURL.JoinPath(req.path)
+	u.RawQuery = req.query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		r, err := http.NewRequestWithContext(ctx, req.method, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		r.Header = header.Clone()
+
+		resp, err := c.httpClient.Do(r)
+		wait := min(c.backoff<<attempt, maxRetryAfter)
+		wait += rand.N(wait/2 + 1)
+		if err == nil {
+			if resp.StatusCode < http.StatusBadRequest {
+				return resp, nil
+			}
+			apiErr := decodeError(resp)
+			resp.Body.Close()
+			err = apiErr
+			retryAfter, retryable := shouldRetry(resp, apiErr)
+			if !retryable {
+				return nil, err
+			}
+			wait = max(wait, retryAfter)
+		}
+		if ctx.Err() != nil || attempt >= c.retries {
+			return nil, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// Reports whether the failed response should be retried, and after how
+// long at least
+func shouldRetry(resp *http.Response, err *Error) (time.Duration, bool) {
+	var retryAfter time.Duration
+	if s, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+		retryAfter = time.Duration(s) * time.Second
+	}
+	switch {
+	case retryAfter > maxRetryAfter, err.Code == QuotaExceeded:
+		return 0, false
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= http.StatusInternalServerError:
+		return retryAfter, true
+	case err.Code == RequestInProgress:
+		// The first attempt of a timed out request is still being handled
+		return retryAfter, true
+	}
+	return 0, false
+}
+
+// Reads the *Error of a failed response
+func decodeError(resp *http.Response) *Error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	e := &Error{}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/problem+json" || json.Unmarshal(b, e) != nil || e.Status == 0 {
+		// Such as the responses of proxies
+		e = &Error{Title: http.StatusText(resp.StatusCode), Detail: strings.TrimSpace(string(b))}
+	}
+	e.Status = resp.StatusCode
+	return e
+}
+
+// Returns the after parameter of the next page linked by header, if any
+func nextPage(header http.Header, limit int) *PageOptions {
+	for _, link := range header.Values("Link") {
+		target, params, ok := strings.Cut(link, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			continue
+		}
+		if after, err := uuid.Parse(u.Query().Get("after")); err == nil {
+			return &PageOptions{Limit: limit, After: after}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package crmclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/EdmundHusserl/CRM/internal/assignment"
+	"github.com/EdmundHusserl/CRM/internal/auth"
+	"github.com/EdmundHusserl/CRM/internal/events"
+	"github.com/EdmundHusserl/CRM/internal/handlers"
+	"github.com/EdmundHusserl/CRM/internal/idempotency"
+	"github.com/EdmundHusserl/CRM/internal/repository/providers"
+	"github.com/EdmundHusserl/CRM/internal/requestid"
+	"github.com/EdmundHusserl/CRM/internal/router"
+	"github.com/EdmundHusserl/CRM/internal/tenant"
+	"github.com/EdmundHusserl/CRM/internal/webhooks"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Returns the real router over an in-memory repository, served to
+// anonymous administrators
+func newRouter(t *testing.T) http.Handler {
+	t.Helper()
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	repo := providers.NewInMemoryCustomerRepository(nil)
+	bus := events.NewBus(16)
+	repo.Events = bus
+	dispatcher := webhooks.NewDispatcher(l, providers.NewInMemoryWebhookRepository())
+	keys := idempotency.NewKeys(l, providers.NewIdempotencyRepository(repo), time.Hour, time.Hour)
+	return router.NewRouter(
+		handlers.NewCustomerHandler(l, repo, assignment.NewStrategy(l, ""), bus),
+		handlers.NewWebhookHandler(l, dispatcher.Store, dispatcher),
+		handlers.NewHealthHandler(l, repo, "in-memory"),
+		requestid.Middleware,
+		auth.Middleware(l, auth.AnonymousResolver{}),
+		tenant.Middleware(l, tenant.DefaultResolver{}),
+		keys.Middleware,
+	)
+}
+
+func newClient(t *testing.T, h http.Handler, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c, err := New(srv.URL, append([]Option{WithRetries(3, time.Millisecond)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func newCustomer(name string) Customer {
+	return Customer{Name: name, Role: Premium, Email: name + "@corp.com", PhoneNumber: "+1 555 010 0100"}
+}
+
+func TestCustomers(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newRouter(t))
+
+	id, err := c.CreateCustomer(ctx, newCustomer("jorge"))
+	if err != nil {
+		t.Fatalf("CreateCustomer() error = %v", err)
+	}
+	jorge, err := c.GetCustomer(ctx, id)
+	if err != nil || jorge.Name != "jorge" || jorge.Role != Premium {
+		t.Fatalf("GetCustomer() = %+v, %v", jorge, err)
+	}
+	jorge.Contacted = true
+	if updated, err := c.UpdateCustomer(ctx, *jorge); err != nil || !updated.Contacted {
+		t.Fatalf("UpdateCustomer() = %+v, %v", updated, err)
+	}
+	owner := uuid.New()
+	if assigned, err := c.AssignCustomer(ctx, id, owner); err != nil || assigned.OwnerID != owner {
+		t.Fatalf("AssignCustomer() = %+v, %v", assigned, err)
+	}
+	imported, err := c.ImportCustomers(ctx, []Customer{newCustomer("ana"), newCustomer("bo"), newCustomer("cy"), newCustomer("di")})
+	if err != nil || len(imported) != 4 {
+		t.Fatalf("ImportCustomers() = %v, %v", imported, err)
+	}
+	if n, err := c.ReassignCustomers(ctx, owner, uuid.New()); err != nil || n != 1 {
+		t.Fatalf("ReassignCustomers() = %d, %v", n, err)
+	}
+
+	var listed []uuid.UUID
+	for customer, err := range c.Customers(ctx, 2) {
+		if err != nil {
+			t.Fatalf("Customers() error = %v", err)
+		}
+		listed = append(listed, customer.ID)
+	}
+	all, err := c.ListCustomers(ctx, PageOptions{})
+	if err != nil || all.Next != nil || len(all.Customers) != 5 {
+		t.Fatalf("ListCustomers() = %+v, %v", all, err)
+	}
+	for i, customer := range all.Customers {
+		if i >= len(listed) || listed[i] != customer.ID {
+			t.Fatalf("Customers() by pages = %v, want the ids of %+v", listed, all.Customers)
+		}
+	}
+	if first, err := c.ListCustomers(ctx, PageOptions{Limit: 4}); err != nil || first.Next == nil || first.Next.After != first.Customers[3].ID {
+		t.Errorf("ListCustomers() = %+v, %v, want a next page after the fourth customer", first, err)
+	}
+
+	if err := c.DeleteCustomer(ctx, id); err != nil {
+		t.Fatalf("DeleteCustomer() error = %v", err)
+	}
+	for trashed, err := range c.Trash(ctx, 10) {
+		if err != nil || trashed.ID != id {
+			t.Errorf("Trash() = %+v, %v, want %v", trashed, err, id)
+		}
+	}
+	if restored, err := c.RestoreCustomer(ctx, id); err != nil || restored.DeletedAt != nil {
+		t.Errorf("RestoreCustomer() = %+v, %v", restored, err)
+	}
+
+	history, err := c.CustomerHistory(ctx, id)
+	var operations []Operation
+	for _, e := range history {
+		operations = append(operations, e.Operation)
+	}
+	slices.Sort(operations)
+	want := []Operation{OperationAssign, OperationAssign, OperationCreate, OperationDelete, OperationRestore, OperationUpdate}
+	if err != nil || !slices.Equal(operations, want) {
+		t.Errorf("CustomerHistory() = %v, %v, want %v", operations, err, want)
+	}
+	if entries, err := c.AuditLog(ctx, AuditFilter{Operation: OperationCreate, Limit: 2}); err != nil || len(entries) != 2 {
+		t.Errorf("AuditLog() = %d entries, %v, want 2", len(entries), err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newRouter(t))
+
+	_, err := c.CreateCustomer(ctx, Customer{Name: "jorge", Email: "jorge@", PhoneNumber: "+1 555 010 0100"})
+	e, ok := err.(*Error)
+	if !ok || e.Status != http.StatusUnprocessableEntity || e.Code != ValidationFailed ||
+		!slices.Equal(e.Errors, []FieldError{{Pointer: "/email", Reason: `"jorge@" is not an e-mail address`}}) {
+		t.Errorf("CreateCustomer() error = %#v, want the invalid e-mail", err)
+	}
+	if _, err := c.GetCustomer(ctx, uuid.New()); !HasCode(err, NotFound) {
+		t.Errorf("GetCustomer() error = %v, want %s", err, NotFound)
+	}
+	if _, err := c.ListCustomers(ctx, PageOptions{Limit: 5000}); !HasCode(err, InvalidParameter) {
+		t.Errorf("ListCustomers() error = %v, want %s", err, InvalidParameter)
+	}
+
+	proxy := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+	}), WithRetries(0, 0))
+	if err := proxy.Live(ctx); err == nil || err.(*Error).Status != http.StatusBadGateway || err.(*Error).Detail != "upstream unavailable" {
+		t.Errorf("Live() error = %#v, want the response of the proxy", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    []int
+		header      http.Header
+		code        Code
+		wantErr     bool
+		wantAttempt int
+	}{
+		{"server errors", []int{http.StatusServiceUnavailable, http.StatusInternalServerError}, nil, Internal, false, 3},
+		{"rate limited", []int{http.StatusTooManyRequests}, http.Header{"Retry-After": {"0"}}, RateLimited, false, 2},
+		{"exhausted retries", []int{502, 502, 502, 502}, nil, Internal, true, 4},
+		{"quota exceeded", []int{http.StatusTooManyRequests}, http.Header{"Retry-After": {"0"}}, QuotaExceeded, true, 1},
+		{"long retry after", []int{http.StatusServiceUnavailable}, http.Header{"Retry-After": {"3600"}}, Maintenance, true, 1},
+		{"client error", []int{http.StatusBadRequest}, nil, InvalidPayload, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newRouter(t)
+			var (
+				mu   sync.Mutex
+				keys []string
+			)
+			flaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				attempt := len(keys)
+				keys = append(keys, r.Header.Get("Idempotency-Key"))
+				mu.Unlock()
+				if attempt < len(tt.failures) {
+					for k, v := range tt.header {
+						w.Header()[k] = v
+					}
+					w.Header().Set("Content-Type", "application/problem+json")
+					w.WriteHeader(tt.failures[attempt])
+					fmt.Fprintf(w, `{"status":%d,"code":%q}`, tt.failures[attempt], tt.code)
+					return
+				}
+				api.ServeHTTP(w, r)
+			})
+			c := newClient(t, flaky)
+
+			_, err := c.CreateCustomer(context.Background(), newCustomer("jorge"))
+			if (err != nil) != tt.wantErr || len(keys) != tt.wantAttempt {
+				t.Fatalf("CreateCustomer() error = %v after %d attempts, want %d attempts", err, len(keys), tt.wantAttempt)
+			}
+			if err != nil && !HasCode(err, tt.code) {
+				t.Errorf("CreateCustomer() error = %v, want %s", err, tt.code)
+			}
+			for _, key := range keys {
+				if key == "" || key != keys[0] {
+					t.Errorf("Idempotency-Key of the attempts = %q, want the same key", keys)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestStreamCustomers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := newClient(t, newRouter(t))
+
+	if _, err := c.CreateCustomer(ctx, newCustomer("ana")); err != nil {
+		t.Fatal(err)
+	}
+	bo, err := c.CreateCustomer(ctx, newCustomer("bo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Resumed after the first event, the second is replayed
+	for e, err := range c.StreamCustomers(ctx, StreamOptions{LastEventID: 1}) {
+		if err != nil || e.Type != CustomerCreated || e.CustomerID != bo || e.Seq != 2 {
+			t.Errorf("StreamCustomers() = %+v, %v, want the creation of %v", e, err, bo)
+		}
+		break
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newRouter(t))
+
+	s, err := c.CreateWebhook(ctx, WebhookSubscription{URL: "https://hooks.example.com/crm", Events: []string{"*"}})
+	if err != nil || s.ID == uuid.Nil || s.Secret == "" {
+		t.Fatalf("CreateWebhook() = %+v, %v", s, err)
+	}
+	if subscriptions, err := c.ListWebhooks(ctx); err != nil || len(subscriptions) != 1 {
+		t.Errorf("ListWebhooks() = %+v, %v", subscriptions, err)
+	}
+	if deliveries, err := c.ListDeliveries(ctx, DeliveryDead); err != nil || len(deliveries) != 0 {
+		t.Errorf("ListDeliveries() = %+v, %v", deliveries, err)
+	}
+	if _, err := c.Redeliver(ctx, uuid.New()); !HasCode(err, NotFound) {
+		t.Errorf("Redeliver() error = %v, want %s", err, NotFound)
+	}
+	if err := c.DeleteWebhook(ctx, s.ID); err != nil {
+		t.Errorf("DeleteWebhook() error = %v", err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newRouter(t))
+
+	if err := c.Live(ctx); err != nil {
+		t.Errorf("Live() error = %v", err)
+	}
+	if r, err := c.Ready(ctx); err != nil || r.Status != "ready" {
+		t.Errorf("Ready() = %+v, %v", r, err)
+	}
+	if v, err := c.Version(ctx); err != nil || v.Provider != "in-memory" {
+		t.Errorf("Version() = %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,245 @@
+package crmclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateCustomer creates c and returns its id. Its owner defaults to the
+// caller for sales reps, then to the assignment rules of the server.
+func (c *Client) CreateCustomer(ctx context.Context, customer Customer) (uuid.UUID, error) {
+	var created struct {
+		ID uuid.UUID `json:"id"`
+	}
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/api/customers", body: customer}, &created)
+	return created.ID, err
+}
+
+func (c *Client) GetCustomer(ctx context.Context, id uuid.UUID) (*Customer, error) {
+	var customer Customer
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/customers/" + id.String()}, &customer); err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// UpdateCustomer changes every field of the customer of the same id, but
+// its owner, see AssignCustomer.
+func (c *Client) UpdateCustomer(ctx context.Context, customer Customer) (*Customer, error) {
+	var updated Customer
+	if _, err := c.do(ctx, request{method: http.MethodPatch, path: "/api/customers", body: customer}, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteCustomer moves a customer to the trash, see RestoreCustomer.
+func (c *Client) DeleteCustomer(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/api/customers/" + id.String()}, nil)
+	return err
+}
+
+// ImportCustomers creates customers in bulk, all or none, and returns
+// their ids in order.
+func (c *Client) ImportCustomers(ctx context.Context, customers []Customer) ([]uuid.UUID, error) {
+	var imported struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/api/customers/import", body: customers}, &imported)
+	return imported.IDs, err
+}
+
+// AssignCustomer makes ownerID the owner of the customer of id.
+func (c *Client) AssignCustomer(ctx context.Context, id, ownerID uuid.UUID) (*Customer, error) {
+	var customer Customer
+	body := map[string]uuid.UUID{"owner_id": ownerID}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/customers/" + id.String() + "/assign", body: body}, &customer); err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// ReassignCustomers transfers every customer of an owner to another and
+// returns how many were transferred.
+func (c *Client) ReassignCustomers(ctx context.Context, from, to uuid.UUID) (int, error) {
+	var reassigned struct {
+		Reassigned int `json:"reassigned"`
+	}
+	body := map[string]uuid.UUID{"from_owner_id": from, "to_owner_id": to}
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/api/customers/reassign", body: body}, &reassigned)
+	return reassigned.Reassigned, err
+}
+
+// ListCustomers returns a page of the customers visible to the caller, or
+// all of them when opts has no Limit.
+func (c *Client) ListCustomers(ctx context.Context, opts PageOptions) (*CustomerPage, error) {
+	return c.listPage(ctx, "/api/customers", opts)
+}
+
+// Customers iterates over the customers visible to the caller, fetched by
+// pages of pageSize. It stops at the first error.
+func (c *Client) Customers(ctx context.Context, pageSize int) iter.Seq2[Customer, error] {
+	return c.iterate(ctx, "/api/customers", pageSize)
+}
+
+// ListTrash returns a page of the deleted customers, or all of them when
+// opts has no Limit.
+func (c *Client) ListTrash(ctx context.Context, opts PageOptions) (*CustomerPage, error) {
+	return c.listPage(ctx, "/api/customers/trash", opts)
+}
+
+// Trash iterates over the deleted customers, fetched by pages of
+// pageSize. It stops at the first error.
+func (c *Client) Trash(ctx context.Context, pageSize int) iter.Seq2[Customer, error] {
+	return c.iterate(ctx, "/api/customers/trash", pageSize)
+}
+
+// RestoreCustomer moves a customer out of the trash.
+func (c *Client) RestoreCustomer(ctx context.Context, id uuid.UUID) (*Customer, error) {
+	var customer Customer
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/customers/" + id.String() + "/restore"}, &customer); err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+func (c *Client) listPage(ctx context.Context, path string, opts PageOptions) (*CustomerPage, error) {
+	query := url.Values{}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.After != uuid.Nil {
+		query.Set("after", opts.After.String())
+	}
+	page := &CustomerPage{}
+	header, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query}, &page.Customers)
+	if err != nil {
+		return nil, err
+	}
+	page.Next = nextPage(header, opts.Limit)
+	return page, nil
+}
+
+func (c *Client) iterate(ctx context.Context, path string, pageSize int) iter.Seq2[Customer, error] {
+	return func(yield func(Customer, error) bool) {
+		if pageSize < 1 {
+			yield(Customer{}, fmt.Errorf("page size %d must be positive", pageSize))
+			return
+		}
+		opts := &PageOptions{Limit: pageSize}
+		for opts != nil {
+			page, err := c.listPage(ctx, path, *opts)
+			if err != nil {
+				yield(Customer{}, err)
+				return
+			}
+			for _, customer := range page.Customers {
+				if !yield(customer, nil) {
+					return
+				}
+			}
+			opts = page.Next
+		}
+	}
+}
+
+// CustomerHistory returns the audit entries of a customer.
+func (c *Client) CustomerHistory(ctx context.Context, id uuid.UUID) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/api/customers/" + id.String() + "/history"}, &entries)
+	return entries, err
+}
+
+// AuditLog searches the audit entries of every customer.
+func (c *Client) AuditLog(ctx context.Context, f AuditFilter) ([]AuditEntry, error) {
+	query := url.Values{}
+	if f.CustomerID != uuid.Nil {
+		query.Set("customer_id", f.CustomerID.String())
+	}
+	if f.ActorID != uuid.Nil {
+		query.Set("actor_id", f.ActorID.String())
+	}
+	if f.Operation != "" {
+		query.Set("operation", string(f.Operation))
+	}
+	if !f.Since.IsZero() {
+		query.Set("since", f.Since.Format(time.RFC3339))
+	}
+	if !f.Until.IsZero() {
+		query.Set("until", f.Until.Format(time.RFC3339))
+	}
+	if f.Limit > 0 {
+		query.Set("limit", strconv.Itoa(f.Limit))
+	}
+	var entries []AuditEntry
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/api/audit", query: query}, &entries)
+	return entries, err
+}
+
+// StreamCustomers iterates over the changes to customers as they happen,
+// until ctx is done or the server ends the stream. Streams ended by the
+// server are resumed by streaming again with the Seq of the last event as
+// LastEventID.
+func (c *Client) StreamCustomers(ctx context.Context, opts StreamOptions) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		query := url.Values{}
+		if opts.Role != nil {
+			query.Set("role", strconv.Itoa(int(*opts.Role)))
+		}
+		if opts.OwnerID != uuid.Nil {
+			query.Set("owner_id", opts.OwnerID.String())
+		}
+		header := http.Header{"Accept": {"text/event-stream"}}
+		if opts.LastEventID > 0 {
+			header.Set("Last-Event-ID", strconv.FormatUint(opts.LastEventID, 10))
+		}
+		resp, err := c.send(ctx, request{method: http.MethodGet, path: "/api/customers/stream", query: query, header: header})
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		var (
+			id   uint64
+			data strings.Builder
+		)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 4<<20)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "id":
+				id, _ = strconv.ParseUint(value, 10, 64)
+			case "data":
+				data.WriteString(value)
+			case "":
+				// A blank line ends an event, a comment is a heartbeat
+				if scanner.Text() != "" || data.Len() == 0 {
+					continue
+				}
+				var e Event
+				err := json.Unmarshal([]byte(data.String()), &e)
+				e.Seq = id
+				data.Reset()
+				if !yield(e, err) || err != nil {
+					return
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			yield(Event{}, err)
+		}
+	}
+}
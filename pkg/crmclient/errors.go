@@ -0,0 +1,61 @@
+package crmclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code identifies a kind of error, see docs/problems.md.
+type Code string
+
+const (
+	InvalidPayload       Code = "invalid_payload"
+	InvalidParameter     Code = "invalid_parameter"
+	ValidationFailed     Code = "validation_failed"
+	Unauthenticated      Code = "unauthenticated"
+	Forbidden            Code = "forbidden"
+	NotFound             Code = "not_found"
+	MethodNotAllowed     Code = "method_not_allowed"
+	Conflict             Code = "conflict"
+	RequestInProgress    Code = "request_in_progress"
+	IdempotencyKeyReused Code = "idempotency_key_reused"
+	InvalidTenant        Code = "invalid_tenant"
+	RateLimited          Code = "rate_limited"
+	QuotaExceeded        Code = "quota_exceeded"
+	Maintenance          Code = "maintenance"
+	Internal             Code = "internal_error"
+)
+
+// FieldError is an invalid member of the payload, designated by its JSON
+// Pointer, or an invalid query, path or header Parameter.
+type FieldError struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// Error is an error answered by the API, a problem detail (RFC 9457)
+// whose Code is stable unlike its Title and Detail.
+type Error struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     Code         `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Detail)
+	for _, f := range e.Errors {
+		msg += fmt.Sprintf(", %s%s: %s", f.Pointer, f.Parameter, f.Reason)
+	}
+	return msg
+}
+
+// HasCode reports whether err is an *Error of the given code.
+func HasCode(err error, code Code) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
@@ -0,0 +1,45 @@
+package crmclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Live reports whether the server serves requests.
+func (c *Client) Live(ctx context.Context) error {
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/healthz"}, nil)
+	return err
+}
+
+// Ready reports whether the server is ready to serve requests. An
+// unavailable server is not an error, its Readiness telling why.
+func (c *Client) Ready(ctx context.Context) (*Readiness, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL.JoinPath("/readyz").String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header = c.header.Clone()
+	resp, err := c.httpClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, decodeError(resp)
+	}
+	var readiness Readiness
+	if err := json.NewDecoder(resp.Body).Decode(&readiness); err != nil {
+		return nil, err
+	}
+	return &readiness, nil
+}
+
+// Version describes the build of the server.
+func (c *Client) Version(ctx context.Context) (*Version, error) {
+	var v Version
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/version"}, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
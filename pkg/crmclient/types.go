@@ -0,0 +1,165 @@
+package crmclient
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Role int
+
+const (
+	Basic Role = iota
+	Premium
+	Partner
+)
+
+type Customer struct {
+	ID          uuid.UUID  `json:"id"`
+	TenantID    string     `json:"tenant_id,omitempty"`
+	Name        string     `json:"name"`
+	Role        Role       `json:"role"`
+	Email       string     `json:"email"`
+	PhoneNumber string     `json:"phone_number"`
+	Contacted   bool       `json:"contacted"`
+	OwnerID     uuid.UUID  `json:"owner_id"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// PageOptions select a page of customers: the Limit ones following the
+// customer of id After, customers being ordered by id.
+type PageOptions struct {
+	Limit int
+	After uuid.UUID
+}
+
+type CustomerPage struct {
+	Customers []Customer
+	// Next selects the following page, nil on the last one
+	Next *PageOptions
+}
+
+type Operation string
+
+const (
+	OperationCreate  Operation = "create"
+	OperationUpdate  Operation = "update"
+	OperationDelete  Operation = "delete"
+	OperationAssign  Operation = "assign"
+	OperationRestore Operation = "restore"
+	OperationPurge   Operation = "purge"
+)
+
+// FieldChange holds the values of a field before and after a change.
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+type AuditEntry struct {
+	ID         int64                  `json:"id"`
+	TenantID   string                 `json:"tenant_id"`
+	CustomerID uuid.UUID              `json:"customer_id"`
+	ActorID    uuid.UUID              `json:"actor_id"`
+	ActorName  string                 `json:"actor_name"`
+	Operation  Operation              `json:"operation"`
+	RequestID  string                 `json:"request_id"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Before     *Customer              `json:"before"`
+	After      *Customer              `json:"after"`
+	Diff       map[string]FieldChange `json:"diff"`
+}
+
+// AuditFilter narrows the entries of the audit log, zero values meaning no
+// restriction.
+type AuditFilter struct {
+	CustomerID uuid.UUID
+	ActorID    uuid.UUID
+	Operation  Operation
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+type EventType string
+
+const (
+	CustomerCreated EventType = "customer.created"
+	CustomerUpdated EventType = "customer.updated"
+	CustomerDeleted EventType = "customer.deleted"
+)
+
+// Event describes a change to a customer.
+type Event struct {
+	ID         uuid.UUID              `json:"id"`
+	Type       EventType              `json:"type"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	TenantID   string                 `json:"tenant_id"`
+	CustomerID uuid.UUID              `json:"customer_id"`
+	Customer   *Customer              `json:"customer"`
+	Changes    map[string]FieldChange `json:"changes"`
+	ActorID    uuid.UUID              `json:"actor_id"`
+	RequestID  string                 `json:"request_id"`
+	// Seq resumes a stream after this event, see StreamOptions
+	Seq uint64 `json:"-"`
+}
+
+// StreamOptions narrow a change stream, zero values meaning no
+// restriction.
+type StreamOptions struct {
+	Role    *Role
+	OwnerID uuid.UUID
+	// LastEventID resumes the stream after the event of this Seq, as long
+	// as the server still holds the events that followed
+	LastEventID uint64
+}
+
+// WebhookSubscription asks for the events of the given types, or * for
+// all, to be POSTed to URL and signed with Secret.
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead"
+)
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	TenantID       string          `json:"tenant_id"`
+	SubscriptionID uuid.UUID       `json:"subscription_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+type Readiness struct {
+	// Status is ready, or unavailable along with Error
+	Status        string `json:"status"`
+	Provider      string `json:"provider"`
+	SchemaVersion int    `json:"schema_version"`
+	Error         string `json:"error_message,omitempty"`
+}
+
+type Version struct {
+	GitSHA    string `json:"git_sha"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	Provider  string `json:"provider"`
+}
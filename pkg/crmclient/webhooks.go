@@ -0,0 +1,51 @@
+package crmclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// CreateWebhook subscribes to customer events. The returned subscription
+// holds the Secret signing the deliveries, generated when s has none.
+func (c *Client) CreateWebhook(ctx context.Context, s WebhookSubscription) (*WebhookSubscription, error) {
+	var created WebhookSubscription
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/webhooks", body: s}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]WebhookSubscription, error) {
+	var subscriptions []WebhookSubscription
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/api/webhooks"}, &subscriptions)
+	return subscriptions, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/api/webhooks/" + id.String()}, nil)
+	return err
+}
+
+// ListDeliveries returns the webhook deliveries of a status, the dead ones
+// when it is empty.
+func (c *Client) ListDeliveries(ctx context.Context, status DeliveryStatus) ([]WebhookDelivery, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", string(status))
+	}
+	var deliveries []WebhookDelivery
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/api/webhooks/deliveries", query: query}, &deliveries)
+	return deliveries, err
+}
+
+// Redeliver sends a delivery again, usually a dead one.
+func (c *Client) Redeliver(ctx context.Context, id uuid.UUID) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/webhooks/deliveries/" + id.String() + "/redeliver"}, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}